@@ -0,0 +1,380 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// WiskeyClient is the client API for Wiskey service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WiskeyClient interface {
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	//Puts and deletes are applied atomically,either all of them are saved or none of them
+	BatchWrite(ctx context.Context, in *BatchWriteRequest, opts ...grpc.CallOption) (*BatchWriteResponse, error)
+	//Ordered keys and values in the range,the tree is read in pages so writes aren't blocked by a long scan
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (Wiskey_ScanClient, error)
+	//Changes of keys with the prefix that are written after the call,in the order of writes
+	//the stream ends with RESOURCE_EXHAUSTED if the client doesn't read events fast enough
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Wiskey_WatchClient, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+}
+
+type wiskeyClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWiskeyClient(cc grpc.ClientConnInterface) WiskeyClient {
+	return &wiskeyClient{cc}
+}
+
+func (c *wiskeyClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, "/wiskey.Wiskey/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wiskeyClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error) {
+	out := new(PutResponse)
+	err := c.cc.Invoke(ctx, "/wiskey.Wiskey/Put", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wiskeyClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/wiskey.Wiskey/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wiskeyClient) BatchWrite(ctx context.Context, in *BatchWriteRequest, opts ...grpc.CallOption) (*BatchWriteResponse, error) {
+	out := new(BatchWriteResponse)
+	err := c.cc.Invoke(ctx, "/wiskey.Wiskey/BatchWrite", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wiskeyClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (Wiskey_ScanClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Wiskey_ServiceDesc.Streams[0], "/wiskey.Wiskey/Scan", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &wiskeyScanClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Wiskey_ScanClient interface {
+	Recv() (*KeyValue, error)
+	grpc.ClientStream
+}
+
+type wiskeyScanClient struct {
+	grpc.ClientStream
+}
+
+func (x *wiskeyScanClient) Recv() (*KeyValue, error) {
+	m := new(KeyValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *wiskeyClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Wiskey_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Wiskey_ServiceDesc.Streams[1], "/wiskey.Wiskey/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &wiskeyWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Wiskey_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type wiskeyWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *wiskeyWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *wiskeyClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/wiskey.Wiskey/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WiskeyServer is the server API for Wiskey service.
+// All implementations must embed UnimplementedWiskeyServer
+// for forward compatibility
+type WiskeyServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Put(context.Context, *PutRequest) (*PutResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	//Puts and deletes are applied atomically,either all of them are saved or none of them
+	BatchWrite(context.Context, *BatchWriteRequest) (*BatchWriteResponse, error)
+	//Ordered keys and values in the range,the tree is read in pages so writes aren't blocked by a long scan
+	Scan(*ScanRequest, Wiskey_ScanServer) error
+	//Changes of keys with the prefix that are written after the call,in the order of writes
+	//the stream ends with RESOURCE_EXHAUSTED if the client doesn't read events fast enough
+	Watch(*WatchRequest, Wiskey_WatchServer) error
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	mustEmbedUnimplementedWiskeyServer()
+}
+
+// UnimplementedWiskeyServer must be embedded to have forward compatible implementations.
+type UnimplementedWiskeyServer struct {
+}
+
+func (UnimplementedWiskeyServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedWiskeyServer) Put(context.Context, *PutRequest) (*PutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+}
+func (UnimplementedWiskeyServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedWiskeyServer) BatchWrite(context.Context, *BatchWriteRequest) (*BatchWriteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchWrite not implemented")
+}
+func (UnimplementedWiskeyServer) Scan(*ScanRequest, Wiskey_ScanServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedWiskeyServer) Watch(*WatchRequest, Wiskey_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedWiskeyServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedWiskeyServer) mustEmbedUnimplementedWiskeyServer() {}
+
+// UnsafeWiskeyServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WiskeyServer will
+// result in compilation errors.
+type UnsafeWiskeyServer interface {
+	mustEmbedUnimplementedWiskeyServer()
+}
+
+func RegisterWiskeyServer(s grpc.ServiceRegistrar, srv WiskeyServer) {
+	s.RegisterService(&Wiskey_ServiceDesc, srv)
+}
+
+func _Wiskey_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiskeyServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wiskey.Wiskey/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiskeyServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wiskey_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiskeyServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wiskey.Wiskey/Put",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiskeyServer).Put(ctx, req.(*PutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wiskey_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiskeyServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wiskey.Wiskey/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiskeyServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wiskey_BatchWrite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchWriteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiskeyServer).BatchWrite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wiskey.Wiskey/BatchWrite",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiskeyServer).BatchWrite(ctx, req.(*BatchWriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wiskey_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WiskeyServer).Scan(m, &wiskeyScanServer{stream})
+}
+
+type Wiskey_ScanServer interface {
+	Send(*KeyValue) error
+	grpc.ServerStream
+}
+
+type wiskeyScanServer struct {
+	grpc.ServerStream
+}
+
+func (x *wiskeyScanServer) Send(m *KeyValue) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Wiskey_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WiskeyServer).Watch(m, &wiskeyWatchServer{stream})
+}
+
+type Wiskey_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type wiskeyWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *wiskeyWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Wiskey_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiskeyServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wiskey.Wiskey/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiskeyServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Wiskey_ServiceDesc is the grpc.ServiceDesc for Wiskey service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Wiskey_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "wiskey.Wiskey",
+	HandlerType: (*WiskeyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _Wiskey_Get_Handler,
+		},
+		{
+			MethodName: "Put",
+			Handler:    _Wiskey_Put_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Wiskey_Delete_Handler,
+		},
+		{
+			MethodName: "BatchWrite",
+			Handler:    _Wiskey_BatchWrite_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _Wiskey_Stats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _Wiskey_Scan_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Wiskey_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/wiskey.proto",
+}
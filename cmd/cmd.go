@@ -1,20 +1,77 @@
 package cmd
 
-import "github.com/jessevdk/go-flags"
+import (
+	"github.com/jessevdk/go-flags"
+	"time"
+)
 
-type options struct {
-	SStablePath  string `short:"s" long:"sstable" description:"A path to sstable directory" required:"true"`
-	Vlog         string `short:"v"  description:"A path to vlog file" required:"true"`
-	Checkpoint   string `short:"c" long:"checkpoint"  description:"A path to checkpoint file" required:"true"`
-	MemtableSize int    `short:"m" long:"memtable" description:"size of memtable" default:"20"`
+//Options of the command line,flags of subcommands are in their fields
+type Options struct {
+	Config       string   `long:"config" description:"A path to ini config file, flags from the command line override it" no-ini:"true"`
+	Dir          string   `short:"d" long:"dir" description:"A path to data directory, it keeps sstables, vlog and checkpoint"`
+	SStablePath  string   `short:"s" long:"sstable" description:"A path to sstable directory, overrides the one in data directory"`
+	Vlog         string   `short:"v" long:"vlog" description:"A path to vlog file, overrides the one in data directory"`
+	Checkpoint   string   `short:"c" long:"checkpoint"  description:"A path to checkpoint file, overrides the one in data directory"`
+	MemtableSize int      `short:"m" long:"memtable" description:"size of memtable in bytes" default:"4194304"`
+	Inline       int      `short:"i" long:"inline" description:"values smaller than this size in bytes are stored inline in sstables" default:"0"`
+	BlockCache   int      `short:"b" long:"block-cache" description:"size of sstable block cache in bytes, 0 disables it" default:"8388608"`
+	BlockSize    uint32   `short:"k" long:"block-size" description:"size of sstable block in bytes" default:"4096"`
+	ValueCache   int      `short:"x" long:"value-cache" description:"size of vlog value cache in bytes, 0 disables it" default:"0"`
+	Families     []string `short:"f" long:"family" description:"name of column family to open, can be repeated"`
+	Compression  string   `short:"z" long:"compression" description:"compression of sstable blocks and vlog values: none or flate" default:"none"`
+	//family:compression pairs
+	FamilyCompression  map[string]string `long:"family-compression" description:"compression of column family, overrides the global one, for example users:flate"`
+	CompactionInterval time.Duration     `long:"compaction-interval" description:"how often sstables are merged" default:"120s"`
+	Compaction         string            `long:"compaction" description:"how sstables are merged: pairwise or none" default:"pairwise"`
+	GcEntries          int               `long:"gc-entries" description:"how many vlog entries a single gc run reads" default:"2"`
+	GcInterval         time.Duration     `long:"gc-interval" description:"how often the vlog is collected in background, 0 collects it only by /gc" default:"0"`
+	Sync               string            `long:"sync" description:"when the vlog is fsynced: none or always" default:"none"`
+	BatchOperations    int               `long:"batch-operations" description:"max puts and deletes of POST /batch and keys of POST /batch/get, 0 disables the limit" default:"1000"`
+	BatchBytes         int               `long:"batch-bytes" description:"max size of POST /batch body in bytes, 0 disables the limit" default:"16777216"`
+	Grpc               string            `long:"grpc" description:"address of the grpc api, for example :9090, empty disables it"`
+	Redis              string            `long:"redis" description:"address of the redis protocol server, for example :6379, empty disables it"`
+	Bench              benchOptions      `command:"bench" description:"Run benchmark workloads in an empty data directory, the directory is removed afterwards"`
+	Stats              statsOptions      `command:"stats" description:"Print statistics of the data directory as JSON, the server can't use the directory at the same time"`
+	//name of the subcommand,empty if the server is started
+	Command string `no-flag:"true" no-ini:"true"`
 }
 
-func Parse() (*options, error) {
-	options := options{}
-	_, err := flags.Parse(&options)
+//Options of wiskey bench,the engine is configured by global options
+type benchOptions struct {
+	Benchmarks string `long:"benchmarks" description:"comma separated workloads: fillseq, fillrandom, overwrite, readrandom, readmissing, readseq, deleterandom, ycsba-ycsbf" default:"fillseq,fillrandom,overwrite,readrandom,readmissing,readseq,deleterandom,ycsba,ycsbb,ycsbc,ycsbd,ycsbe,ycsbf"`
+	Num        int    `long:"num" description:"keys written by fill workloads" default:"10000"`
+	Ops        int    `long:"ops" description:"operations of the other workloads, 0 means num" default:"0"`
+	KeySize    int    `long:"key-size" description:"size of keys in bytes" default:"16"`
+	ValueSize  int    `long:"value-size" description:"size of values in bytes" default:"100"`
+	Threads    int    `long:"threads" description:"goroutines that run operations" default:"1"`
+	Seed       int64  `long:"seed" description:"seed of random keys and values" default:"0"`
+}
+
+//wiskey stats has only global options
+type statsOptions struct{}
+
+func Parse() (*Options, error) {
+	options := Options{}
+	parser := flags.NewParser(&options, flags.Default)
+	parser.SubcommandsOptional = true
+	_, err := parser.Parse()
 	if err != nil {
 		return nil, err
 	}
+	if options.Config != "" {
+		err = flags.NewIniParser(parser).ParseFile(options.Config)
+		if err != nil {
+			return nil, err
+		}
+		//parse the command line again,so it overrides the config file
+		_, err = parser.Parse()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if parser.Active != nil {
+		options.Command = parser.Active.Name
+	}
 	return &options, nil
 
 }
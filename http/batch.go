@@ -0,0 +1,212 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+const (
+	putOperation    = "put"
+	deleteOperation = "delete"
+)
+
+//Put or delete of POST /batch,the default family is used if the family is empty
+type BatchOperation struct {
+	Op     string  `json:"op"`
+	Family string  `json:"family,omitempty"`
+	Key    string  `json:"key"`
+	Value  *string `json:"value,omitempty"` //required by put
+}
+
+type Keys struct {
+	Keys   []string `json:"keys" binding:"required"`
+	Family string   `json:"family"`
+}
+
+//Value of a key of POST /batch/get,the value is empty if the key wasn't found
+type KeyResult struct {
+	Key   string `json:"key"`
+	Found bool   `json:"found"`
+	Value string `json:"value,omitempty"`
+}
+
+//Error of the operation at the position in the batch
+type batchError struct {
+	status int
+	err    error
+}
+
+func (e *batchError) Error() string {
+	return e.err.Error()
+}
+
+func operationError(status int, position int, format string, args ...interface{}) *batchError {
+	return &batchError{status: status, err: fmt.Errorf("operation %d: "+format, append([]interface{}{position}, args...)...)}
+}
+
+//Read operations of the body,it's a json array or one operation per line with Content-Type: application/x-ndjson
+func readOperations(c *gin.Context, config Config) ([]BatchOperation, error) {
+	reader := io.Reader(c.Request.Body)
+	if config.MaxBatchBytes != 0 {
+		reader = io.LimitReader(reader, int64(config.MaxBatchBytes)+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, &batchError{status: http.StatusBadRequest, err: err}
+	}
+	if config.MaxBatchBytes != 0 && len(body) > config.MaxBatchBytes {
+		return nil, &batchError{status: http.StatusRequestEntityTooLarge, err: errors.New("batch is bigger than " + strconv.Itoa(config.MaxBatchBytes) + " bytes")}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	ndjson := c.ContentType() == ndjsonType
+	if !ndjson {
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, &batchError{status: http.StatusBadRequest, err: errors.New("batch has to be a json array of operations")}
+		}
+	}
+	operations := []BatchOperation{}
+	for ndjson || decoder.More() {
+		var operation BatchOperation
+		err := decoder.Decode(&operation)
+		if ndjson && err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, operationError(http.StatusBadRequest, len(operations), "%v", err)
+		}
+		if config.MaxBatchOperations != 0 && len(operations) == config.MaxBatchOperations {
+			return nil, &batchError{status: http.StatusRequestEntityTooLarge, err: errors.New("batch has more than " + strconv.Itoa(config.MaxBatchOperations) + " operations")}
+		}
+		operations = append(operations, operation)
+	}
+	return operations, nil
+}
+
+//Add the put or delete of the family to the batch,
+//changes of the default family remove content types as POST /{key} and DELETE /{key}
+func batchChange(lsm *LsmTree, batch *WriteBatch, family *LsmTree, name string, key []byte, value []byte, deleted bool) {
+	switch {
+	case family == lsm && deleted:
+		batchDelete(lsm, batch, key)
+	case family == lsm:
+		batchPut(lsm, batch, key, value, "")
+	case deleted:
+		batch.Delete(name, key)
+	default:
+		batch.Put(name, key, value)
+	}
+}
+
+//Build the write batch of the operations
+func writeBatch(lsm *LsmTree, operations []BatchOperation, encoding string) (*WriteBatch, error) {
+	batch := NewWriteBatch()
+	for i, operation := range operations {
+		family, ok := lsm.ColumnFamily(operation.Family)
+		if !ok {
+			return nil, operationError(http.StatusNotFound, i, "column family %s doesn't exist", operation.Family)
+		}
+		key, err := decodeString(encoding, operation.Key)
+		if err != nil {
+			return nil, operationError(http.StatusBadRequest, i, "invalid key: %v", err)
+		}
+		if len(key) == 0 {
+			return nil, operationError(http.StatusBadRequest, i, "key can't be empty")
+		}
+		switch operation.Op {
+		case putOperation:
+			if operation.Value == nil {
+				return nil, operationError(http.StatusBadRequest, i, "put requires a value")
+			}
+			value, err := decodeString(encoding, *operation.Value)
+			if err != nil {
+				return nil, operationError(http.StatusBadRequest, i, "invalid value: %v", err)
+			}
+			batchChange(lsm, batch, family, operation.Family, key, value, false)
+		case deleteOperation:
+			batchChange(lsm, batch, family, operation.Family, key, nil, true)
+		default:
+			return nil, operationError(http.StatusBadRequest, i, "op has to be put or delete")
+		}
+	}
+	return batch, nil
+}
+
+//Apply puts and deletes atomically,either all of them are saved or none of them
+func batchHandler(lsm *LsmTree, config Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding, ok := jsonEncoding(c)
+		if !ok {
+			return
+		}
+		operations, err := readOperations(c, config)
+		var batch *WriteBatch
+		if err == nil {
+			batch, err = writeBatch(lsm, operations, encoding)
+		}
+		var invalid *batchError
+		if errors.As(err, &invalid) {
+			c.JSON(invalid.status, gin.H{"error": invalid.Error()})
+			return
+		}
+		if err = lsm.Write(batch); err != nil {
+			respondWithError(c, err)
+		} else {
+			c.JSON(http.StatusOK, gin.H{"operations": len(operations)})
+		}
+	}
+}
+
+//Values of many keys at once in the order of the keys,each key has its found status
+func batchGetHandler(lsm *LsmTree, config Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var json Keys
+		encoding, ok := jsonEncoding(c)
+		if !ok {
+			return
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if config.MaxBatchOperations != 0 && len(json.Keys) > config.MaxBatchOperations {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "batch has more than " + strconv.Itoa(config.MaxBatchOperations) + " keys"})
+			return
+		}
+		family, ok := lsm.ColumnFamily(json.Family)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "column family " + json.Family + " doesn't exist"})
+			return
+		}
+		keys := make([][]byte, len(json.Keys))
+		for i, key := range json.Keys {
+			if keys[i], ok = decodeValue(c, encoding, key); !ok {
+				return
+			}
+		}
+		values, found, err := family.MultiGet(keys)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		//values keeps the old response of found keys only
+		results := make([]KeyResult, len(json.Keys))
+		valuesByKey := make(map[string]string)
+		for i, key := range json.Keys {
+			results[i] = KeyResult{Key: key, Found: found[i]}
+			if found[i] {
+				results[i].Value = encodeValue(encoding, values[i])
+				valuesByKey[key] = results[i].Value
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results, "values": valuesByKey})
+	}
+}
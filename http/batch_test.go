@@ -0,0 +1,132 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+//Body of POST /batch as a json array or as ndjson lines
+func batchBody(ndjson bool, operations ...string) (string, string) {
+	if ndjson {
+		return strings.Join(operations, "\n") + "\n", ndjsonType
+	}
+	return "[" + strings.Join(operations, ",") + "]", "application/json"
+}
+
+func TestBatchHandler_Atomic(t *testing.T) {
+	tree, router := newTestRouter(t, Config{MaxBatchOperations: 4, MaxBatchBytes: 1024})
+	users, _ := tree.ColumnFamily("users")
+	valid := []string{
+		`{"op":"put","key":"a","value":"1"}`,
+		`{"op":"put","family":"users","key":"b","value":"2"}`,
+	}
+	for _, ndjson := range []bool{false, true} {
+		for invalid, status := range map[string]int{
+			`{"op":"update","key":"c","value":"3"}`:                 http.StatusBadRequest,
+			`{"op":"put","key":"c"}`:                                http.StatusBadRequest,
+			`{"op":"put","key":"","value":"3"}`:                     http.StatusBadRequest,
+			`{"op":"put","key":"c","value":"3","ttl":1}`:            http.StatusBadRequest,
+			`{"op":"put","family":"missing","key":"c","value":"3"}`: http.StatusNotFound,
+			`{"op":"put","key":`:                                    http.StatusBadRequest,
+		} {
+			body, contentType := batchBody(ndjson, append(valid, invalid)...)
+			recorder := serve(router, http.MethodPost, "/batch", strings.NewReader(body), "Content-Type", contentType)
+			var response map[string]string
+			decodeResponse(t, recorder, status, &response)
+			if !strings.HasPrefix(response["error"], "operation 2") {
+				t.Fatalf("error of %s doesn't point to the operation: %s", invalid, response["error"])
+			}
+			//nothing of the batch was written
+			if _, err := tree.Get([]byte("a")); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("put before %s was applied,ndjson %v", invalid, ndjson)
+			}
+			if _, err := users.Get([]byte("b")); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("put to the family before %s was applied,ndjson %v", invalid, ndjson)
+			}
+		}
+	}
+	//limits reject the whole batch as well
+	for _, operations := range [][]string{
+		append(valid, valid[0], valid[0], valid[0]),
+		{`{"op":"put","key":"a","value":"` + strings.Repeat("v", 1024) + `"}`},
+	} {
+		body, contentType := batchBody(false, operations...)
+		recorder := serve(router, http.MethodPost, "/batch", strings.NewReader(body), "Content-Type", contentType)
+		if recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("batch over the limit returned %d", recorder.Code)
+		}
+	}
+	if _, err := tree.Get([]byte("a")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("batch over the limit was applied")
+	}
+	for i, ndjson := range []bool{false, true} {
+		body, contentType := batchBody(ndjson, append(valid, fmt.Sprintf(`{"op":"delete","key":"%d"}`, i))...)
+		var response map[string]int
+		decodeResponse(t, serve(router, http.MethodPost, "/batch", strings.NewReader(body), "Content-Type", contentType), http.StatusOK, &response)
+		if response["operations"] != 3 {
+			t.Fatalf("valid batch returned %v", response)
+		}
+	}
+	if value, err := tree.Get([]byte("a")); err != nil || string(value) != "1" {
+		t.Fatalf("valid batch wasn't applied, got %q %v", value, err)
+	}
+	if value, err := users.Get([]byte("b")); err != nil || string(value) != "2" {
+		t.Fatalf("valid batch wasn't applied to the family, got %q %v", value, err)
+	}
+}
+
+func TestBatchGetHandler_Found(t *testing.T) {
+	tree, router := newTestRouter(t, Config{MaxBatchOperations: 4})
+	for key, value := range map[string]string{"a": "1", "empty": ""} {
+		entry := NewEntry([]byte(key), []byte(value))
+		if err := tree.Put(&entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users, _ := tree.ColumnFamily("users")
+	entry := NewEntry([]byte("b"), []byte("2"))
+	if err := users.Put(&entry); err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Results []KeyResult       `json:"results"`
+		Values  map[string]string `json:"values"`
+	}
+	body := `{"keys":["missing","a","empty","a"]}`
+	decodeResponse(t, serve(router, http.MethodPost, "/batch/get", strings.NewReader(body)), http.StatusOK, &response)
+	//an empty value is found,the flag tells it apart from a missing key
+	expected := []KeyResult{{Key: "missing"}, {Key: "a", Found: true, Value: "1"}, {Key: "empty", Found: true}, {Key: "a", Found: true, Value: "1"}}
+	if fmt.Sprint(response.Results) != fmt.Sprint(expected) {
+		t.Fatalf("results are %+v", response.Results)
+	}
+	if _, ok := response.Values["missing"]; ok || len(response.Values) != 2 || response.Values["a"] != "1" {
+		t.Fatalf("values have only found keys: %v", response.Values)
+	}
+	response.Results = nil
+	body = `{"family":"users","keys":["a","b"]}`
+	decodeResponse(t, serve(router, http.MethodPost, "/batch/get", strings.NewReader(body)), http.StatusOK, &response)
+	if fmt.Sprint(response.Results) != fmt.Sprint([]KeyResult{{Key: "a"}, {Key: "b", Found: true, Value: "2"}}) {
+		t.Fatalf("family results are %+v", response.Results)
+	}
+	//keys and values are base64 with the encoding
+	key := base64.StdEncoding.EncodeToString([]byte("a"))
+	body = `{"keys":["` + key + `"]}`
+	decodeResponse(t, serve(router, http.MethodPost, "/batch/get?encoding=base64", strings.NewReader(body)), http.StatusOK, &response)
+	if len(response.Results) != 1 || !response.Results[0].Found || response.Results[0].Value != base64.StdEncoding.EncodeToString([]byte("1")) {
+		t.Fatalf("base64 results are %+v", response.Results)
+	}
+	for body, status := range map[string]int{
+		`{"keys":["a","a","a","a","a"]}`:    http.StatusRequestEntityTooLarge,
+		`{"family":"missing","keys":["a"]}`: http.StatusNotFound,
+		`{"family":"users"}`:                http.StatusBadRequest,
+	} {
+		if recorder := serve(router, http.MethodPost, "/batch/get", strings.NewReader(body)); recorder.Code != status {
+			t.Fatalf("%s returned %d instead of %d", body, recorder.Code, status)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/tsandl/go-wiskey-update/api"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"net"
+)
+
+//The api.Wiskey service on the tree,it behaves as the matching http endpoints
+type grpcServer struct {
+	api.UnimplementedWiskeyServer
+	lsm    *LsmTree
+	config Config
+}
+
+//Listen on the address and serve the api in background,it panics if the address can't be used
+func startGrpc(lsm *LsmTree, config Config) {
+	listener, err := net.Listen("tcp", config.GrpcAddress)
+	if err != nil {
+		panic(err)
+	}
+	server := newGrpcServer(lsm, config)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			panic(err)
+		}
+	}()
+}
+
+func newGrpcServer(lsm *LsmTree, config Config) *grpc.Server {
+	//values can be as big as the ones of PUT /kv/{key}
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(maxValueSize + 1024))
+	api.RegisterWiskeyServer(server, &grpcServer{lsm: lsm, config: config})
+	return server
+}
+
+//Map storage errors to grpc codes
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrKeyTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+//Find the column family,empty name is the default family
+func (server *grpcServer) family(name string) (*LsmTree, error) {
+	family, ok := server.lsm.ColumnFamily(name)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "column family %s doesn't exist", name)
+	}
+	return family, nil
+}
+
+func (server *grpcServer) Get(ctx context.Context, request *api.GetRequest) (*api.GetResponse, error) {
+	family, err := server.family(request.Family)
+	if err != nil {
+		return nil, err
+	}
+	value, err := family.Get(request.Key)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &api.GetResponse{Value: value}, nil
+}
+
+func (server *grpcServer) Put(ctx context.Context, request *api.PutRequest) (*api.PutResponse, error) {
+	family, err := server.family(request.Family)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Key) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "key can't be empty")
+	}
+	if family == server.lsm {
+		err = putValue(server.lsm, request.Key, request.Value, "")
+	} else {
+		entry := NewEntry(request.Key, request.Value)
+		err = family.Put(&entry)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &api.PutResponse{}, nil
+}
+
+func (server *grpcServer) Delete(ctx context.Context, request *api.DeleteRequest) (*api.DeleteResponse, error) {
+	family, err := server.family(request.Family)
+	if err != nil {
+		return nil, err
+	}
+	if family == server.lsm {
+		err = deleteValue(server.lsm, request.Key)
+	} else {
+		err = family.Delete(request.Key)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &api.DeleteResponse{}, nil
+}
+
+//The same limits as POST /batch,the size is the size of the encoded request
+func (server *grpcServer) BatchWrite(ctx context.Context, request *api.BatchWriteRequest) (*api.BatchWriteResponse, error) {
+	config := server.config
+	if config.MaxBatchOperations != 0 && len(request.Operations) > config.MaxBatchOperations {
+		return nil, status.Errorf(codes.ResourceExhausted, "batch has more than %d operations", config.MaxBatchOperations)
+	}
+	if config.MaxBatchBytes != 0 && proto.Size(request) > config.MaxBatchBytes {
+		return nil, status.Errorf(codes.ResourceExhausted, "batch is bigger than %d bytes", config.MaxBatchBytes)
+	}
+	batch := NewWriteBatch()
+	for i, operation := range request.Operations {
+		family, ok := server.lsm.ColumnFamily(operation.Family)
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "operation %d: column family %s doesn't exist", i, operation.Family)
+		}
+		if len(operation.Key) == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "operation %d: key can't be empty", i)
+		}
+		switch operation.Type {
+		case api.Operation_PUT:
+			batchChange(server.lsm, batch, family, operation.Family, operation.Key, operation.Value, false)
+		case api.Operation_DELETE:
+			batchChange(server.lsm, batch, family, operation.Family, operation.Key, nil, true)
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "operation %d: unknown type %v", i, operation.Type)
+		}
+	}
+	if err := server.lsm.Write(batch); err != nil {
+		return nil, grpcError(err)
+	}
+	return &api.BatchWriteResponse{Operations: int64(len(request.Operations))}, nil
+}
+
+//Stream the scan page by page as GET /scan with ndjson
+func (server *grpcServer) Scan(request *api.ScanRequest, stream api.Wiskey_ScanServer) error {
+	family, err := server.family(request.Family)
+	if err != nil {
+		return err
+	}
+	if request.Limit < 0 {
+		return status.Error(codes.InvalidArgument, "limit has to be a positive number")
+	}
+	options := ScanOptions{Start: request.Start, Prefix: request.Prefix, Reverse: request.Reverse}
+	//empty end means there is no bound
+	if len(request.End) != 0 {
+		options.End = request.End
+	}
+	limited, left := request.Limit != 0, int(request.Limit)
+	for {
+		options.Limit = scanPageSize
+		if limited && left < scanPageSize {
+			options.Limit = left
+		}
+		result, err := family.Scan(options)
+		if err != nil {
+			return grpcError(err)
+		}
+		for _, entry := range result.Entries {
+			if err := stream.Send(&api.KeyValue{Key: entry.Key, Value: entry.Value}); err != nil {
+				return err
+			}
+		}
+		left -= len(result.Entries)
+		if result.Next == nil || (limited && left == 0) {
+			return nil
+		}
+		options = *result.Next
+	}
+}
+
+//Send changes until the client cancels the stream or the watcher stops
+func (server *grpcServer) Watch(request *api.WatchRequest, stream api.Wiskey_WatchServer) error {
+	family, err := server.family(request.Family)
+	if err != nil {
+		return err
+	}
+	watcher, err := family.Watch(request.Prefix)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer watcher.Close()
+	//headers are sent once the watcher is registered,so the client knows that following writes are sent
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-watcher.Events():
+			if !ok {
+				if errors.Is(watcher.Err(), ErrWatcherLagged) {
+					return status.Error(codes.ResourceExhausted, watcher.Err().Error())
+				}
+				return grpcError(ErrClosed)
+			}
+			if err := stream.Send(watchEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func watchEvent(event WatchEvent) *api.WatchEvent {
+	switch {
+	case event.End != nil:
+		return &api.WatchEvent{Type: api.WatchEvent_DELETE_RANGE, Key: event.Key, End: event.End}
+	case event.Deleted:
+		return &api.WatchEvent{Type: api.WatchEvent_DELETE, Key: event.Key}
+	default:
+		return &api.WatchEvent{Type: api.WatchEvent_PUT, Key: event.Key, Value: event.Value}
+	}
+}
+
+func (server *grpcServer) Stats(ctx context.Context, request *api.StatsRequest) (*api.StatsResponse, error) {
+	stats, err := server.lsm.Stats()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response := &api.StatsResponse{
+		Memtable:   memtableStats(stats.Memtable),
+		Levels:     levelStats(stats.Levels),
+		Families:   make(map[string]*api.FamilyStats),
+		Vlog:       &api.VlogStats{Size: stats.Vlog.Size, Head: stats.Vlog.Head, Tail: stats.Vlog.Tail, SampledBytes: stats.Vlog.SampledBytes, GarbageRatio: stats.Vlog.GarbageRatio},
+		Flush:      workStats(stats.Flush),
+		Compaction: workStats(stats.Compaction),
+		Gc:         workStats(stats.Gc),
+		WriteStall: workStats(stats.WriteStall),
+		BlockCache: cacheUsage(stats.BlockCache),
+		ValueCache: cacheUsage(stats.ValueCache),
+		Operations: make(map[string]*api.OperationStats),
+	}
+	for name, family := range stats.Families {
+		response.Families[name] = &api.FamilyStats{Memtable: memtableStats(family.Memtable), Levels: levelStats(family.Levels)}
+	}
+	for name, operation := range stats.Operations {
+		response.Operations[name] = operationStats(operation)
+	}
+	return response, nil
+}
+
+func memtableStats(stats MemtableStats) *api.MemtableStats {
+	return &api.MemtableStats{Entries: int64(stats.Entries), Bytes: int64(stats.Bytes)}
+}
+
+func levelStats(levels []LevelStats) []*api.LevelStats {
+	result := make([]*api.LevelStats, len(levels))
+	for i, level := range levels {
+		result[i] = &api.LevelStats{Level: int64(level.Level), Tables: int64(level.Tables), Bytes: level.Bytes}
+	}
+	return result
+}
+
+func workStats(stats WorkStats) *api.WorkStats {
+	return &api.WorkStats{Runs: stats.Runs, ReadBytes: stats.ReadBytes, WrittenBytes: stats.WrittenBytes, DurationNs: int64(stats.Duration)}
+}
+
+func cacheUsage(usage CacheUsage) *api.CacheUsage {
+	return &api.CacheUsage{Hits: usage.Hits, Misses: usage.Misses, Size: int64(usage.Size), Capacity: int64(usage.Capacity), HitRate: usage.HitRate}
+}
+
+func operationStats(stats OperationStats) *api.OperationStats {
+	result := &api.OperationStats{Count: stats.Count, DurationNs: int64(stats.Duration), P50Ns: int64(stats.P50), P99Ns: int64(stats.P99), P999Ns: int64(stats.P999)}
+	for _, bucket := range stats.Histogram {
+		result.Histogram = append(result.Histogram, &api.LatencyBucket{LeNs: int64(bucket.UpperBound), Count: bucket.Count})
+	}
+	return result
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/tsandl/go-wiskey-update/api"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+)
+
+//Server on a random port of localhost with an in memory tree
+func startTestGrpc(t *testing.T) (*LsmTree, api.WiskeyClient) {
+	db, err := Open(Options{Dir: "/data", FS: NewMemFS(), MemtableSize: 1000, CompactionStrategy: NoCompaction, Logger: log.New(ioutil.Discard, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{ContentTypeFamily, "users"} {
+		if _, err := db.CreateColumnFamily(name, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newGrpcServer(db.LsmTree, Config{MaxBatchOperations: 100})
+	go server.Serve(listener)
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+		db.Close()
+	})
+	return db.LsmTree, api.NewWiskeyClient(conn)
+}
+
+func checkCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Fatalf("expected %v,got %v", code, err)
+	}
+}
+
+func TestGrpcServer_GetPut(t *testing.T) {
+	ctx := context.Background()
+	_, client := startTestGrpc(t)
+	for _, family := range []string{"", "users"} {
+		if _, err := client.Put(ctx, &api.PutRequest{Family: family, Key: []byte("anita"), Value: []byte("Developer")}); err != nil {
+			t.Fatal(err)
+		}
+		response, err := client.Get(ctx, &api.GetRequest{Family: family, Key: []byte("anita")})
+		if err != nil || string(response.GetValue()) != "Developer" {
+			t.Fatalf("get from family %q returned %v %v", family, response, err)
+		}
+		_, err = client.Get(ctx, &api.GetRequest{Family: family, Key: []byte("missing")})
+		checkCode(t, err, codes.NotFound)
+		if _, err := client.Delete(ctx, &api.DeleteRequest{Family: family, Key: []byte("anita")}); err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Get(ctx, &api.GetRequest{Family: family, Key: []byte("anita")})
+		checkCode(t, err, codes.NotFound)
+	}
+	//binary keys and values are kept as they are
+	binary := []byte{0, 0xff, '\n', 0x80}
+	if _, err := client.Put(ctx, &api.PutRequest{Key: binary, Value: binary}); err != nil {
+		t.Fatal(err)
+	}
+	if response, err := client.Get(ctx, &api.GetRequest{Key: binary}); err != nil || !bytes.Equal(response.GetValue(), binary) {
+		t.Fatalf("binary value returned %v %v", response, err)
+	}
+	_, err := client.Put(ctx, &api.PutRequest{Value: []byte("Developer")})
+	checkCode(t, err, codes.InvalidArgument)
+	_, err = client.Get(ctx, &api.GetRequest{Family: "missing", Key: []byte("anita")})
+	checkCode(t, err, codes.NotFound)
+}
+
+//Read the whole stream,the error of the stream is returned
+func scanKeys(client api.WiskeyClient, request *api.ScanRequest) ([]string, error) {
+	stream, err := client.Scan(context.Background(), request)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for {
+		pair, err := stream.Recv()
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return keys, err
+		}
+		if string(pair.Value) != "value"+string(pair.Key) {
+			return keys, fmt.Errorf("key %s has value %s", pair.Key, pair.Value)
+		}
+		keys = append(keys, string(pair.Key))
+	}
+}
+
+func TestGrpcServer_Scan(t *testing.T) {
+	tree, client := startTestGrpc(t)
+	//more keys than a page,so the stream continues from the next page
+	count := scanPageSize + 500
+	batch := NewWriteBatch()
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("key%05d", i)
+		batch.Put("", []byte(key), []byte("value"+key))
+	}
+	if err := tree.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := scanKeys(client, &api.ScanRequest{})
+	if err != nil || len(keys) != count {
+		t.Fatalf("full scan returned %d keys %v", len(keys), err)
+	}
+	for i, key := range keys {
+		if key != fmt.Sprintf("key%05d", i) {
+			t.Fatalf("key %d is %s", i, key)
+		}
+	}
+	keys, err = scanKeys(client, &api.ScanRequest{Limit: scanPageSize + 10, Reverse: true})
+	if err != nil || len(keys) != scanPageSize+10 || keys[0] != fmt.Sprintf("key%05d", count-1) {
+		t.Fatalf("reverse scan with limit returned %d keys %v", len(keys), err)
+	}
+	keys, err = scanKeys(client, &api.ScanRequest{Start: []byte("key00010"), End: []byte("key00013")})
+	if err != nil || fmt.Sprint(keys) != "[key00010 key00011 key00012]" {
+		t.Fatalf("range scan returned %v %v", keys, err)
+	}
+	keys, err = scanKeys(client, &api.ScanRequest{Prefix: []byte("key0149")})
+	if err != nil || len(keys) != 10 {
+		t.Fatalf("prefix scan returned %v %v", keys, err)
+	}
+	_, err = scanKeys(client, &api.ScanRequest{Limit: -1})
+	checkCode(t, err, codes.InvalidArgument)
+	_, err = scanKeys(client, &api.ScanRequest{Family: "missing"})
+	checkCode(t, err, codes.NotFound)
+}
+
+func TestGrpcServer_Watch(t *testing.T) {
+	tree, client := startTestGrpc(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &api.WatchRequest{Prefix: []byte("user/")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//wait until the watcher is registered
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Put(ctx, &api.PutRequest{Key: []byte("other"), Value: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Put(ctx, &api.PutRequest{Key: []byte("user/anita"), Value: []byte("Developer")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Delete(ctx, &api.DeleteRequest{Key: []byte("user/anita")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.DeleteRange([]byte("user/a"), []byte("user/z")); err != nil {
+		t.Fatal(err)
+	}
+	expected := []*api.WatchEvent{
+		{Type: api.WatchEvent_PUT, Key: []byte("user/anita"), Value: []byte("Developer")},
+		{Type: api.WatchEvent_DELETE, Key: []byte("user/anita")},
+		{Type: api.WatchEvent_DELETE_RANGE, Key: []byte("user/a"), End: []byte("user/z")},
+	}
+	for i, want := range expected {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != want.Type || !bytes.Equal(event.Key, want.Key) || !bytes.Equal(event.Value, want.Value) || !bytes.Equal(event.End, want.End) {
+			t.Fatalf("event %d is %v,expected %v", i, event, want)
+		}
+	}
+	cancel()
+	_, err = stream.Recv()
+	checkCode(t, err, codes.Canceled)
+}
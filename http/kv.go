@@ -0,0 +1,199 @@
+package http
+
+import (
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	//content types of values written by PUT /kv/*key,the family is written with the value in a single batch
+	ContentTypeFamily = "_content_type"
+	//deadlines of keys set with EX or PX by redis clients in unix milliseconds
+	ExpireFamily       = "_expire"
+	defaultContentType = "application/octet-stream"
+	maxValueSize       = 64 << 20 //of a raw body
+	base64Encoding     = "base64"
+)
+
+//Values in json are strings,with ?encoding=base64 they are base64 strings so binary values can round-trip
+func jsonEncoding(c *gin.Context) (string, bool) {
+	encoding := c.Query("encoding")
+	if encoding != "" && encoding != base64Encoding {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "encoding has to be base64"})
+		return "", false
+	}
+	return encoding, true
+}
+
+func encodeValue(encoding string, value []byte) string {
+	if encoding == base64Encoding {
+		return base64.StdEncoding.EncodeToString(value)
+	}
+	return string(value)
+}
+
+//Responds with 400 if the value isn't valid base64,keys of /batch/get are decoded the same way
+func decodeValue(c *gin.Context, encoding string, value string) ([]byte, bool) {
+	decoded, err := decodeString(encoding, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid base64: " + err.Error()})
+		return nil, false
+	}
+	return decoded, true
+}
+
+func decodeString(encoding string, value string) ([]byte, error) {
+	if encoding != base64Encoding {
+		return []byte(value), nil
+	}
+	return base64.StdEncoding.DecodeString(value)
+}
+
+//Add the value with its content type to the batch,values without a content type remove the old one
+//the deadline of the old value is removed as well,tombstones are written only for existing ones
+func batchPut(lsm *LsmTree, batch *WriteBatch, key []byte, value []byte, contentType string) {
+	batch.Put("", key, value)
+	if _, ok := lsm.ColumnFamily(ContentTypeFamily); ok {
+		if contentType == "" {
+			batch.DeleteExisting(ContentTypeFamily, key)
+		} else {
+			batch.Put(ContentTypeFamily, key, []byte(contentType))
+		}
+	}
+	if _, ok := lsm.ColumnFamily(ExpireFamily); ok {
+		batch.DeleteExisting(ExpireFamily, key)
+	}
+}
+
+//Add the delete of the value with its content type and deadline to the batch
+func batchDelete(lsm *LsmTree, batch *WriteBatch, key []byte) {
+	batch.Delete("", key)
+	for _, name := range []string{ContentTypeFamily, ExpireFamily} {
+		if _, ok := lsm.ColumnFamily(name); ok {
+			batch.DeleteExisting(name, key)
+		}
+	}
+}
+
+//Add the delete of the value with its content type and deadline to the batch,
+//only the existing ones get tombstones
+func batchDeleteExisting(lsm *LsmTree, batch *WriteBatch, key []byte) {
+	batch.DeleteExisting("", key)
+	for _, name := range []string{ContentTypeFamily, ExpireFamily} {
+		if _, ok := lsm.ColumnFamily(name); ok {
+			batch.DeleteExisting(name, key)
+		}
+	}
+}
+
+//Add the deadline of the key to the batch,it has to go after the put of the value
+func batchExpire(batch *WriteBatch, key []byte, deadline int64) {
+	batch.Put(ExpireFamily, key, []byte(strconv.FormatInt(deadline, 10)))
+}
+
+//Save the value with its content type
+func putValue(lsm *LsmTree, key []byte, value []byte, contentType string) error {
+	batch := NewWriteBatch()
+	batchPut(lsm, batch, key, value, contentType)
+	return lsm.Write(batch)
+}
+
+//Delete the value with its content type
+func deleteValue(lsm *LsmTree, key []byte) error {
+	batch := NewWriteBatch()
+	batchDelete(lsm, batch, key)
+	return lsm.Write(batch)
+}
+
+//Key of /kv/*key,slashes are part of the key and the rest of the path is already unescaped
+func kvKey(c *gin.Context) ([]byte, bool) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key can't be empty"})
+		return nil, false
+	}
+	return []byte(key), true
+}
+
+//Save the raw body,its content type is returned by GET
+func putRaw(lsm *LsmTree) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := kvKey(c)
+		if !ok {
+			return
+		}
+		//the whole header is kept,so parameters like charset are returned as well
+		contentType := c.GetHeader("Content-Type")
+		if contentType != "" {
+			if _, _, err := mime.ParseMediaType(contentType); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid content type: " + err.Error()})
+				return
+			}
+		}
+		//GET returns the default type for values without a content type
+		if contentType == defaultContentType {
+			contentType = ""
+		}
+		value, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, maxValueSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(value) > maxValueSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "value is bigger than " + strconv.Itoa(maxValueSize) + " bytes"})
+			return
+		}
+		err = putValue(lsm, key, value, contentType)
+		if err != nil {
+			respondWithError(c, err)
+		} else {
+			c.Status(http.StatusNoContent)
+		}
+	}
+}
+
+//Return the value verbatim with the content type it was saved with
+func getRaw(lsm *LsmTree) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := kvKey(c)
+		if !ok {
+			return
+		}
+		//both are read under one lock,so a concurrent put can't mix its content type with the old value
+		values, found, err := lsm.GetFromFamilies(key, []string{"", ContentTypeFamily})
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		if !found[0] {
+			respondWithError(c, ErrNotFound)
+			return
+		}
+		contentType := defaultContentType
+		if found[1] {
+			contentType = string(values[1])
+		}
+		c.Data(http.StatusOK, contentType, values[0])
+	}
+}
+
+func deleteRaw(lsm *LsmTree) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := kvKey(c)
+		if !ok {
+			return
+		}
+		if err := deleteValue(lsm, key); err != nil {
+			respondWithError(c, err)
+		} else {
+			c.Status(http.StatusNoContent)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestKv_RawRoundTrip(t *testing.T) {
+	_, router := newTestRouter(t, Config{})
+	value := make([]byte, 256)
+	for i := range value {
+		value[i] = byte(i)
+	}
+	//slashes are part of the key
+	if recorder := serve(router, http.MethodPut, "/kv/dir/file.bin", bytes.NewReader(value)); recorder.Code != http.StatusNoContent {
+		t.Fatalf("put returned %d %s", recorder.Code, recorder.Body)
+	}
+	recorder := serve(router, http.MethodGet, "/kv/dir/file.bin", nil)
+	if recorder.Code != http.StatusOK || !bytes.Equal(recorder.Body.Bytes(), value) {
+		t.Fatalf("get returned %d %q", recorder.Code, recorder.Body)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != defaultContentType {
+		t.Fatalf("value without a content type returned %s", contentType)
+	}
+	//an empty body is a value as well
+	serve(router, http.MethodPut, "/kv/empty", nil)
+	if recorder := serve(router, http.MethodGet, "/kv/empty", nil); recorder.Code != http.StatusOK || recorder.Body.Len() != 0 {
+		t.Fatalf("empty value returned %d %q", recorder.Code, recorder.Body)
+	}
+	if recorder := serve(router, http.MethodGet, "/kv/dir", nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("missing key returned %d", recorder.Code)
+	}
+	if recorder := serve(router, http.MethodPut, "/kv/", strings.NewReader("value")); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("empty key returned %d", recorder.Code)
+	}
+	if recorder := serve(router, http.MethodDelete, "/kv/dir/file.bin", nil); recorder.Code != http.StatusNoContent {
+		t.Fatalf("delete returned %d", recorder.Code)
+	}
+	if recorder := serve(router, http.MethodGet, "/kv/dir/file.bin", nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("deleted key returned %d", recorder.Code)
+	}
+}
+
+func TestKv_ContentType(t *testing.T) {
+	tree, router := newTestRouter(t, Config{})
+	contentTypeOf := func(key string) string {
+		recorder := serve(router, http.MethodGet, "/kv/"+key, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("get of %s returned %d", key, recorder.Code)
+		}
+		return recorder.Header().Get("Content-Type")
+	}
+	//the whole header is kept with its parameters
+	serve(router, http.MethodPut, "/kv/page", strings.NewReader("<p>hi</p>"), "Content-Type", "text/html; charset=utf-8")
+	if contentType := contentTypeOf("page"); contentType != "text/html; charset=utf-8" {
+		t.Fatalf("stored content type is %s", contentType)
+	}
+	stored, ok := tree.ColumnFamily(ContentTypeFamily)
+	if !ok {
+		t.Fatal("content type family is missing")
+	}
+	if value, err := stored.Get([]byte("page")); err != nil || string(value) != "text/html; charset=utf-8" {
+		t.Fatalf("content type family has %q %v", value, err)
+	}
+	//a put without a content type removes the old one
+	serve(router, http.MethodPut, "/kv/page", strings.NewReader("plain"))
+	if contentType := contentTypeOf("page"); contentType != defaultContentType {
+		t.Fatalf("content type after put without it is %s", contentType)
+	}
+	serve(router, http.MethodPut, "/kv/page", strings.NewReader("{}"), "Content-Type", "application/json")
+	if recorder := serve(router, http.MethodPost, "/page", strings.NewReader(`{"value":"json"}`)); recorder.Code != http.StatusAccepted {
+		t.Fatalf("post returned %d", recorder.Code)
+	}
+	if contentType := contentTypeOf("page"); contentType != defaultContentType {
+		t.Fatalf("content type after POST /:key is %s", contentType)
+	}
+	if recorder := serve(router, http.MethodPut, "/kv/page", strings.NewReader("x"), "Content-Type", "text/"); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("invalid content type returned %d", recorder.Code)
+	}
+	serve(router, http.MethodPut, "/kv/page", strings.NewReader("x"), "Content-Type", "text/plain")
+	serve(router, http.MethodDelete, "/kv/page", nil)
+	if _, err := stored.Get([]byte("page")); err == nil {
+		t.Fatal("content type wasn't deleted with the value")
+	}
+}
+
+func TestKv_Base64Json(t *testing.T) {
+	_, router := newTestRouter(t, Config{})
+	binary := []byte{0, 0xff, '"', '\n', 0x80}
+	encoded := base64.StdEncoding.EncodeToString(binary)
+	body := `{"value":"` + encoded + `"}`
+	for _, paths := range [][2]string{{"/bin", "/fetch/bin"}, {"/cf/users/bin", "/cf/users/bin"}} {
+		if recorder := serve(router, http.MethodPost, paths[0]+"?encoding=base64", strings.NewReader(body)); recorder.Code != http.StatusAccepted {
+			t.Fatalf("post to %s returned %d %s", paths[0], recorder.Code, recorder.Body)
+		}
+		var response map[string]string
+		decodeResponse(t, serve(router, http.MethodGet, paths[1]+"?encoding=base64", nil), http.StatusOK, &response)
+		if response["value"] != encoded {
+			t.Fatalf("%s returned %q instead of %q", paths[1], response["value"], encoded)
+		}
+		//without the encoding the value is a string,invalid utf-8 can't round-trip
+		decodeResponse(t, serve(router, http.MethodGet, paths[1], nil), http.StatusOK, &response)
+		if response["value"] == encoded || []byte(response["value"])[0] != 0 {
+			t.Fatalf("%s without encoding returned %q", paths[1], response["value"])
+		}
+	}
+	//the raw value is the decoded one
+	if recorder := serve(router, http.MethodGet, "/kv/bin", nil); !bytes.Equal(recorder.Body.Bytes(), binary) {
+		t.Fatalf("raw value is %q", recorder.Body)
+	}
+	if recorder := serve(router, http.MethodPost, "/bin?encoding=base64", strings.NewReader(`{"value":"%%%"}`)); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("invalid base64 returned %d", recorder.Code)
+	}
+	if recorder := serve(router, http.MethodGet, "/fetch/bin?encoding=hex", nil); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("unknown encoding returned %d", recorder.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Requests of the http server by route,every route is a pattern like /cf/:name/:key
+type httpMetrics struct {
+	mutex     sync.Mutex
+	requests  map[requestKey]uint64
+	latencies map[routeKey]*LatencyHistogram
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type requestKey struct {
+	routeKey
+	status int
+}
+
+func newHttpMetrics() *httpMetrics {
+	return &httpMetrics{requests: make(map[requestKey]uint64), latencies: make(map[routeKey]*LatencyHistogram)}
+}
+
+//Count requests and their latency
+func (metrics *httpMetrics) middleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	route := routeKey{method: c.Request.Method, route: c.FullPath()}
+	//paths without a route aren't kept one by one,so random paths can't grow the map
+	if route.route == "" {
+		route.route = "unmatched"
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.requests[requestKey{routeKey: route, status: c.Writer.Status()}]++
+	histogram, ok := metrics.latencies[route]
+	if !ok {
+		histogram = &LatencyHistogram{}
+		metrics.latencies[route] = histogram
+	}
+	histogram.Record(time.Since(start))
+}
+
+func (metrics *httpMetrics) write(writer *MetricsWriter) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	requests := make([]requestKey, 0, len(metrics.requests))
+	for key := range metrics.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].routeKey != requests[j].routeKey {
+			return requests[i].routeKey.less(requests[j].routeKey)
+		}
+		return requests[i].status < requests[j].status
+	})
+	for _, key := range requests {
+		writer.Counter("wiskey_http_requests_total", "Http requests by route and status", float64(metrics.requests[key]),
+			"method", key.method, "route", key.route, "status", strconv.Itoa(key.status))
+	}
+	routes := make([]routeKey, 0, len(metrics.latencies))
+	for key := range metrics.latencies {
+		routes = append(routes, key)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].less(routes[j])
+	})
+	for _, key := range routes {
+		writer.Histogram("wiskey_http_request_duration_seconds", "Latency of http requests by route", metrics.latencies[key].Snapshot(),
+			"method", key.method, "route", key.route)
+	}
+}
+
+func (key routeKey) less(other routeKey) bool {
+	if key.route != other.route {
+		return key.route < other.route
+	}
+	return key.method < other.method
+}
+
+//Engine and http metrics in the Prometheus text format
+func (metrics *httpMetrics) handler(lsm *LsmTree) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := lsm.Stats()
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", MetricsContentType)
+		writer := NewMetricsWriter(c.Writer)
+		stats.WriteMetrics(writer)
+		metrics.write(writer)
+		if err := writer.Flush(); err != nil {
+			c.Error(err)
+		}
+	}
+}
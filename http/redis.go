@@ -0,0 +1,812 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	redisVersion     = "7.0.0" //clients check it before they use newer commands
+	defaultScanCount = 10
+	maxScanCursors   = 1024 //the oldest cursor is forgotten when there are more of them
+	expireInterval   = time.Second
+)
+
+//Subset of redis commands on the default family,values are plain strings
+//keys with EX or PX get a deadline in ExpireFamily,expired keys aren't returned
+//and they are deleted in background page by page
+type redisServer struct {
+	lsm    *LsmTree
+	config Config
+	//serializes writes of redis clients,so NX,XX and INCR are atomic between them
+	//it also guards the page of deadlines that is checked next
+	mutex        sync.Mutex
+	expireCursor []byte
+	cursorMutex  sync.Mutex
+	cursors      map[uint64][]byte //start of the next page of SCAN
+	cursorIds    []uint64          //in the order they were created
+	nextCursor   uint64
+	started      time.Time
+	clients      int64  //connected right now
+	connections  uint64 //since start
+	commands     uint64
+	expired      uint64        //keys deleted in background
+	done         chan struct{} //closed by stop
+	stopOnce     sync.Once
+}
+
+//Client of the server,commands of a single connection are run one by one
+type redisConnection struct {
+	id     uint64
+	reader *respReader
+	writer *respWriter
+	quit   bool
+}
+
+type redisCommand struct {
+	arity int //amount of arguments with the name of the command,negative is the minimum
+	run   func(server *redisServer, conn *redisConnection, args [][]byte)
+}
+
+var redisCommands = map[string]redisCommand{
+	"ping":   {-1, (*redisServer).ping},
+	"hello":  {-1, (*redisServer).hello},
+	"select": {2, (*redisServer).selectDb},
+	"quit":   {1, (*redisServer).quit},
+	"info":   {-1, (*redisServer).info},
+	"get":    {2, (*redisServer).get},
+	"set":    {-3, (*redisServer).set},
+	"del":    {-2, (*redisServer).del},
+	"exists": {-2, (*redisServer).exists},
+	"mget":   {-2, (*redisServer).mget},
+	"mset":   {-3, (*redisServer).mset},
+	"incr":   {2, (*redisServer).incr},
+	"scan":   {-2, (*redisServer).scan},
+}
+
+func newRedisServer(lsm *LsmTree, config Config) *redisServer {
+	return &redisServer{lsm: lsm, config: config, cursors: make(map[uint64][]byte), started: time.Now(), done: make(chan struct{})}
+}
+
+//Listen on the address and serve redis clients in background,it panics if the address can't be used
+func startRedis(lsm *LsmTree, config Config) {
+	listener, err := net.Listen("tcp", config.RedisAddress)
+	if err != nil {
+		panic(err)
+	}
+	server := newRedisServer(lsm, config)
+	go server.expireKeys()
+	go func() {
+		if err := server.serve(listener); err != nil {
+			panic(err)
+		}
+	}()
+}
+
+//Accept clients until the listener is closed
+func (server *redisServer) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			//for example too many open files,the client can connect again later
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go server.handle(conn)
+	}
+}
+
+//Answers are sent once all pipelined commands that were received are run
+func (server *redisServer) handle(netConn net.Conn) {
+	defer netConn.Close()
+	atomic.AddInt64(&server.clients, 1)
+	defer atomic.AddInt64(&server.clients, -1)
+	conn := &redisConnection{
+		id:     atomic.AddUint64(&server.connections, 1),
+		reader: newRespReader(netConn),
+		writer: newRespWriter(netConn),
+	}
+	for !conn.quit {
+		args, err := conn.reader.readCommand()
+		if errors.Is(err, errProtocol) {
+			conn.writer.error("ERR " + err.Error())
+			conn.writer.flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(args) != 0 {
+			server.execute(conn, args)
+		}
+		if conn.reader.drained() || conn.quit {
+			if err := conn.writer.flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (server *redisServer) execute(conn *redisConnection, args [][]byte) {
+	atomic.AddUint64(&server.commands, 1)
+	name := strings.ToLower(string(args[0]))
+	command, ok := redisCommands[name]
+	if !ok {
+		var prefix strings.Builder
+		for _, arg := range args[1:] {
+			prefix.WriteString("'" + string(arg) + "' ")
+		}
+		conn.writer.error(fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", args[0], prefix.String()))
+		return
+	}
+	if (command.arity > 0 && len(args) != command.arity) || len(args) < -command.arity {
+		conn.writer.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	command.run(server, conn, args)
+}
+
+func (conn *redisConnection) fail(err error) {
+	conn.writer.error("ERR " + err.Error())
+}
+
+func (conn *redisConnection) syntaxError() {
+	conn.writer.error("ERR syntax error")
+}
+
+//Responds with an error if the command has more keys than a batch
+func (server *redisServer) tooManyKeys(conn *redisConnection, keys int) bool {
+	if server.config.MaxBatchOperations != 0 && keys > server.config.MaxBatchOperations {
+		conn.writer.error("ERR command has more than " + strconv.Itoa(server.config.MaxBatchOperations) + " keys")
+		return true
+	}
+	return false
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+//Deadlines of the keys in unix milliseconds,0 if the key doesn't expire
+func (server *redisServer) deadlines(keys [][]byte) ([]int64, error) {
+	deadlines := make([]int64, len(keys))
+	family, ok := server.lsm.ColumnFamily(ExpireFamily)
+	if !ok {
+		return deadlines, nil
+	}
+	values, found, err := family.MultiGet(keys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if found[i] {
+			deadlines[i], _ = strconv.ParseInt(string(values[i]), 10, 64)
+		}
+	}
+	return deadlines, nil
+}
+
+func expired(deadline int64, now int64) bool {
+	return deadline != 0 && deadline <= now
+}
+
+//Values of the keys with their deadlines,expired keys aren't found
+func (server *redisServer) values(keys [][]byte) ([][]byte, []bool, []int64, error) {
+	values, found, err := server.lsm.MultiGet(keys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	deadlines, err := server.deadlines(keys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := nowMillis()
+	for i := range keys {
+		if found[i] && expired(deadlines[i], now) {
+			values[i], found[i] = nil, false
+		}
+	}
+	return values, found, deadlines, nil
+}
+
+//Delete a page of expired keys every interval until the server is stopped or the tree is closed
+func (server *redisServer) expireKeys() {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-server.done:
+			return
+		case <-ticker.C:
+		}
+		err := server.deleteExpired()
+		if errors.Is(err, ErrClosed) {
+			return
+		}
+		if err != nil {
+			fmt.Println("Redis expiration failed " + err.Error())
+		}
+	}
+}
+
+//Check the next page of deadlines and delete expired keys,so http clients don't see them either
+func (server *redisServer) deleteExpired() error {
+	family, ok := server.lsm.ColumnFamily(ExpireFamily)
+	if !ok {
+		return nil
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	result, err := family.Scan(ScanOptions{Start: server.expireCursor, Limit: scanPageSize})
+	if err != nil {
+		return err
+	}
+	server.expireCursor = nil
+	if result.Next != nil {
+		server.expireCursor = result.Next.Start
+	}
+	now := nowMillis()
+	for _, entry := range result.Entries {
+		deadline, _ := strconv.ParseInt(string(entry.Value), 10, 64)
+		if !expired(deadline, now) {
+			continue
+		}
+		//http and grpc puts don't take the mutex,a put after the scan removes the deadline and the key stays
+		batch := NewWriteBatch()
+		batch.Require(ExpireFamily, entry.Key, entry.Value)
+		batchDeleteExisting(server.lsm, batch, entry.Key)
+		err := server.lsm.Write(batch)
+		if errors.Is(err, ErrConditionFailed) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		atomic.AddUint64(&server.expired, 1)
+	}
+	return nil
+}
+
+//Stop the expiration worker
+func (server *redisServer) stop() {
+	server.stopOnce.Do(func() {
+		close(server.done)
+	})
+}
+
+func (server *redisServer) ping(conn *redisConnection, args [][]byte) {
+	switch len(args) {
+	case 1:
+		conn.writer.simple("PONG")
+	case 2:
+		conn.writer.bulk(args[1])
+	default:
+		conn.writer.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+//Switch the protocol,HELLO 3 enables RESP3,authentication isn't supported so AUTH accepts anything
+func (server *redisServer) hello(conn *redisConnection, args [][]byte) {
+	protocol := conn.writer.protocol
+	if len(args) > 1 {
+		version, err := strconv.Atoi(string(args[1]))
+		if err != nil || (version != 2 && version != 3) {
+			conn.writer.error("NOPROTO unsupported protocol version")
+			return
+		}
+		protocol = version
+	}
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); {
+		case option == "AUTH" && i+2 < len(args):
+			i += 2
+		case option == "SETNAME" && i+1 < len(args):
+			i++
+		default:
+			conn.syntaxError()
+			return
+		}
+	}
+	conn.writer.protocol = protocol
+	conn.writer.dictionary(7)
+	conn.writer.bulk([]byte("server"))
+	conn.writer.bulk([]byte("redis"))
+	conn.writer.bulk([]byte("version"))
+	conn.writer.bulk([]byte(redisVersion))
+	conn.writer.bulk([]byte("proto"))
+	conn.writer.integer(int64(protocol))
+	conn.writer.bulk([]byte("id"))
+	conn.writer.integer(int64(conn.id))
+	conn.writer.bulk([]byte("mode"))
+	conn.writer.bulk([]byte("standalone"))
+	conn.writer.bulk([]byte("role"))
+	conn.writer.bulk([]byte("master"))
+	conn.writer.bulk([]byte("modules"))
+	conn.writer.array(0)
+}
+
+//There is a single database
+func (server *redisServer) selectDb(conn *redisConnection, args [][]byte) {
+	if string(args[1]) != "0" {
+		conn.writer.error("ERR DB index is out of range")
+		return
+	}
+	conn.writer.simple("OK")
+}
+
+func (server *redisServer) quit(conn *redisConnection, args [][]byte) {
+	conn.writer.simple("OK")
+	conn.quit = true
+}
+
+//Sections of the server,clients,counters and the engine,INFO without a section returns all of them
+func (server *redisServer) info(conn *redisConnection, args [][]byte) {
+	sections := make(map[string]bool)
+	for _, arg := range args[1:] {
+		sections[strings.ToLower(string(arg))] = true
+	}
+	all := len(sections) == 0 || sections["all"] || sections["default"] || sections["everything"]
+	var info strings.Builder
+	section := func(name string, fields ...interface{}) {
+		if !all && !sections[strings.ToLower(name)] {
+			return
+		}
+		if info.Len() != 0 {
+			info.WriteString("\r\n")
+		}
+		info.WriteString("# " + name + "\r\n")
+		for i := 0; i < len(fields); i += 2 {
+			info.WriteString(fmt.Sprintf("%s:%v\r\n", fields[i], fields[i+1]))
+		}
+	}
+	_, port, _ := net.SplitHostPort(server.config.RedisAddress)
+	section("Server",
+		"redis_version", redisVersion,
+		"redis_mode", "standalone",
+		"process_id", os.Getpid(),
+		"tcp_port", port,
+		"uptime_in_seconds", int64(time.Since(server.started)/time.Second))
+	section("Clients", "connected_clients", atomic.LoadInt64(&server.clients))
+	section("Stats",
+		"total_connections_received", atomic.LoadUint64(&server.connections),
+		"total_commands_processed", atomic.LoadUint64(&server.commands),
+		"expired_keys", atomic.LoadUint64(&server.expired))
+	if all || sections["wiskey"] {
+		stats, err := server.lsm.Stats()
+		if err != nil {
+			conn.fail(err)
+			return
+		}
+		tables, tablesBytes := 0, int64(0)
+		for _, level := range stats.Levels {
+			tables += level.Tables
+			tablesBytes += level.Bytes
+		}
+		section("Wiskey",
+			"memtable_entries", stats.Memtable.Entries,
+			"memtable_bytes", stats.Memtable.Bytes,
+			"sstables", tables,
+			"sstable_bytes", tablesBytes,
+			"vlog_bytes", stats.Vlog.Size,
+			"vlog_garbage_ratio", strconv.FormatFloat(stats.Vlog.GarbageRatio, 'f', 4, 64))
+	}
+	conn.writer.bulk([]byte(info.String()))
+}
+
+func (server *redisServer) get(conn *redisConnection, args [][]byte) {
+	values, found, _, err := server.values(args[1:])
+	if err != nil {
+		conn.fail(err)
+	} else if !found[0] {
+		conn.writer.null()
+	} else {
+		conn.writer.bulk(values[0])
+	}
+}
+
+//SET key value [NX|XX] [EX seconds|PX milliseconds],the deadline of the old value is removed
+func (server *redisServer) set(conn *redisConnection, args [][]byte) {
+	key, value := args[1], args[2]
+	var onlyNew, onlyExisting bool
+	var deadline int64
+	for i := 3; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); option {
+		case "NX":
+			onlyNew = true
+		case "XX":
+			onlyExisting = true
+		case "EX", "PX":
+			if deadline != 0 || i+1 == len(args) {
+				conn.syntaxError()
+				return
+			}
+			if _, ok := server.lsm.ColumnFamily(ExpireFamily); !ok {
+				conn.writer.error("ERR expiration requires the " + ExpireFamily + " column family")
+				return
+			}
+			i++
+			amount, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				conn.writer.error("ERR value is not an integer or out of range")
+				return
+			}
+			unit, now := int64(1), nowMillis()
+			if option == "EX" {
+				unit = 1000
+			}
+			if amount <= 0 || amount > (math.MaxInt64-now)/unit {
+				conn.writer.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			deadline = now + amount*unit
+		default:
+			conn.syntaxError()
+			return
+		}
+	}
+	if onlyNew && onlyExisting {
+		conn.syntaxError()
+		return
+	}
+	if len(key) == 0 {
+		conn.writer.error("ERR empty keys aren't supported")
+		return
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if onlyNew || onlyExisting {
+		_, found, _, err := server.values([][]byte{key})
+		if err != nil {
+			conn.fail(err)
+			return
+		}
+		if found[0] == onlyNew {
+			conn.writer.null()
+			return
+		}
+	}
+	batch := NewWriteBatch()
+	batchPut(server.lsm, batch, key, value, "")
+	if deadline != 0 {
+		batchExpire(batch, key, deadline)
+	}
+	if err := server.lsm.Write(batch); err != nil {
+		conn.fail(err)
+		return
+	}
+	conn.writer.simple("OK")
+}
+
+//Returns the amount of deleted keys that existed
+func (server *redisServer) del(conn *redisConnection, args [][]byte) {
+	keys := args[1:]
+	if server.tooManyKeys(conn, len(keys)) {
+		return
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	_, found, _, err := server.values(keys)
+	if err != nil {
+		conn.fail(err)
+		return
+	}
+	deleted := int64(0)
+	batch := NewWriteBatch()
+	seen := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		//repeated keys are deleted and counted once
+		if seen[string(key)] {
+			continue
+		}
+		seen[string(key)] = true
+		if found[i] {
+			deleted++
+		}
+		//missing keys get no tombstones,expired keys that weren't deleted yet are deleted as well
+		batchDeleteExisting(server.lsm, batch, key)
+	}
+	if err := server.lsm.Write(batch); err != nil {
+		conn.fail(err)
+		return
+	}
+	conn.writer.integer(deleted)
+}
+
+//Repeated keys are counted as many times as they are given
+func (server *redisServer) exists(conn *redisConnection, args [][]byte) {
+	if server.tooManyKeys(conn, len(args)-1) {
+		return
+	}
+	_, found, _, err := server.values(args[1:])
+	if err != nil {
+		conn.fail(err)
+		return
+	}
+	count := int64(0)
+	for _, ok := range found {
+		if ok {
+			count++
+		}
+	}
+	conn.writer.integer(count)
+}
+
+func (server *redisServer) mget(conn *redisConnection, args [][]byte) {
+	if server.tooManyKeys(conn, len(args)-1) {
+		return
+	}
+	values, found, _, err := server.values(args[1:])
+	if err != nil {
+		conn.fail(err)
+		return
+	}
+	conn.writer.array(len(values))
+	for i, value := range values {
+		if found[i] {
+			conn.writer.bulk(value)
+		} else {
+			conn.writer.null()
+		}
+	}
+}
+
+//All keys are saved in a single batch
+func (server *redisServer) mset(conn *redisConnection, args [][]byte) {
+	if len(args)%2 == 0 {
+		conn.writer.error("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+	if server.tooManyKeys(conn, len(args)/2) {
+		return
+	}
+	batch := NewWriteBatch()
+	for i := 1; i < len(args); i += 2 {
+		if len(args[i]) == 0 {
+			conn.writer.error("ERR empty keys aren't supported")
+			return
+		}
+		batchPut(server.lsm, batch, args[i], args[i+1], "")
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if err := server.lsm.Write(batch); err != nil {
+		conn.fail(err)
+		return
+	}
+	conn.writer.simple("OK")
+}
+
+//Read,increment and write the value,missing keys are 0 and the deadline is kept
+func (server *redisServer) incr(conn *redisConnection, args [][]byte) {
+	key := args[1]
+	if len(key) == 0 {
+		conn.writer.error("ERR empty keys aren't supported")
+		return
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	values, found, deadlines, err := server.values([][]byte{key})
+	if err != nil {
+		conn.fail(err)
+		return
+	}
+	number := int64(0)
+	if found[0] {
+		number, err = strconv.ParseInt(string(values[0]), 10, 64)
+		if err != nil {
+			conn.writer.error("ERR value is not an integer or out of range")
+			return
+		}
+	}
+	if number == math.MaxInt64 {
+		conn.writer.error("ERR increment or decrement would overflow")
+		return
+	}
+	number++
+	batch := NewWriteBatch()
+	batchPut(server.lsm, batch, key, []byte(strconv.FormatInt(number, 10)), "")
+	if found[0] && deadlines[0] != 0 {
+		batchExpire(batch, key, deadlines[0])
+	}
+	if err := server.lsm.Write(batch); err != nil {
+		conn.fail(err)
+		return
+	}
+	conn.writer.integer(number)
+}
+
+//SCAN cursor [MATCH pattern] [COUNT count] [TYPE type],COUNT keys are read from the tree per call,
+//so a call can return less keys than COUNT and even none of them,the cursor is 0 at the end
+func (server *redisServer) scan(conn *redisConnection, args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		conn.writer.error("ERR invalid cursor")
+		return
+	}
+	var pattern []byte
+	count := defaultScanCount
+	onlyStrings := true
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			conn.syntaxError()
+			return
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				conn.writer.error("ERR value is not an integer or out of range")
+				return
+			}
+			if count < 1 || count > maxScanLimit {
+				conn.syntaxError()
+				return
+			}
+		case "TYPE":
+			//all values are strings
+			onlyStrings = strings.EqualFold(string(args[i+1]), "string")
+		default:
+			conn.syntaxError()
+			return
+		}
+	}
+	options := ScanOptions{Prefix: patternPrefix(pattern), Limit: count}
+	if cursor != 0 {
+		start, ok := server.cursor(cursor)
+		if !ok {
+			conn.writer.error("ERR invalid cursor")
+			return
+		}
+		options.Start = start
+	}
+	result, err := server.lsm.Scan(options)
+	if err != nil {
+		conn.fail(err)
+		return
+	}
+	keys := make([][]byte, len(result.Entries))
+	for i, entry := range result.Entries {
+		keys[i] = entry.Key
+	}
+	deadlines, err := server.deadlines(keys)
+	if err != nil {
+		conn.fail(err)
+		return
+	}
+	var matched [][]byte
+	now := nowMillis()
+	for i, key := range keys {
+		if onlyStrings && !expired(deadlines[i], now) && (pattern == nil || matchPattern(pattern, key)) {
+			matched = append(matched, key)
+		}
+	}
+	next := uint64(0)
+	if result.Next != nil {
+		next = server.saveCursor(result.Next.Start)
+	}
+	conn.writer.array(2)
+	conn.writer.bulk([]byte(strconv.FormatUint(next, 10)))
+	conn.writer.array(len(matched))
+	for _, key := range matched {
+		conn.writer.bulk(key)
+	}
+}
+
+//Redis cursors are numbers,so the start of the next page is kept by the server
+func (server *redisServer) saveCursor(start []byte) uint64 {
+	server.cursorMutex.Lock()
+	defer server.cursorMutex.Unlock()
+	server.nextCursor++
+	id := server.nextCursor
+	server.cursors[id] = start
+	server.cursorIds = append(server.cursorIds, id)
+	if len(server.cursorIds) > maxScanCursors {
+		delete(server.cursors, server.cursorIds[0])
+		server.cursorIds = server.cursorIds[1:]
+	}
+	return id
+}
+
+func (server *redisServer) cursor(id uint64) ([]byte, bool) {
+	server.cursorMutex.Lock()
+	defer server.cursorMutex.Unlock()
+	start, ok := server.cursors[id]
+	return start, ok
+}
+
+//Keys that match the pattern start with its characters before the first wildcard
+func patternPrefix(pattern []byte) []byte {
+	for i, char := range pattern {
+		if char == '*' || char == '?' || char == '[' || char == '\\' {
+			return pattern[:i]
+		}
+	}
+	return pattern
+}
+
+//Glob style pattern of redis: * any characters,? a single character,[abc] [^abc] [a-z] character classes
+//and \ escapes the next character
+//a mismatch retries from the last star with one more character taken by it,so the match doesn't backtrack
+//further than that and takes at most len(pattern)*len(value) steps
+func matchPattern(pattern []byte, value []byte) bool {
+	p, v := 0, 0
+	starPattern, starValue := -1, 0 //position after the last star and the value it was matched against
+	for p < len(pattern) || v < len(value) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				p++
+				starPattern, starValue = p, v
+				continue
+			}
+			if v < len(value) {
+				if length, ok := matchCharacter(pattern[p:], value[v]); ok {
+					p, v = p+length, v+1
+					continue
+				}
+			}
+		}
+		if starPattern < 0 || starValue == len(value) {
+			return false
+		}
+		starValue++
+		p, v = starPattern, starValue
+	}
+	return true
+}
+
+//Match the character against the first element of the pattern,it isn't a star
+//Returns how many bytes of the pattern the element takes
+func matchCharacter(pattern []byte, char byte) (int, bool) {
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '[':
+		i := 1
+		negated := i < len(pattern) && pattern[i] == '^'
+		if negated {
+			i++
+		}
+		matched := false
+		for i < len(pattern) && pattern[i] != ']' {
+			switch {
+			case pattern[i] == '\\' && i+1 < len(pattern):
+				matched = matched || pattern[i+1] == char
+				i += 2
+			case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+				low, high := pattern[i], pattern[i+2]
+				if low > high {
+					low, high = high, low
+				}
+				matched = matched || (char >= low && char <= high)
+				i += 3
+			default:
+				matched = matched || pattern[i] == char
+				i++
+			}
+		}
+		//the closing bracket
+		if i < len(pattern) {
+			i++
+		}
+		return i, matched != negated
+	case '\\':
+		if len(pattern) > 1 {
+			return 2, pattern[1] == char
+		}
+	}
+	return 1, pattern[0] == char
+}
@@ -0,0 +1,320 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"io/ioutil"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Server on a random port of localhost with an in memory tree,clients use RESP3 unless protocol is 2
+func startTestRedis(t *testing.T) (*redisServer, func(protocol int) *redis.Client) {
+	db, err := Open(Options{Dir: "/data", FS: NewMemFS(), MemtableSize: 100, CompactionStrategy: NoCompaction, Logger: log.New(ioutil.Discard, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{ContentTypeFamily, ExpireFamily} {
+		if _, err := db.CreateColumnFamily(name, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newRedisServer(db.LsmTree, Config{MaxBatchOperations: 100, RedisAddress: listener.Addr().String()})
+	go server.serve(listener)
+	var clients []*redis.Client
+	t.Cleanup(func() {
+		for _, client := range clients {
+			client.Close()
+		}
+		server.stop()
+		listener.Close()
+		db.Close()
+	})
+	return server, func(protocol int) *redis.Client {
+		client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), Protocol: protocol})
+		clients = append(clients, client)
+		return client
+	}
+}
+
+func TestRedisServer_Commands(t *testing.T) {
+	ctx := context.Background()
+	_, newClient := startTestRedis(t)
+	for _, protocol := range []int{2, 3} {
+		client := newClient(protocol)
+		key := func(name string) string {
+			return fmt.Sprintf("%s%d", name, protocol)
+		}
+		if pong, err := client.Ping(ctx).Result(); err != nil || pong != "PONG" {
+			t.Fatalf("ping returned %q %v", pong, err)
+		}
+		if err := client.Set(ctx, key("a"), "1", 0).Err(); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := client.Get(ctx, key("a")).Result(); err != nil || value != "1" {
+			t.Fatalf("get returned %q %v", value, err)
+		}
+		if err := client.Get(ctx, key("missing")).Err(); err != redis.Nil {
+			t.Fatalf("missing key has to be nil,got %v", err)
+		}
+		if err := client.SetArgs(ctx, key("a"), "2", redis.SetArgs{Mode: "NX"}).Err(); err != redis.Nil {
+			t.Fatalf("NX overwrote the key: %v", err)
+		}
+		if err := client.SetArgs(ctx, key("b"), "2", redis.SetArgs{Mode: "XX"}).Err(); err != redis.Nil {
+			t.Fatalf("XX created the key: %v", err)
+		}
+		if err := client.SetArgs(ctx, key("a"), "3", redis.SetArgs{Mode: "XX"}).Err(); err != nil {
+			t.Fatalf("XX didn't overwrite the key: %v", err)
+		}
+		if err := client.SetArgs(ctx, key("new"), "1", redis.SetArgs{Mode: "NX", TTL: time.Hour}).Err(); err != nil {
+			t.Fatalf("NX didn't create the key: %v", err)
+		}
+		if err := client.MSet(ctx, key("b"), "b", key("c"), "c").Err(); err != nil {
+			t.Fatal(err)
+		}
+		values, err := client.MGet(ctx, key("a"), key("missing"), key("c")).Result()
+		if err != nil || fmt.Sprint(values) != "[3 <nil> c]" {
+			t.Fatalf("mget returned %v %v", values, err)
+		}
+		if count, err := client.Exists(ctx, key("a"), key("a"), key("missing")).Result(); err != nil || count != 2 {
+			t.Fatalf("exists returned %d %v", count, err)
+		}
+		if count, err := client.Del(ctx, key("b"), key("c"), key("missing")).Result(); err != nil || count != 2 {
+			t.Fatalf("del returned %d %v", count, err)
+		}
+		if count, err := client.Incr(ctx, key("a")).Result(); err != nil || count != 4 {
+			t.Fatalf("incr returned %d %v", count, err)
+		}
+		if count, err := client.Incr(ctx, key("counter")).Result(); err != nil || count != 1 {
+			t.Fatalf("incr of a missing key returned %d %v", count, err)
+		}
+		client.Set(ctx, key("text"), "text", 0)
+		if err := client.Incr(ctx, key("text")).Err(); err == nil || !strings.Contains(err.Error(), "not an integer") {
+			t.Fatalf("incr of a string has to fail,got %v", err)
+		}
+		//pipelined commands are answered together
+		pipeline := client.Pipeline()
+		incr := pipeline.Incr(ctx, key("counter"))
+		get := pipeline.Get(ctx, key("counter"))
+		if _, err := pipeline.Exec(ctx); err != nil || incr.Val() != 2 || get.Val() != "2" {
+			t.Fatalf("pipeline returned %d %q %v", incr.Val(), get.Val(), err)
+		}
+		if info, err := client.Info(ctx).Result(); err != nil || !strings.Contains(info, "redis_version:") || !strings.Contains(info, "# Wiskey") {
+			t.Fatalf("info returned %q %v", info, err)
+		}
+		if info, err := client.Info(ctx, "clients").Result(); err != nil || strings.Contains(info, "# Server") {
+			t.Fatalf("info of a section returned %q %v", info, err)
+		}
+		if err := client.Do(ctx, "UNKNOWN", "x").Err(); err == nil || !strings.Contains(err.Error(), "unknown command 'UNKNOWN'") {
+			t.Fatalf("unknown command has to fail,got %v", err)
+		}
+		if err := client.Do(ctx, "SET", key("a"), "1", "EX", "0").Err(); err == nil || !strings.Contains(err.Error(), "invalid expire time") {
+			t.Fatalf("zero expiration has to fail,got %v", err)
+		}
+		if err := client.Do(ctx, "SET", key("a"), "1", "NX", "XX").Err(); err == nil || !strings.Contains(err.Error(), "syntax error") {
+			t.Fatalf("NX and XX can't be combined,got %v", err)
+		}
+		if err := client.Do(ctx, "GET").Err(); err == nil || !strings.Contains(err.Error(), "wrong number of arguments") {
+			t.Fatalf("arguments have to be checked,got %v", err)
+		}
+	}
+}
+
+func TestRedisServer_Expiration(t *testing.T) {
+	ctx := context.Background()
+	server, newClient := startTestRedis(t)
+	client := newClient(3)
+	if err := client.Set(ctx, "short", "value", 50*time.Millisecond).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set(ctx, "long", "1", time.Hour).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set(ctx, "cleared", "value", 50*time.Millisecond).Err(); err != nil {
+		t.Fatal(err)
+	}
+	//SET without expiration removes the deadline
+	if err := client.Set(ctx, "cleared", "value", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	//INCR keeps it
+	if err := client.Incr(ctx, "long").Err(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := client.Get(ctx, "short").Err(); err != redis.Nil {
+		t.Fatalf("expired key has to be nil,got %v", err)
+	}
+	if count, err := client.Exists(ctx, "short", "long", "cleared").Result(); err != nil || count != 2 {
+		t.Fatalf("exists returned %d %v", count, err)
+	}
+	deadlines, err := server.deadlines([][]byte{[]byte("long"), []byte("cleared")})
+	if err != nil || deadlines[0] == 0 || deadlines[1] != 0 {
+		t.Fatalf("unexpected deadlines %v %v", deadlines, err)
+	}
+	//expired keys are deleted from the tree in background
+	if _, err := server.lsm.Get([]byte("short")); err != nil {
+		t.Fatalf("expired key is deleted only in background,got %v", err)
+	}
+	if err := server.deleteExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.lsm.Get([]byte("short")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired key wasn't deleted,got %v", err)
+	}
+	if value, err := client.Get(ctx, "long").Result(); err != nil || value != "2" {
+		t.Fatalf("key with a later deadline was deleted: %q %v", value, err)
+	}
+}
+
+//Deadlines removed by http puts keep the key,DEL of missing keys and the stopped worker write nothing
+func TestRedisServer_ExpirationWrites(t *testing.T) {
+	ctx := context.Background()
+	server, newClient := startTestRedis(t)
+	client := newClient(3)
+	for _, key := range []string{"expired", "overwritten"} {
+		if err := client.Set(ctx, key, "value", 10*time.Millisecond).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := putValue(server.lsm, []byte("overwritten"), []byte("new"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.deleteExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := client.Get(ctx, "overwritten").Result(); err != nil || value != "new" {
+		t.Fatalf("value written after the deadline was removed was deleted: %q %v", value, err)
+	}
+	if _, err := server.lsm.Get([]byte("expired")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired key wasn't deleted,got %v", err)
+	}
+	stats, err := server.lsm.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := client.Del(ctx, "missing", "expired", "missing").Result(); err != nil || count != 0 {
+		t.Fatalf("del of missing keys returned %d %v", count, err)
+	}
+	if err := server.deleteExpired(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := server.lsm.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Vlog.Size != stats.Vlog.Size {
+		t.Fatalf("tombstones of missing keys were written,vlog grew from %d to %d bytes", stats.Vlog.Size, after.Vlog.Size)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		server.expireKeys()
+		close(stopped)
+	}()
+	server.stop()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expiration worker didn't stop")
+	}
+}
+
+func TestRedisServer_Scan(t *testing.T) {
+	ctx := context.Background()
+	_, newClient := startTestRedis(t)
+	writer := newClient(3)
+	var users []string
+	for i := 0; i < 30; i++ {
+		users = append(users, fmt.Sprintf("user:%02d", i))
+		if err := writer.Set(ctx, users[i], "value", 0).Err(); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Set(ctx, fmt.Sprintf("order:%02d", i), "value", 0).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"user:*", users},
+		{"user:1?", users[10:20]},
+		{"*:0[0-4]", []string{"order:00", "order:01", "order:02", "order:03", "order:04", "user:00", "user:01", "user:02", "user:03", "user:04"}},
+		{"missing*", nil},
+	}
+	for _, protocol := range []int{2, 3} {
+		client := newClient(protocol)
+		for _, c := range cases {
+			var keys []string
+			iterator := client.Scan(ctx, 0, c.pattern, 7).Iterator()
+			for iterator.Next(ctx) {
+				keys = append(keys, iterator.Val())
+			}
+			if err := iterator.Err(); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(keys)
+			if fmt.Sprint(keys) != fmt.Sprint(c.expected) {
+				t.Fatalf("scan of %q returned %v,expected %v", c.pattern, keys, c.expected)
+			}
+		}
+	}
+	if err := writer.Do(ctx, "SCAN", "12345").Err(); err == nil || !strings.Contains(err.Error(), "invalid cursor") {
+		t.Fatalf("unknown cursor has to fail,got %v", err)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		value   string
+		matched bool
+	}{
+		{"*", "", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbb", false},
+		{"a**", "a", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"[abc]x", "bx", true},
+		{"[^abc]x", "bx", false},
+		{"[a-c]", "d", false},
+		{"[c-a]", "b", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{`[\]]`, "]", true},
+		{"user:*:name", "user:1:name", true},
+		{"user:*:name", "user:1:email", false},
+		{"*a*b", "xaxxb", true},
+		{"*a*b", "xaxxbc", false},
+		{"[", "[", false},
+	}
+	for _, c := range cases {
+		if matchPattern([]byte(c.pattern), []byte(c.value)) != c.matched {
+			t.Fatalf("pattern %q and %q have to match: %v", c.pattern, c.value, c.matched)
+		}
+	}
+	//stars don't backtrack recursively,a pattern that is exponential for a recursive match ends quickly
+	start := time.Now()
+	if matchPattern([]byte(strings.Repeat("*a", 30)+"b"), []byte(strings.Repeat("a", 1000))) {
+		t.Fatal("pattern without b in the value can't match")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("match took %v", elapsed)
+	}
+	if prefix := patternPrefix([]byte("user:*")); string(prefix) != "user:" {
+		t.Fatalf("prefix of the pattern is %q", prefix)
+	}
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxRespArguments = 1 << 20 //of a single command
+	maxInlineLength  = 64 << 10
+)
+
+//Malformed input,the connection is closed after the error is sent
+var errProtocol = errors.New("Protocol error")
+
+//Reads commands of redis clients,they are arrays of bulk strings
+//inline commands separated by spaces are accepted as well,so the server can be used with telnet
+type respReader struct {
+	reader *bufio.Reader
+}
+
+func newRespReader(reader io.Reader) *respReader {
+	return &respReader{reader: bufio.NewReader(reader)}
+}
+
+//Returns arguments of the next command,empty lines return no arguments
+func (resp *respReader) readCommand() ([][]byte, error) {
+	line, err := resp.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count > maxRespArguments {
+		return nil, errProtocol
+	}
+	args := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		line, err := resp.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxValueSize {
+			return nil, errProtocol
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(resp.reader, arg); err != nil {
+			return nil, err
+		}
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, errProtocol
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+//Line without \r\n
+func (resp *respReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		part, isPrefix, err := resp.reader.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, part...)
+		if len(line) > maxInlineLength {
+			return nil, errProtocol
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+//Commands were read and answered,so responses can be sent at once
+func (resp *respReader) drained() bool {
+	return resp.reader.Buffered() == 0
+}
+
+//Writes replies in RESP2 or in RESP3 after HELLO 3
+type respWriter struct {
+	writer   *bufio.Writer
+	protocol int
+}
+
+func newRespWriter(writer io.Writer) *respWriter {
+	return &respWriter{writer: bufio.NewWriter(writer), protocol: 2}
+}
+
+func (resp *respWriter) simple(value string) {
+	resp.line('+', value)
+}
+
+//Message has to start with an error code like ERR,it can't span lines
+func (resp *respWriter) error(message string) {
+	resp.line('-', strings.NewReplacer("\r", " ", "\n", " ").Replace(message))
+}
+
+func (resp *respWriter) integer(value int64) {
+	resp.line(':', strconv.FormatInt(value, 10))
+}
+
+func (resp *respWriter) bulk(value []byte) {
+	resp.line('$', strconv.Itoa(len(value)))
+	resp.writer.Write(value)
+	resp.writer.WriteString("\r\n")
+}
+
+//Missing value
+func (resp *respWriter) null() {
+	if resp.protocol == 3 {
+		resp.writer.WriteString("_\r\n")
+	} else {
+		resp.writer.WriteString("$-1\r\n")
+	}
+}
+
+//Header of an array,its elements are written next
+func (resp *respWriter) array(size int) {
+	resp.line('*', strconv.Itoa(size))
+}
+
+//Header of a map of size pairs,RESP2 has no maps so it's an array of keys and values
+func (resp *respWriter) dictionary(size int) {
+	if resp.protocol == 3 {
+		resp.line('%', strconv.Itoa(size))
+	} else {
+		resp.array(2 * size)
+	}
+}
+
+func (resp *respWriter) line(kind byte, value string) {
+	resp.writer.WriteByte(kind)
+	resp.writer.WriteString(value)
+	resp.writer.WriteString("\r\n")
+}
+
+func (resp *respWriter) flush() error {
+	return resp.writer.Flush()
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultScanLimit = 100
+	maxScanLimit     = 1000 //of a json page,ndjson streams any amount of keys
+	scanPageSize     = 1000 //keys read from the tree at once while streaming
+	ndjsonType       = "application/x-ndjson"
+)
+
+type ScanEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type ScanPage struct {
+	Entries []ScanEntry `json:"entries"`
+	Cursor  string      `json:"cursor,omitempty"` //continues the scan,empty if there are no keys anymore
+}
+
+//Cursor is the bound of the next page,so it doesn't depend on the server state
+func encodeCursor(next *ScanOptions) string {
+	if next == nil {
+		return ""
+	}
+	if next.Reverse {
+		return base64.RawURLEncoding.EncodeToString(next.End)
+	}
+	return base64.RawURLEncoding.EncodeToString(next.Start)
+}
+
+//Read scan options from the query,the cursor replaces start or end of a reverse scan
+func scanOptions(c *gin.Context, defaultLimit int, maxLimit int) (ScanOptions, bool) {
+	options := ScanOptions{Limit: defaultLimit}
+	if start, ok := c.GetQuery("start"); ok {
+		options.Start = []byte(start)
+	}
+	if end, ok := c.GetQuery("end"); ok {
+		options.End = []byte(end)
+	}
+	options.Prefix = []byte(c.Query("prefix"))
+	if reverse := c.Query("reverse"); reverse != "" {
+		parsed, err := strconv.ParseBool(reverse)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reverse has to be true or false"})
+			return options, false
+		}
+		options.Reverse = parsed
+	}
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit has to be a positive number"})
+			return options, false
+		}
+		if maxLimit != 0 && (parsed == 0 || parsed > maxLimit) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit has to be from 1 to " + strconv.Itoa(maxLimit) + ", stream ndjson for more keys"})
+			return options, false
+		}
+		options.Limit = parsed
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		bound, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return options, false
+		}
+		if options.Reverse {
+			options.End = bound
+		} else {
+			options.Start = bound
+		}
+	}
+	return options, true
+}
+
+//Ordered page of keys and values,with Accept: application/x-ndjson keys are streamed line by line
+//and the last line has the cursor if the limit was reached
+func scanHandler(lsm *LsmTree) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.Contains(c.GetHeader("Accept"), ndjsonType) {
+			streamScan(c, lsm)
+			return
+		}
+		options, ok := scanOptions(c, defaultScanLimit, maxScanLimit)
+		if !ok {
+			return
+		}
+		encoding, ok := jsonEncoding(c)
+		if !ok {
+			return
+		}
+		result, err := lsm.Scan(options)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		page := ScanPage{Entries: make([]ScanEntry, len(result.Entries)), Cursor: encodeCursor(result.Next)}
+		for i, entry := range result.Entries {
+			page.Entries[i] = ScanEntry{Key: encodeValue(encoding, entry.Key), Value: encodeValue(encoding, entry.Value)}
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+//Stream the scan page by page,the tree isn't locked between pages
+func streamScan(c *gin.Context, lsm *LsmTree) {
+	options, ok := scanOptions(c, 0, 0)
+	if !ok {
+		return
+	}
+	encoding, ok := jsonEncoding(c)
+	if !ok {
+		return
+	}
+	//keys that are still left,0 streams all of them
+	limited, left := options.Limit != 0, options.Limit
+	encoder := json.NewEncoder(c.Writer)
+	started := false
+	for {
+		options.Limit = scanPageSize
+		if limited && left < scanPageSize {
+			options.Limit = left
+		}
+		result, err := lsm.Scan(options)
+		if err != nil {
+			//the status can't be changed after the first line
+			if !started {
+				respondWithError(c, err)
+			} else {
+				c.Error(err)
+			}
+			return
+		}
+		if !started {
+			c.Header("Content-Type", ndjsonType)
+			c.Status(http.StatusOK)
+			started = true
+		}
+		for _, entry := range result.Entries {
+			if err := encoder.Encode(ScanEntry{Key: encodeValue(encoding, entry.Key), Value: encodeValue(encoding, entry.Value)}); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+		c.Writer.Flush()
+		left -= len(result.Entries)
+		if result.Next == nil {
+			return
+		}
+		if limited && left == 0 {
+			encoder.Encode(gin.H{"cursor": encodeCursor(result.Next)})
+			return
+		}
+		options = *result.Next
+	}
+}
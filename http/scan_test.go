@@ -0,0 +1,175 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+//Keys key00000... with values value0...
+func writeScanKeys(t *testing.T, tree *LsmTree, count int) {
+	batch := NewWriteBatch()
+	for i := 0; i < count; i++ {
+		batch.Put("", []byte(fmt.Sprintf("key%05d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+	if err := tree.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//Follow cursors from the first page till the last one,returns keys of all pages
+func scanPages(t *testing.T, router http.Handler, query string) []string {
+	var keys []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		path := "/scan?" + query
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		var page ScanPage
+		decodeResponse(t, serve(router, http.MethodGet, path, nil), http.StatusOK, &page)
+		for _, entry := range page.Entries {
+			keys = append(keys, entry.Key)
+		}
+		if page.Cursor == "" {
+			return keys
+		}
+		if len(page.Entries) == 0 || pages > 100 {
+			t.Fatalf("page %d of %s has a cursor but no entries", pages, query)
+		}
+		cursor = page.Cursor
+	}
+}
+
+func TestScanHandler_Cursor(t *testing.T) {
+	tree, router := newTestRouter(t, Config{})
+	writeScanKeys(t, tree, 25)
+	var page ScanPage
+	decodeResponse(t, serve(router, http.MethodGet, "/scan?limit=10", nil), http.StatusOK, &page)
+	if len(page.Entries) != 10 || page.Cursor == "" || page.Entries[0] != (ScanEntry{Key: "key00000", Value: "value0"}) {
+		t.Fatalf("first page is %+v", page)
+	}
+	keys := scanPages(t, router, "limit=10")
+	if len(keys) != 25 {
+		t.Fatalf("pages have %d keys: %v", len(keys), keys)
+	}
+	for i, key := range keys {
+		if key != fmt.Sprintf("key%05d", i) {
+			t.Fatalf("key %d is %s", i, key)
+		}
+	}
+	//a reverse cursor is the end of the next page
+	keys = scanPages(t, router, "limit=7&reverse=true")
+	if len(keys) != 25 {
+		t.Fatalf("reverse pages have %d keys: %v", len(keys), keys)
+	}
+	for i, key := range keys {
+		if key != fmt.Sprintf("key%05d", 24-i) {
+			t.Fatalf("reverse key %d is %s", i, key)
+		}
+	}
+	//the cursor stays in the range and the prefix
+	keys = scanPages(t, router, "limit=2&start=key00003&end=key00008")
+	if strings.Join(keys, ",") != "key00003,key00004,key00005,key00006,key00007" {
+		t.Fatalf("range pages are %v", keys)
+	}
+	keys = scanPages(t, router, "limit=3&prefix=key0001&reverse=true")
+	if len(keys) != 10 || keys[0] != "key00019" || keys[9] != "key00010" {
+		t.Fatalf("prefix pages are %v", keys)
+	}
+	//without a limit a page has defaultScanLimit keys
+	writeScanKeys(t, tree, defaultScanLimit+1)
+	page = ScanPage{}
+	decodeResponse(t, serve(router, http.MethodGet, "/scan", nil), http.StatusOK, &page)
+	if len(page.Entries) != defaultScanLimit || page.Cursor == "" {
+		t.Fatalf("default page has %d entries and cursor %q", len(page.Entries), page.Cursor)
+	}
+}
+
+func TestScanHandler_Validation(t *testing.T) {
+	_, router := newTestRouter(t, Config{})
+	for _, query := range []string{
+		"limit=0",
+		fmt.Sprintf("limit=%d", maxScanLimit+1),
+		"limit=-1",
+		"limit=ten",
+		"reverse=maybe",
+		"cursor=not+base64",
+		"encoding=hex",
+	} {
+		var body map[string]string
+		decodeResponse(t, serve(router, http.MethodGet, "/scan?"+query, nil), http.StatusBadRequest, &body)
+		if body["error"] == "" {
+			t.Fatalf("%s has no error message", query)
+		}
+	}
+	//ndjson has no upper limit but the limit is still checked
+	recorder := serve(router, http.MethodGet, "/scan?limit=-1", nil, "Accept", ndjsonType)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("negative ndjson limit returned %d", recorder.Code)
+	}
+	recorder = serve(router, http.MethodGet, fmt.Sprintf("/scan?limit=%d", maxScanLimit+1), nil, "Accept", ndjsonType)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("ndjson limit above the page limit returned %d", recorder.Code)
+	}
+}
+
+//Lines of the ndjson response,the cursor of the last line is returned separately
+func ndjsonLines(t *testing.T, router http.Handler, query string) ([]ScanEntry, string) {
+	recorder := serve(router, http.MethodGet, "/scan?"+query, nil, "Accept", ndjsonType)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != ndjsonType {
+		t.Fatalf("ndjson scan returned %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	var entries []ScanEntry
+	cursor := ""
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		if cursor != "" {
+			t.Fatalf("line after the cursor: %s", scanner.Text())
+		}
+		var line map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %s: %v", scanner.Text(), err)
+		}
+		if next, ok := line["cursor"]; ok {
+			cursor = next
+			continue
+		}
+		entries = append(entries, ScanEntry{Key: line["key"], Value: line["value"]})
+	}
+	return entries, cursor
+}
+
+func TestScanHandler_Ndjson(t *testing.T) {
+	tree, router := newTestRouter(t, Config{})
+	//more keys than a page read from the tree
+	count := scanPageSize + 500
+	writeScanKeys(t, tree, count)
+	entries, cursor := ndjsonLines(t, router, "")
+	if len(entries) != count || cursor != "" {
+		t.Fatalf("ndjson returned %d lines and cursor %q", len(entries), cursor)
+	}
+	for i, entry := range entries {
+		if entry != (ScanEntry{Key: fmt.Sprintf("key%05d", i), Value: fmt.Sprintf("value%d", i)}) {
+			t.Fatalf("line %d is %+v", i, entry)
+		}
+	}
+	//the limit ends the stream with a cursor line
+	entries, cursor = ndjsonLines(t, router, fmt.Sprintf("limit=%d", scanPageSize+100))
+	if len(entries) != scanPageSize+100 || cursor == "" {
+		t.Fatalf("limited ndjson returned %d lines and cursor %q", len(entries), cursor)
+	}
+	rest, last := ndjsonLines(t, router, "cursor="+url.QueryEscape(cursor))
+	if len(rest) != count-scanPageSize-100 || last != "" || rest[0].Key != fmt.Sprintf("key%05d", scanPageSize+100) {
+		t.Fatalf("ndjson after the cursor returned %d lines from %+v and cursor %q", len(rest), rest[0], last)
+	}
+	entries, _ = ndjsonLines(t, router, "reverse=true&prefix=key0000")
+	if len(entries) != 10 || entries[0].Key != "key00009" {
+		t.Fatalf("reverse ndjson with prefix returned %+v", entries)
+	}
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/tsandl/go-wiskey-update/pkg"
 	"net/http"
@@ -10,8 +11,33 @@ type Value struct {
 	Value string `json:"value" binding:"required"`
 }
 
-func Start(lsm *LsmTree) {
+//Limits of the server,0 disables a limit
+type Config struct {
+	MaxBatchOperations int    //puts and deletes of POST /batch and keys of POST /batch/get
+	MaxBatchBytes      int    //size of the POST /batch body
+	GrpcAddress        string //address of the grpc api,empty disables it
+	RedisAddress       string //address of the redis protocol server,empty disables it
+}
+
+func Start(lsm *LsmTree, config Config) {
+	router := newRouter(lsm, config)
+	if config.GrpcAddress != "" {
+		startGrpc(lsm, config)
+	}
+	if config.RedisAddress != "" {
+		startRedis(lsm, config)
+	}
+	err := router.Run(":8080")
+	if err != nil {
+		panic(err)
+	}
+}
+
+//Routes of the http api,the router isn't started
+func newRouter(lsm *LsmTree, config Config) *gin.Engine {
 	router := gin.New()
+	metrics := newHttpMetrics()
+	router.Use(metrics.middleware)
 	router.GET("/gc", func(c *gin.Context) {
 		err := lsm.CompressVlog()
 		if err != nil {
@@ -23,7 +49,7 @@ func Start(lsm *LsmTree) {
 	//delete key
 	router.DELETE("/:key", func(c *gin.Context) {
 		key := c.Param("key")
-		err := lsm.Delete([]byte(key))
+		err := deleteValue(lsm, []byte(key))
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{"error": err.Error()})
 		} else {
@@ -32,34 +58,147 @@ func Start(lsm *LsmTree) {
 	})
 	//get key
 	router.GET("/fetch/:key", func(c *gin.Context) {
+		encoding, ok := jsonEncoding(c)
+		if !ok {
+			return
+		}
 		key := c.Param("key")
-		value, found := lsm.Get([]byte(key))
-		if found {
-			c.JSON(http.StatusOK, gin.H{"value": string(value)})
+		value, err := lsm.Get([]byte(key))
+		if err != nil {
+			respondWithError(c, err)
 		} else {
-			c.Status(http.StatusNotFound)
+			c.JSON(http.StatusOK, gin.H{"value": encodeValue(encoding, value)})
 		}
 	})
 	//post key
 	router.POST("/:key", func(c *gin.Context) {
 		var json Value
 		key := c.Param("key")
+		encoding, ok := jsonEncoding(c)
+		if !ok {
+			return
+		}
 		if err := c.ShouldBindJSON(&json); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		entry := NewEntry([]byte(key), []byte(json.Value))
-		err := lsm.Put(&entry)
+		value, ok := decodeValue(c, encoding, json.Value)
+		if !ok {
+			return
+		}
+		//the content type of the old value written by PUT /kv/*key is removed
+		err := putValue(lsm, []byte(key), value, "")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondWithError(c, err)
 			return
 		} else {
 			c.Status(http.StatusAccepted)
 		}
 	})
 
-	err := router.Run(":8080")
-	if err != nil {
-		panic(err)
+	//atomic batch of puts and deletes
+	router.POST("/batch", batchHandler(lsm, config))
+	//get many keys at once with their found status
+	router.POST("/batch/get", batchGetHandler(lsm, config))
+	//statistics of the engine
+	router.GET("/stats", func(c *gin.Context) {
+		stats, err := lsm.Stats()
+		if err != nil {
+			respondWithError(c, err)
+		} else {
+			c.JSON(http.StatusOK, stats)
+		}
+	})
+	//raw values with content types,keys can contain slashes
+	router.PUT("/kv/*key", putRaw(lsm))
+	router.GET("/kv/*key", getRaw(lsm))
+	router.DELETE("/kv/*key", deleteRaw(lsm))
+	//ordered keys and values in the range or with the prefix
+	router.GET("/scan", scanHandler(lsm))
+	//engine and http metrics for Prometheus
+	router.GET("/metrics", metrics.handler(lsm))
+	//column families
+	families := router.Group("/cf/:name")
+	//get key from column family
+	families.GET("/:key", func(c *gin.Context) {
+		family, found := columnFamily(c, lsm)
+		if !found {
+			return
+		}
+		encoding, ok := jsonEncoding(c)
+		if !ok {
+			return
+		}
+		value, err := family.Get([]byte(c.Param("key")))
+		if err != nil {
+			respondWithError(c, err)
+		} else {
+			c.JSON(http.StatusOK, gin.H{"value": encodeValue(encoding, value)})
+		}
+	})
+	//post key to column family
+	families.POST("/:key", func(c *gin.Context) {
+		family, found := columnFamily(c, lsm)
+		if !found {
+			return
+		}
+		var json Value
+		encoding, ok := jsonEncoding(c)
+		if !ok {
+			return
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		value, ok := decodeValue(c, encoding, json.Value)
+		if !ok {
+			return
+		}
+		entry := NewEntry([]byte(c.Param("key")), value)
+		err := family.Put(&entry)
+		if err != nil {
+			respondWithError(c, err)
+		} else {
+			c.Status(http.StatusAccepted)
+		}
+	})
+	//delete key from column family
+	families.DELETE("/:key", func(c *gin.Context) {
+		family, found := columnFamily(c, lsm)
+		if !found {
+			return
+		}
+		err := family.Delete([]byte(c.Param("key")))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"error": err.Error()})
+		} else {
+			c.Status(http.StatusAccepted)
+		}
+	})
+	return router
+}
+
+//Map storage errors to http status codes
+func respondWithError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.Status(http.StatusNotFound)
+	case errors.Is(err, ErrKeyTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrClosed):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+//Find the column family from the path, responds with 404 if it doesn't exist
+func columnFamily(c *gin.Context, lsm *LsmTree) (*LsmTree, bool) {
+	name := c.Param("name")
+	family, found := lsm.ColumnFamily(name)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "column family " + name + " doesn't exist"})
 	}
+	return family, found
 }
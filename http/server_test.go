@@ -0,0 +1,53 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/tsandl/go-wiskey-update/pkg"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//Router of an in memory tree with the families that main creates and a users family
+func newTestRouter(t *testing.T, config Config) (*LsmTree, http.Handler) {
+	gin.SetMode(gin.TestMode)
+	db, err := Open(Options{Dir: "/data", FS: NewMemFS(), MemtableSize: 1000, CompactionStrategy: NoCompaction, Logger: log.New(ioutil.Discard, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	for _, name := range []string{ContentTypeFamily, "users"} {
+		if _, err := db.CreateColumnFamily(name, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db.LsmTree, newRouter(db.LsmTree, config)
+}
+
+//Send the request to the router,headers are name and value pairs
+func serve(router http.Handler, method string, path string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, body)
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+//Decode the json body of a response with the expected status
+func decodeResponse(t *testing.T, recorder *httptest.ResponseRecorder, status int, body interface{}) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Fatalf("expected status %d,got %d %s", status, recorder.Code, recorder.Body)
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), body); err != nil {
+		t.Fatalf("invalid json %s: %v", recorder.Body, err)
+	}
+}
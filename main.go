@@ -1,9 +1,16 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/tsandl/go-wiskey-update/cmd"
 	"github.com/tsandl/go-wiskey-update/http"
 	"github.com/tsandl/go-wiskey-update/pkg"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 )
 
 func main() {
@@ -11,8 +18,130 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	vlog := NewVlog(parse.Vlog, parse.Checkpoint)
-	memtable := NewMemTable(parse.MemtableSize)
-	tree := NewLsmTree(vlog, parse.SStablePath, memtable, 120)
-	http.Start(tree)
+	if parse.Command == "bench" {
+		bench(parse)
+		return
+	}
+	if parse.Command == "stats" {
+		stats(parse)
+		return
+	}
+	db, err := Open(engineOptions(parse))
+	if err != nil {
+		panic(err)
+	}
+	tree := db.LsmTree
+	for _, family := range parse.Families {
+		_, err := tree.CreateColumnFamily(family, parse.MemtableSize)
+		if err != nil {
+			panic(err)
+		}
+	}
+	//content types of values written by PUT /kv/*key
+	if _, err := tree.CreateColumnFamily(http.ContentTypeFamily, parse.MemtableSize); err != nil {
+		panic(err)
+	}
+	//deadlines of keys set by redis clients with EX or PX
+	if parse.Redis != "" {
+		if _, err := tree.CreateColumnFamily(http.ExpireFamily, parse.MemtableSize); err != nil {
+			panic(err)
+		}
+	}
+	for name, compression := range parse.FamilyCompression {
+		family, ok := tree.ColumnFamily(name)
+		if !ok {
+			panic("unknown column family " + name)
+		}
+		compressor, err := CompressorByName(compression)
+		if err != nil {
+			panic(err)
+		}
+		family.SetCompressor(compressor)
+	}
+	//flush memtables and persist the head on shutdown
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if err := db.Close(); err != nil {
+			fmt.Println("Close failed " + err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
+	http.Start(tree, http.Config{
+		MaxBatchOperations: parse.BatchOperations,
+		MaxBatchBytes:      parse.BatchBytes,
+		GrpcAddress:        parse.Grpc,
+		RedisAddress:       parse.Redis,
+	})
+}
+
+//Options of the engine from the command line
+func engineOptions(parse *cmd.Options) Options {
+	options := DefaultOptions()
+	options.Dir = parse.Dir
+	options.SStableDir = parse.SStablePath
+	options.VlogPath = parse.Vlog
+	options.CheckpointPath = parse.Checkpoint
+	options.MemtableSize = parse.MemtableSize
+	options.ValueThreshold = parse.Inline
+	options.BlockSize = parse.BlockSize
+	options.BlockCacheSize = parse.BlockCache
+	options.ValueCacheSize = parse.ValueCache
+	options.Compression = parse.Compression
+	options.CompactionInterval = parse.CompactionInterval
+	options.CompactionStrategy = CompactionStrategy(parse.Compaction)
+	options.GcEntries = parse.GcEntries
+	options.GcInterval = parse.GcInterval
+	options.SyncMode = SyncMode(parse.Sync)
+	return options
+}
+
+//Run benchmark workloads,the data directory is a temporary one unless it's set
+func bench(parse *cmd.Options) {
+	options := engineOptions(parse)
+	if options.Dir == "" {
+		options.Dir = filepath.Join(os.TempDir(), "wiskey-bench")
+	}
+	//all files of the benchmark are in its directory,so it can be removed afterwards
+	options.SStableDir, options.VlogPath, options.CheckpointPath = "", "", ""
+	config := BenchConfig{
+		Workloads: strings.Split(parse.Bench.Benchmarks, ","),
+		Num:       parse.Bench.Num,
+		Ops:       parse.Bench.Ops,
+		KeySize:   parse.Bench.KeySize,
+		ValueSize: parse.Bench.ValueSize,
+		Threads:   parse.Bench.Threads,
+		Seed:      parse.Bench.Seed,
+	}
+	if err := RunBenchmarks(options, config, os.Stdout); err != nil {
+		fmt.Println("Benchmark failed " + err.Error())
+		os.Exit(1)
+	}
+}
+
+//Print statistics of the data directory,it's locked so the server can't run on it
+//it's opened read only,so a missing directory isn't created and memtables aren't flushed
+func stats(parse *cmd.Options) {
+	options := engineOptions(parse)
+	options.ReadOnly = true
+	db, err := Open(options)
+	if err != nil {
+		fmt.Println("Can't open the data directory " + err.Error())
+		os.Exit(1)
+	}
+	stats, err := db.Stats()
+	var encoded []byte
+	if err == nil {
+		encoded, err = json.MarshalIndent(stats, "", "  ")
+	}
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Println("Stats failed " + err.Error())
+		os.Exit(1)
+	}
+	fmt.Println(string(encoded))
 }
@@ -0,0 +1,152 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//Set of puts and deletes that is applied atomically,
+//entries can belong to different column families
+type WriteBatch struct {
+	families []string
+	entries  []*TableEntry
+	existing []bool //the delete is dropped if the key doesn't exist when the batch is written
+	required []requirement
+}
+
+//Value the key has to have when the batch is written
+type requirement struct {
+	family string
+	key    []byte
+	value  []byte
+}
+
+func NewWriteBatch() *WriteBatch {
+	return &WriteBatch{}
+}
+
+//Put the key to given column family
+func (batch *WriteBatch) Put(family string, key []byte, value []byte) {
+	batch.add(family, &TableEntry{key: key, value: value}, false)
+}
+
+//Delete the key from given column family
+func (batch *WriteBatch) Delete(family string, key []byte) {
+	batch.add(family, DeletedEntry(key), false)
+}
+
+//Delete the key only if it exists before the batch is applied,so no tombstone is saved for missing keys
+func (batch *WriteBatch) DeleteExisting(family string, key []byte) {
+	batch.add(family, DeletedEntry(key), true)
+}
+
+//Write the batch only if the key of the family has the value,otherwise Write returns ErrConditionFailed
+//it's checked under the same lock as the batch is written,so a concurrent write can't slip in between
+func (batch *WriteBatch) Require(family string, key []byte, value []byte) {
+	batch.required = append(batch.required, requirement{family: family, key: key, value: value})
+}
+
+func (batch *WriteBatch) add(family string, entry *TableEntry, existing bool) {
+	batch.families = append(batch.families, family)
+	batch.entries = append(batch.entries, entry)
+	batch.existing = append(batch.existing, existing)
+}
+
+func (batch *WriteBatch) Len() int {
+	return len(batch.entries)
+}
+
+//Apply the batch,all entries are saved in the vlog with a single write
+func (lsm *LsmTree) Write(batch *WriteBatch) error {
+	defer lsm.root.stats.operation(opWrite, time.Now())
+	if batch.Len() == 0 {
+		return nil
+	}
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	if err := lsm.checkWritable(); err != nil {
+		return err
+	}
+	for _, required := range batch.required {
+		tree, ok := lsm.familyByName(required.family)
+		if !ok {
+			return fmt.Errorf("unknown column family %q", required.family)
+		}
+		value, err := tree.get(required.key)
+		if errors.Is(err, ErrNotFound) || err == nil && !bytes.Equal(value, required.value) {
+			return ErrConditionFailed
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var trees []*LsmTree
+	var entries, written []*TableEntry
+	for i, entry := range batch.entries {
+		name := batch.families[i]
+		tree, ok := lsm.familyByName(name)
+		if !ok {
+			return fmt.Errorf("unknown column family %q", name)
+		}
+		//check it before the batch is saved in the vlog
+		if err := validateKey(entry.key); err != nil {
+			return err
+		}
+		if string(entry.key) == tombstone {
+			return errors.New("can't use this key, it's reserved as tombstone")
+		}
+		if batch.existing[i] {
+			_, err := tree.get(entry.key)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		trees = append(trees, tree)
+		entries = append(entries, entry.withFamily(tree.family))
+		written = append(written, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	compressed := make([]*TableEntry, len(entries))
+	for i, entry := range entries {
+		var err error
+		compressed[i], err = entry.compressed(trees[i].compressor)
+		if err != nil {
+			return err
+		}
+	}
+	metas, err := lsm.log.AppendBatch(compressed)
+	if err != nil {
+		return err
+	}
+	full := false
+	for i, tree := range trees {
+		key := entries[i].key
+		if string(entries[i].value) == tombstone {
+			tree.deleted[string(key)] = true
+		} else {
+			delete(tree.deleted, string(key))
+		}
+		err := tree.memtable.Put(key, tree.inlined(entries[i], metas[i]))
+		if err != nil {
+			return err
+		}
+		tree.notify(writeEvent(written[i]))
+		full = full || tree.memtable.isFull()
+	}
+	if full {
+		//the write waits for the flush
+		start := time.Now()
+		if err := lsm.flush(); err != nil {
+			return err
+		}
+		lsm.root.stats.writeStall.record(start, 0, 0)
+	}
+	return nil
+}
@@ -0,0 +1,521 @@
+package wiskey
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//Workloads of the benchmark,they are modeled on db_bench of leveldb and YCSB
+//fill workloads start with an empty database,the rest uses keys written by previous workloads
+var BenchWorkloads = []string{
+	"fillseq",      //write keys in order
+	"fillrandom",   //write keys in random order
+	"overwrite",    //overwrite random existing keys
+	"readrandom",   //read random existing keys
+	"readmissing",  //read keys that don't exist
+	"readseq",      //read keys in order
+	"deleterandom", //delete random keys
+	"ycsba",        //50% reads,50% updates,zipfian keys
+	"ycsbb",        //95% reads,5% updates,zipfian keys
+	"ycsbc",        //only reads,zipfian keys
+	"ycsbd",        //95% reads of the latest keys,5% inserts
+	"ycsbe",        //95% short scans,5% inserts
+	"ycsbf",        //50% reads,50% read-modify-writes,zipfian keys
+}
+
+//Settings of the benchmark
+type BenchConfig struct {
+	Workloads []string //workloads to run in the given order
+	Num       int      //keys written by fill workloads
+	Ops       int      //operations of the other workloads,0 means Num
+	KeySize   int      //size of keys in bytes
+	ValueSize int      //size of values in bytes
+	Threads   int      //goroutines that run operations
+	Seed      int64    //seed of random keys and values
+}
+
+//Check that the benchmark can be run
+func (config BenchConfig) Validate() error {
+	for _, workload := range config.Workloads {
+		if benchWorkloadIndex(workload) < 0 {
+			return fmt.Errorf("unknown workload %q,expected one of %s", workload, strings.Join(BenchWorkloads, ","))
+		}
+	}
+	if config.Num <= 0 || config.Ops < 0 || config.Threads <= 0 {
+		return errors.New("num and threads have to be positive,ops can't be negative")
+	}
+	//keys are zero padded numbers
+	if config.KeySize < 8 || config.KeySize >= maxKeyLength || config.ValueSize < 0 {
+		return fmt.Errorf("key size has to be between 8 and %d,value size can't be negative", maxKeyLength)
+	}
+	return nil
+}
+
+func benchWorkloadIndex(name string) int {
+	for i, workload := range BenchWorkloads {
+		if workload == name {
+			return i
+		}
+	}
+	return -1
+}
+
+//Result of a single workload
+type benchResult struct {
+	name        string
+	ops         int
+	duration    time.Duration
+	latencies   []time.Duration //sorted latencies of all operations
+	found       int64           //reads that found the key
+	userBytes   int64           //keys and values written by the workload
+	diskWritten int64           //bytes written to vlog,sstables and metadata
+	diskSize    int64           //size of all files after the workload
+	liveBytes   int64           //keys and values that exist after the workload
+}
+
+func (result *benchResult) percentile(percent float64) time.Duration {
+	if len(result.latencies) == 0 {
+		return 0
+	}
+	return result.latencies[int(float64(len(result.latencies)-1)*percent/100)]
+}
+
+func (result *benchResult) opsPerSecond() float64 {
+	return float64(result.ops) / result.duration.Seconds()
+}
+
+//Bytes written to the disk per byte written by the user,0 if the workload doesn't write
+func (result *benchResult) writeAmplification() float64 {
+	if result.userBytes == 0 {
+		return 0
+	}
+	return float64(result.diskWritten) / float64(result.userBytes)
+}
+
+//Size of files per byte of live data
+func (result *benchResult) spaceAmplification() float64 {
+	if result.liveBytes == 0 {
+		return 0
+	}
+	return float64(result.diskSize) / float64(result.liveBytes)
+}
+
+//Single line in the format of db_bench with latencies and amplification
+func (result *benchResult) String() string {
+	line := fmt.Sprintf("%-12s : %10.3f micros/op; %10.0f ops/sec; p50 %v p99 %v p999 %v;",
+		result.name, result.duration.Seconds()*1e6/float64(result.ops), result.opsPerSecond(),
+		result.percentile(50), result.percentile(99), result.percentile(99.9))
+	if result.userBytes != 0 {
+		line += fmt.Sprintf(" write amp %.2f;", result.writeAmplification())
+	}
+	line += fmt.Sprintf(" space amp %.2f", result.spaceAmplification())
+	if strings.HasPrefix(result.name, "read") || strings.HasPrefix(result.name, "ycsb") {
+		line += fmt.Sprintf(" (%d found)", result.found)
+	}
+	return line
+}
+
+//Run workloads one by one and print a line per workload
+//the data directory has to be empty or missing,it's removed when the benchmark ends
+func RunBenchmarks(opts Options, config BenchConfig, out io.Writer) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	bench, err := newBenchmark(opts, config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Keys:       %d bytes each\n", config.KeySize)
+	fmt.Fprintf(out, "Values:     %d bytes each\n", config.ValueSize)
+	fmt.Fprintf(out, "Entries:    %d\n", config.Num)
+	fmt.Fprintf(out, "Operations: %d\n", bench.config.Ops)
+	fmt.Fprintf(out, "Threads:    %d\n", config.Threads)
+	fmt.Fprintf(out, "------------------------------------------------\n")
+	for _, workload := range config.Workloads {
+		result, err := bench.run(workload)
+		if err != nil {
+			bench.destroy()
+			return fmt.Errorf("%s: %w", workload, err)
+		}
+		fmt.Fprintln(out, result)
+	}
+	return bench.destroy()
+}
+
+//Database under the benchmark and the state of its keys
+type benchmark struct {
+	opts     Options
+	config   BenchConfig
+	fs       *countingFS
+	db       *DB
+	live     []int32 //1 if the key exists,keys after Num are inserted by ycsb workloads
+	inserted int64   //keys inserted after Num
+	values   []byte  //random data that values are cut from
+}
+
+func newBenchmark(opts Options, config BenchConfig) (*benchmark, error) {
+	opts = opts.withDefaults()
+	if config.Ops == 0 {
+		config.Ops = config.Num
+	}
+	if opts.Dir == "" {
+		return nil, errors.New("benchmark needs a data directory")
+	}
+	infos, err := opts.FS.ReadDir(opts.Dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	//the directory is removed after the benchmark,so existing data is never touched
+	if len(infos) != 0 {
+		return nil, fmt.Errorf("benchmark directory %s is not empty", opts.Dir)
+	}
+	counting := &countingFS{FS: opts.FS}
+	opts.FS = counting
+	rnd := rand.New(rand.NewSource(config.Seed))
+	values := make([]byte, 1<<20+config.ValueSize)
+	for i := range values {
+		values[i] = byte('a' + rnd.Intn(26))
+	}
+	bench := &benchmark{opts: opts, config: config, fs: counting, values: values}
+	return bench, bench.fresh()
+}
+
+//Start with an empty database
+func (bench *benchmark) fresh() error {
+	if bench.db != nil {
+		if err := bench.db.Close(); err != nil {
+			return err
+		}
+		bench.db = nil
+	}
+	if err := removeAll(bench.fs, bench.opts.Dir); err != nil {
+		return err
+	}
+	//every ycsb workload inserts less than Ops keys
+	bench.live = make([]int32, bench.config.Num+bench.config.Ops*len(bench.config.Workloads))
+	bench.inserted = 0
+	db, err := Open(bench.opts)
+	if err != nil {
+		return err
+	}
+	bench.db = db
+	return nil
+}
+
+//Close the database and remove its files
+func (bench *benchmark) destroy() error {
+	if bench.db != nil {
+		if err := bench.db.Close(); err != nil {
+			return err
+		}
+		bench.db = nil
+	}
+	return removeAll(bench.fs, bench.opts.Dir)
+}
+
+//State of a single goroutine of the workload
+type benchThread struct {
+	bench     *benchmark
+	rnd       *rand.Rand
+	zipf      *rand.Zipf
+	latencies []time.Duration
+	found     int64
+	userBytes int64
+}
+
+//Run the workload and measure it,the memtable is flushed after writes,so amplification includes sstables
+func (bench *benchmark) run(workload string) (*benchResult, error) {
+	if strings.HasPrefix(workload, "fill") {
+		if err := bench.fresh(); err != nil {
+			return nil, err
+		}
+	}
+	ops := bench.config.Ops
+	if strings.HasPrefix(workload, "fill") {
+		ops = bench.config.Num
+	}
+	op := bench.operation(workload)
+	atomic.StoreInt64(&bench.fs.written, 0)
+	threads := make([]*benchThread, bench.config.Threads)
+	errs := make(chan error, len(threads))
+	var group sync.WaitGroup
+	start := time.Now()
+	for t := range threads {
+		rnd := rand.New(rand.NewSource(bench.config.Seed + int64(t) + 1))
+		//s has to be bigger than 1,YCSB uses 0.99
+		threads[t] = &benchThread{bench: bench, rnd: rnd, zipf: rand.NewZipf(rnd, 1.01, 1, uint64(bench.config.Num-1))}
+		//every thread runs a contiguous part of operations,so fillseq writes keys in order
+		first, last := ops*t/len(threads), ops*(t+1)/len(threads)
+		group.Add(1)
+		go func(thread *benchThread) {
+			defer group.Done()
+			for i := first; i < last; i++ {
+				started := time.Now()
+				if err := op(thread, i); err != nil {
+					errs <- err
+					return
+				}
+				thread.latencies = append(thread.latencies, time.Since(started))
+			}
+		}(threads[t])
+	}
+	group.Wait()
+	duration := time.Since(start)
+	close(errs)
+	for err := range errs {
+		return nil, err
+	}
+	result := &benchResult{name: workload, ops: ops, duration: duration}
+	for _, thread := range threads {
+		result.latencies = append(result.latencies, thread.latencies...)
+		result.found += thread.found
+		result.userBytes += thread.userBytes
+	}
+	sort.Slice(result.latencies, func(i, j int) bool {
+		return result.latencies[i] < result.latencies[j]
+	})
+	if result.userBytes != 0 {
+		if err := bench.db.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	result.diskWritten = atomic.LoadInt64(&bench.fs.written)
+	size, err := dirSize(bench.fs, bench.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	result.diskSize = size
+	for i := range bench.live {
+		result.liveBytes += int64(atomic.LoadInt32(&bench.live[i])) * int64(bench.config.KeySize+bench.config.ValueSize)
+	}
+	return result, nil
+}
+
+//Operation of the workload,i is the index of the operation
+func (bench *benchmark) operation(workload string) func(thread *benchThread, i int) error {
+	num := bench.config.Num
+	switch workload {
+	case "fillseq":
+		return func(thread *benchThread, i int) error {
+			return thread.put(i)
+		}
+	case "fillrandom", "overwrite":
+		return func(thread *benchThread, i int) error {
+			return thread.put(thread.rnd.Intn(num))
+		}
+	case "readrandom":
+		return func(thread *benchThread, i int) error {
+			return thread.get(thread.rnd.Intn(num))
+		}
+	case "readmissing":
+		return func(thread *benchThread, i int) error {
+			return thread.getKey(bench.missingKey(thread.rnd.Intn(num)))
+		}
+	case "readseq":
+		return func(thread *benchThread, i int) error {
+			return thread.get(i % num)
+		}
+	case "deleterandom":
+		return func(thread *benchThread, i int) error {
+			return thread.delete(thread.rnd.Intn(num))
+		}
+	case "ycsba", "ycsbb", "ycsbc":
+		reads := map[string]int{"ycsba": 50, "ycsbb": 95, "ycsbc": 100}[workload]
+		return func(thread *benchThread, i int) error {
+			if thread.rnd.Intn(100) < reads {
+				return thread.get(thread.zipfian())
+			}
+			return thread.put(thread.zipfian())
+		}
+	case "ycsbd":
+		return func(thread *benchThread, i int) error {
+			if thread.rnd.Intn(100) < 95 {
+				return thread.get(thread.latest())
+			}
+			return thread.put(bench.insertKey())
+		}
+	case "ycsbe":
+		return func(thread *benchThread, i int) error {
+			if thread.rnd.Intn(100) < 95 {
+				return thread.scan(thread.zipfian(), 1+thread.rnd.Intn(100))
+			}
+			return thread.put(bench.insertKey())
+		}
+	case "ycsbf":
+		return func(thread *benchThread, i int) error {
+			key := thread.zipfian()
+			if thread.rnd.Intn(100) < 50 {
+				return thread.get(key)
+			}
+			if err := thread.get(key); err != nil {
+				return err
+			}
+			return thread.put(key)
+		}
+	}
+	return nil
+}
+
+func (bench *benchmark) key(i int) []byte {
+	return []byte(fmt.Sprintf("%0*d", bench.config.KeySize, i))
+}
+
+//Key that is never written,it's longer than all written keys
+func (bench *benchmark) missingKey(i int) []byte {
+	return append(bench.key(i), '.')
+}
+
+//Keys after Num are inserted by ycsb workloads
+func (bench *benchmark) insertKey() int {
+	return bench.config.Num + int(atomic.AddInt64(&bench.inserted, 1)-1)
+}
+
+//Amount of keys including inserted ones
+func (bench *benchmark) keys() int {
+	return bench.config.Num + int(atomic.LoadInt64(&bench.inserted))
+}
+
+//Popular keys are scattered over the key space like in YCSB
+func (thread *benchThread) zipfian() int {
+	hash := fnv.New64a()
+	fmt.Fprint(hash, thread.zipf.Uint64())
+	return int(hash.Sum64() % uint64(thread.bench.config.Num))
+}
+
+//The most recently inserted keys are the most popular
+func (thread *benchThread) latest() int {
+	key := thread.bench.keys() - 1 - int(thread.zipf.Uint64())
+	if key < 0 {
+		return 0
+	}
+	return key
+}
+
+func (thread *benchThread) value() []byte {
+	offset := thread.rnd.Intn(len(thread.bench.values) - thread.bench.config.ValueSize)
+	return thread.bench.values[offset : offset+thread.bench.config.ValueSize]
+}
+
+func (thread *benchThread) put(i int) error {
+	bench := thread.bench
+	if err := bench.db.Put(&TableEntry{key: bench.key(i), value: thread.value()}); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&bench.live[i], 1)
+	thread.userBytes += int64(bench.config.KeySize + bench.config.ValueSize)
+	return nil
+}
+
+func (thread *benchThread) get(i int) error {
+	return thread.getKey(thread.bench.key(i))
+}
+
+func (thread *benchThread) getKey(key []byte) error {
+	_, err := thread.bench.db.Get(key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err == nil {
+		thread.found++
+	}
+	return err
+}
+
+func (thread *benchThread) delete(i int) error {
+	bench := thread.bench
+	if err := bench.db.Delete(bench.key(i)); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&bench.live[i], 0)
+	thread.userBytes += int64(bench.config.KeySize)
+	return nil
+}
+
+//Read keys in order starting from the given one
+func (thread *benchThread) scan(start int, length int) error {
+	result, err := thread.bench.db.Scan(ScanOptions{Start: thread.bench.key(start), Limit: length})
+	if err != nil {
+		return err
+	}
+	thread.found += int64(len(result.Entries))
+	return nil
+}
+
+//File system that counts written bytes
+type countingFS struct {
+	FS
+	written int64
+}
+
+func (fs *countingFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	file, err := fs.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: file, written: &fs.written}, nil
+}
+
+type countingFile struct {
+	File
+	written *int64
+}
+
+func (file *countingFile) Write(buffer []byte) (int, error) {
+	written, err := file.File.Write(buffer)
+	atomic.AddInt64(file.written, int64(written))
+	return written, err
+}
+
+//Size of all files in the directory and its subdirectories
+func dirSize(fs FS, dir string) (int64, error) {
+	infos, err := fs.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	size := int64(0)
+	for _, info := range infos {
+		if info.IsDir() {
+			subdir, err := dirSize(fs, filepath.Join(dir, info.Name()))
+			if err != nil {
+				return 0, err
+			}
+			size += subdir
+		} else {
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
+
+//Remove the directory with everything in it,a missing directory is not an error
+func removeAll(fs FS, dir string) error {
+	infos, err := fs.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		if info.IsDir() {
+			err = removeAll(fs, path)
+		} else {
+			err = fs.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fs.Remove(dir)
+}
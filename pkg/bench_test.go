@@ -0,0 +1,127 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func benchOptions(fs FS, dir string) Options {
+	return Options{Dir: dir, FS: fs, MemtableSize: 100, Logger: log.New(ioutil.Discard, "", 0)}
+}
+
+func TestRunBenchmarks(t *testing.T) {
+	fs := NewMemFS()
+	config := BenchConfig{Workloads: BenchWorkloads, Num: 300, KeySize: 16, ValueSize: 50, Threads: 3, Seed: 1}
+	out := bytes.NewBuffer([]byte{})
+	if err := RunBenchmarks(benchOptions(fs, "/bench"), config, out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	results := lines[len(lines)-len(BenchWorkloads):]
+	for i, workload := range BenchWorkloads {
+		if !strings.HasPrefix(results[i], workload+" ") || !strings.Contains(results[i], "p999") {
+			t.Fatalf("unexpected result of %s: %s", workload, results[i])
+		}
+	}
+	if !strings.Contains(results[0], "write amp") || strings.Contains(results[3], "write amp") {
+		t.Fatalf("only writes have write amplification:\n%s", out)
+	}
+	if strings.Contains(results[3], "(0 found)") || !strings.Contains(results[4], "(0 found)") {
+		t.Fatalf("readrandom has to find keys,readmissing none:\n%s", out)
+	}
+	//the benchmark removes its data
+	if _, err := fs.Stat("/bench"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("benchmark directory wasn't removed: %v", err)
+	}
+}
+
+func TestRunBenchmarks_Validation(t *testing.T) {
+	fs := NewMemFS()
+	valid := BenchConfig{Workloads: []string{"fillseq"}, Num: 10, KeySize: 16, Threads: 1}
+	invalid := []BenchConfig{valid, valid, valid}
+	invalid[0].Workloads = []string{"fillsomething"}
+	invalid[1].KeySize = 4
+	invalid[2].Threads = 0
+	for i, config := range invalid {
+		if err := RunBenchmarks(benchOptions(fs, "/bench"), config, ioutil.Discard); err == nil {
+			t.Fatalf("config %d has to be rejected", i)
+		}
+	}
+	//existing data is never removed
+	if err := fs.MkdirAll("/data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(fs, "/data/MANIFEST", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunBenchmarks(benchOptions(fs, "/data"), valid, ioutil.Discard); err == nil {
+		t.Fatal("not empty directory has to be rejected")
+	}
+	if _, err := fs.Stat("/data/MANIFEST"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBenchmark_Amplification(t *testing.T) {
+	bench, err := newBenchmark(benchOptions(NewMemFS(), "/bench"), BenchConfig{Workloads: []string{"fillseq", "overwrite"}, Num: 500, KeySize: 16, ValueSize: 100, Threads: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bench.destroy()
+	fill, err := bench.run("fillseq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every value is written to the vlog and every key to an sstable at least once
+	if fill.writeAmplification() < 1 || fill.spaceAmplification() < 1 {
+		t.Fatalf("write amplification %f and space amplification %f can't be less than 1", fill.writeAmplification(), fill.spaceAmplification())
+	}
+	overwrite, err := bench.run("overwrite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//old values stay in the vlog until gc
+	if overwrite.spaceAmplification() <= fill.spaceAmplification() {
+		t.Fatalf("space amplification has to grow after overwrite,%f <= %f", overwrite.spaceAmplification(), fill.spaceAmplification())
+	}
+}
+
+//Every workload as a Go benchmark,read workloads run on 10000 keys in 10 sstables
+//go test -run=^$ -bench=Workloads ./pkg
+func BenchmarkWorkloads(b *testing.B) {
+	for _, workload := range BenchWorkloads {
+		b.Run(workload, func(b *testing.B) {
+			config := BenchConfig{Workloads: []string{"fillseq", workload}, Num: 10000, Ops: b.N, KeySize: 16, ValueSize: 100, Threads: 1}
+			if strings.HasPrefix(workload, "fill") {
+				config.Num = b.N
+			}
+			opts := benchOptions(NewOsFS(), filepath.Join(b.TempDir(), "bench"))
+			opts.MemtableSize = 1000
+			bench, err := newBenchmark(opts, config)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer bench.destroy()
+			if !strings.HasPrefix(workload, "fill") {
+				if _, err := bench.run("fillseq"); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ResetTimer()
+			result, err := bench.run(workload)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(result.percentile(99).Nanoseconds()), "p99-ns")
+			b.ReportMetric(result.writeAmplification(), "write-amp")
+			b.ReportMetric(result.spaceAmplification(), "space-amp")
+		})
+	}
+}
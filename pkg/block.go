@@ -0,0 +1,99 @@
+package wiskey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	defaultBlockSize = 4 * 1024 //default size of sstable block in bytes
+	restartInterval  = 16       //how many entries share key prefixes before the next restart point
+)
+
+//Block of sorted sstable entries
+//every entry keeps only the part of the key that differs from the previous key
+//every restartInterval entries the full key is stored,such entry is a restart point
+//restart points are used to binary search inside of the block
+//+---------+-----+---------+-----------------+----------------+
+//| Entry 1 | ... | Entry N | Restart offsets | Restarts count |
+//+---------+-----+---------+-----------------+----------------+
+//Entry
+//+--------+----------+------------+-----------+-------------+-------------+
+//| Shared | Unshared | Key suffix | Timestamp | Vlog offset | Vlog length |
+//+--------+----------+------------+-----------+-------------+-------------+
+//shared and unshared key lengths are uvarints,the lowest bit of unshared is set if the value is inline
+//then value length(uvarint) and value are stored instead of vlog offset and length
+//vlog offset is uint64 and length is uint32
+type blockBuilder struct {
+	buffer   *bytes.Buffer
+	restarts []uint32 //offsets of restart points
+	counter  int      //entries since the last restart point
+	lastKey  []byte
+}
+
+func newBlockBuilder() *blockBuilder {
+	return &blockBuilder{buffer: bytes.NewBuffer([]byte{}), restarts: []uint32{0}}
+}
+
+//Add the entry to the block,entries have to be sorted
+func (block *blockBuilder) add(entry *sstableEntry) {
+	shared := 0
+	if block.counter == restartInterval {
+		block.restarts = append(block.restarts, uint32(block.buffer.Len()))
+		block.counter = 0
+	} else {
+		shared = sharedPrefix(block.lastKey, entry.key)
+	}
+	unshared := uint64(len(entry.key)-shared) << 1
+	if entry.inline {
+		unshared |= 1
+	}
+	block.putUvarint(uint64(shared))
+	block.putUvarint(unshared)
+	block.buffer.Write(entry.key[shared:])
+	binary.Write(block.buffer, binary.BigEndian, entry.timeStamp)
+	if entry.inline {
+		block.putUvarint(uint64(len(entry.value)))
+		block.buffer.Write(entry.value)
+	} else {
+		binary.Write(block.buffer, binary.BigEndian, entry.valueOffset)
+		binary.Write(block.buffer, binary.BigEndian, entry.valueLength)
+	}
+	block.lastKey = append(block.lastKey[:0], entry.key...)
+	block.counter++
+}
+
+func (block *blockBuilder) putUvarint(value uint64) {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	block.buffer.Write(buffer[:binary.PutUvarint(buffer, value)])
+}
+
+func (block *blockBuilder) empty() bool {
+	return block.buffer.Len() == 0
+}
+
+//Size of the block after finish
+func (block *blockBuilder) size() int {
+	return block.buffer.Len() + uint32Size*(len(block.restarts)+1)
+}
+
+//Append restart points and return the block
+func (block *blockBuilder) finish() []byte {
+	for _, restart := range block.restarts {
+		binary.Write(block.buffer, binary.BigEndian, restart)
+	}
+	binary.Write(block.buffer, binary.BigEndian, uint32(len(block.restarts)))
+	return block.buffer.Bytes()
+}
+
+//Length of the common prefix of two keys
+func sharedPrefix(first []byte, second []byte) int {
+	shared := 0
+	for shared < len(first) && shared < len(second) && first[shared] == second[shared] {
+		shared++
+	}
+	return shared
+}
+
+var errCorruptedBlock = fmt.Errorf("%w: invalid block", ErrCorruptedTable)
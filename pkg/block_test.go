@@ -0,0 +1,128 @@
+package wiskey
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeTestTable(t *testing.T, entries []*sstableEntry, blockSize uint32, compressor Compressor) *SSTable {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewWriter(file, blockSize, compressor)
+	for _, entry := range entries {
+		err := writer.WriteEntry(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := os.Open(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := ReadTable(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+//sorted entries with a long shared tenant prefix
+func tenantEntries(amount int) []*sstableEntry {
+	entries := make([]*sstableEntry, amount)
+	for i := range entries {
+		key := []byte(fmt.Sprintf("tenant-0000000001/users/%05d", i))
+		entries[i] = &sstableEntry{key: key, timeStamp: uint64(i), valueOffset: uint64(i), valueLength: 10}
+		if i%3 == 0 {
+			entries[i] = &sstableEntry{key: key, timeStamp: uint64(i), inline: true, value: []byte(fmt.Sprintf("value%d", i))}
+		}
+	}
+	return entries
+}
+
+func TestSSTable_BlockLookup(t *testing.T) {
+	entries := tenantEntries(500)
+	//small blocks force multiple blocks and restart points in every block
+	table := writeTestTable(t, entries, 512, nil)
+	defer os.Remove(table.reader.Name())
+	defer table.Close()
+	if len(table.indexes) < 2 {
+		t.Fatalf("Table had to be split into blocks but has %d", len(table.indexes))
+	}
+	for _, entry := range entries {
+		found, ok, err := table.lookup(entry.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || !bytes.Equal(found.key, entry.key) || found.timeStamp != entry.timeStamp ||
+			found.inline != entry.inline || !bytes.Equal(found.value, entry.value) || found.valueOffset != entry.valueOffset {
+			t.Fatalf("Wrong entry for key %s", entry.key)
+		}
+	}
+	for _, key := range []string{"a", "tenant-0000000001/users/00010a", "tenant-0000000001/users/99999", "z"} {
+		if _, ok, _ := table.lookup([]byte(key)); ok {
+			t.Fatalf("Key %s doesn't exist but was found", key)
+		}
+	}
+	//iterator returns all entries in order
+	iterator := table.iterator()
+	for _, entry := range entries {
+		next, err := iterator.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next == nil || !bytes.Equal(next.key, entry.key) {
+			t.Fatalf("Iterator had to return %s", entry.key)
+		}
+	}
+	if next, _ := iterator.next(); next != nil {
+		t.Fatal("Iterator returned more entries than were written")
+	}
+}
+
+func TestSSTable_PrefixCompression(t *testing.T) {
+	entries := tenantEntries(500)
+	table := writeTestTable(t, entries, defaultBlockSize, nil)
+	defer os.Remove(table.reader.Name())
+	defer table.Close()
+	stat, err := table.reader.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullKeys := 0
+	for _, entry := range entries {
+		fullKeys += len(entry.key)
+	}
+	//shared prefixes are stored only at restart points
+	if stat.Size() >= int64(fullKeys) {
+		t.Fatalf("Table with shared prefixes is %d bytes, keys alone are %d bytes", stat.Size(), fullKeys)
+	}
+}
+
+func TestSSTable_CorruptedBlock(t *testing.T) {
+	if _, err := newBlockReader([]byte{0, 0}); err == nil {
+		t.Fatal("Too short block has to be rejected")
+	}
+	if _, err := newBlockReader([]byte{0, 0, 0, 9}); err == nil {
+		t.Fatal("Block with too many restart points has to be rejected")
+	}
+	block := newBlockBuilder()
+	block.add(&sstableEntry{key: []byte("key"), timeStamp: 1})
+	data := block.finish()
+	//cut the timestamp
+	reader, err := newBlockReader(append(append([]byte{}, data[:5]...), data[len(data)-uint32Size*2:]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.next(); err == nil {
+		t.Fatal("Truncated entry has to be rejected")
+	}
+}
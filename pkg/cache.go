@@ -0,0 +1,180 @@
+package wiskey
+
+import (
+	"container/list"
+	"sync"
+)
+
+//Hit and miss counters of a cache
+type CacheStats struct {
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Size     int    `json:"size"`     //bytes currently stored in the cache
+	Capacity int    `json:"capacity"` //max amount of bytes in the cache
+}
+
+//Part of lookups that were served from the cache
+func (stats CacheStats) HitRate() float64 {
+	total := stats.Hits + stats.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(stats.Hits) / float64(total)
+}
+
+//lru cache bounded by the size of stored values in bytes
+type lruCache struct {
+	mutex    sync.Mutex
+	capacity int
+	size     int
+	items    map[interface{}]*list.Element
+	order    *list.List //the most recently used item goes first
+	hits     uint64
+	misses   uint64
+}
+
+type lruItem struct {
+	key   interface{}
+	value interface{}
+	size  int
+}
+
+func newLruCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		items:    make(map[interface{}]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (cache *lruCache) get(key interface{}) (interface{}, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, found := cache.items[key]
+	if !found {
+		cache.misses++
+		return nil, false
+	}
+	cache.hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*lruItem).value, true
+}
+
+//Save the value,the least recently used values are evicted until it fits
+func (cache *lruCache) put(key interface{}, value interface{}, size int) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	//doesn't fit at all
+	if size > cache.capacity {
+		return
+	}
+	if element, found := cache.items[key]; found {
+		cache.removeElement(element)
+	}
+	for cache.size+size > cache.capacity {
+		cache.removeElement(cache.order.Back())
+	}
+	cache.items[key] = cache.order.PushFront(&lruItem{key: key, value: value, size: size})
+	cache.size += size
+}
+
+func (cache *lruCache) remove(key interface{}) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, found := cache.items[key]; found {
+		cache.removeElement(element)
+	}
+}
+
+//Remove all items with keys matching the predicate
+func (cache *lruCache) removeIf(predicate func(key interface{}) bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for key, element := range cache.items {
+		if predicate(key) {
+			cache.removeElement(element)
+		}
+	}
+}
+
+//Keys of all cached items
+func (cache *lruCache) keys() []interface{} {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	keys := make([]interface{}, 0, len(cache.items))
+	for key := range cache.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (cache *lruCache) clear() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.items = make(map[interface{}]*list.Element)
+	cache.order.Init()
+	cache.size = 0
+}
+
+func (cache *lruCache) removeElement(element *list.Element) {
+	item := cache.order.Remove(element).(*lruItem)
+	delete(cache.items, item.key)
+	cache.size -= item.size
+}
+
+func (cache *lruCache) stats() CacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return CacheStats{Hits: cache.hits, Misses: cache.misses, Size: cache.size, Capacity: cache.capacity}
+}
+
+//Cache of sstable blocks shared by all sstables of the tree
+//blocks are keyed by the table id and the offset of the block in the table
+type blockCache struct {
+	cache  *lruCache
+	mutex  sync.Mutex
+	tables map[string]uint64 //table path to table id
+	nextId uint64
+}
+
+type blockKey struct {
+	table  uint64
+	offset uint64
+}
+
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{cache: newLruCache(capacity), tables: make(map[string]uint64)}
+}
+
+//Id of the table with given path
+func (cache *blockCache) tableId(path string) uint64 {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	id, found := cache.tables[path]
+	if !found {
+		cache.nextId++
+		id = cache.nextId
+		cache.tables[path] = id
+	}
+	return id
+}
+
+//The table was removed or changed,it gets a new id so its old blocks are never read again
+//they will be evicted eventually
+func (cache *blockCache) forget(path string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	delete(cache.tables, path)
+}
+
+func (cache *blockCache) get(table uint64, offset uint64) ([]byte, bool) {
+	block, found := cache.cache.get(blockKey{table: table, offset: offset})
+	if !found {
+		return nil, false
+	}
+	return block.([]byte), true
+}
+
+func (cache *blockCache) put(table uint64, offset uint64, block []byte) {
+	cache.cache.put(blockKey{table: table, offset: offset}, block, len(block))
+}
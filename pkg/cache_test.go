@@ -0,0 +1,130 @@
+package wiskey
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLruCache_Eviction(t *testing.T) {
+	cache := newLruCache(10)
+	cache.put("first", []byte("1234"), 4)
+	cache.put("second", []byte("1234"), 4)
+	//first becomes the most recently used
+	if _, found := cache.get("first"); !found {
+		t.Fatal("Value wasn't found in cache")
+	}
+	//doesn't fit,second has to be evicted
+	cache.put("third", []byte("1234"), 4)
+	if _, found := cache.get("second"); found {
+		t.Fatal("Least recently used value had to be evicted")
+	}
+	if _, found := cache.get("first"); !found {
+		t.Fatal("Recently used value was evicted")
+	}
+	//bigger than the whole cache
+	cache.put("huge", make([]byte, 11), 11)
+	if _, found := cache.get("huge"); found {
+		t.Fatal("Value bigger than capacity was cached")
+	}
+	stats := cache.stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Size != 8 {
+		t.Fatalf("Wrong cache stats %+v", stats)
+	}
+}
+
+func TestLsmTree_BlockCache(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	tree.SetBlockCache(1024)
+	entries := FakeEntries()
+	for _, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		for _, entry := range entries {
+			value, err := tree.Get(entry.key)
+			if err != nil || bytes.Compare(value, entry.value) != 0 {
+				t.Fatal("Wrong value with block cache")
+			}
+		}
+	}
+	stats := tree.BlockCacheStats()
+	if stats.Hits == 0 || stats.Misses == 0 {
+		t.Fatalf("Second round of reads had to hit the cache %+v", stats)
+	}
+	//merge removes tables,their blocks are never read again
+	tree.rwm.Lock()
+	removed := tree.sstables[0]
+	id := tree.blockCache.tableId(removed)
+	tree.forgetCachedBlocks(removed)
+	tree.rwm.Unlock()
+	if tree.blockCache.tableId(removed) == id {
+		t.Fatal("Removed table has to get a new id")
+	}
+}
+
+func TestLsmTree_ValueCache(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	tree.SetValueCache(1024)
+	entries := FakeEntries()
+	for _, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		for _, entry := range entries {
+			value, err := tree.Get(entry.key)
+			if err != nil || bytes.Compare(value, entry.value) != 0 {
+				t.Fatal("Wrong value with value cache")
+			}
+		}
+	}
+	stats := tree.ValueCacheStats()
+	if stats.Hits != uint64(len(entries)) {
+		t.Fatalf("Second round of reads had to hit the cache %+v", stats)
+	}
+	//gc moves entries,their old offsets must not be served from the cache
+	err = tree.CompressVlog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range tree.log.cache.keys() {
+		if key.(uint64) < tree.log.tail {
+			t.Fatalf("Entry at collected offset %d is still cached", key)
+		}
+	}
+	for _, entry := range entries {
+		value, err := tree.Get(entry.key)
+		if err != nil || bytes.Compare(value, entry.value) != 0 {
+			t.Fatal("Wrong value after gc with value cache")
+		}
+	}
+	//overwritten value isn't kept in the cache
+	err = tree.Put(&TableEntry{key: []byte("ZNITA"), value: []byte("DEVELOPER7")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := tree.memtable.Get([]byte("ZNITA"))
+	tree.Get([]byte("ZNITA"))
+	err = tree.Put(&TableEntry{key: []byte("ZNITA"), value: []byte("MANAGER")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range tree.log.cache.keys() {
+		if key.(uint64) == meta.offset {
+			t.Fatal("Overwritten value is still cached")
+		}
+	}
+}
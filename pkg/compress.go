@@ -0,0 +1,154 @@
+package wiskey
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+const (
+	noCompression    = 0 //id of uncompressed sstable blocks
+	flateCompression = 1
+)
+
+//Compressed data doesn't inflate to the length that was stored with it
+var errCorruptedCompression = fmt.Errorf("%w: compressed data doesn't match its length", ErrCorruption)
+
+//Codec for sstable blocks and vlog values
+//the id is stored next to compressed data,so data can be read with any configured compressor
+type Compressor interface {
+	Id() byte //unique id of the codec,0 means no compression
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	//size is the length of the uncompressed data,nothing after it has to be read
+	Decompress(data []byte, size int) ([]byte, error)
+}
+
+var compressors = struct {
+	sync.RWMutex
+	byId   map[byte]Compressor
+	byName map[string]Compressor
+}{byId: make(map[byte]Compressor), byName: make(map[string]Compressor)}
+
+func init() {
+	err := RegisterCompressor(&flateCompressor{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//Make the compressor available for reading and by name
+func RegisterCompressor(compressor Compressor) error {
+	compressors.Lock()
+	defer compressors.Unlock()
+	if compressor.Id() == noCompression {
+		return fmt.Errorf("compressor id %d is reserved for uncompressed data", noCompression)
+	}
+	if existing, ok := compressors.byId[compressor.Id()]; ok {
+		return fmt.Errorf("compressor %q has the same id as %q", compressor.Name(), existing.Name())
+	}
+	if _, ok := compressors.byName[compressor.Name()]; ok {
+		return fmt.Errorf("compressor %q is already registered", compressor.Name())
+	}
+	compressors.byId[compressor.Id()] = compressor
+	compressors.byName[compressor.Name()] = compressor
+	return nil
+}
+
+//Find the compressor by name,"none" means no compression and returns nil
+func CompressorByName(name string) (Compressor, error) {
+	if name == "none" || name == "" {
+		return nil, nil
+	}
+	compressors.RLock()
+	defer compressors.RUnlock()
+	compressor, ok := compressors.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q", name)
+	}
+	return compressor, nil
+}
+
+//Compress the data if it makes it smaller
+//Returns compressed data and the id of the compressor or the same data with noCompression id
+//compressed data starts with the uncompressed length as uvarint,so a corrupted record can't inflate without a limit
+func compress(compressor Compressor, data []byte) ([]byte, byte, error) {
+	if compressor == nil {
+		return data, noCompression, nil
+	}
+	output, err := compressor.Compress(data)
+	if err != nil {
+		return nil, noCompression, err
+	}
+	compressed := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(output))
+	compressed = append(compressed[:binary.PutUvarint(compressed, uint64(len(data)))], output...)
+	//not worth to spend time on decompression
+	if len(compressed) >= len(data)-len(data)/8 {
+		return data, noCompression, nil
+	}
+	return compressed, compressor.Id(), nil
+}
+
+func decompress(id byte, data []byte) ([]byte, error) {
+	if id == noCompression {
+		return data, nil
+	}
+	compressors.RLock()
+	compressor, ok := compressors.byId[id]
+	compressors.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown compression type %d", id)
+	}
+	size, read := binary.Uvarint(data)
+	if read <= 0 || size > math.MaxInt32 {
+		return nil, errCorruptedCompression
+	}
+	decompressed, err := compressor.Decompress(data[read:], int(size))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruption, err)
+	}
+	if len(decompressed) != int(size) {
+		return nil, errCorruptedCompression
+	}
+	return decompressed, nil
+}
+
+//Deflate from the standard library
+type flateCompressor struct{}
+
+func (*flateCompressor) Id() byte {
+	return flateCompression
+}
+
+func (*flateCompressor) Name() string {
+	return "flate"
+}
+
+func (*flateCompressor) Compress(data []byte) ([]byte, error) {
+	buffer := bytes.NewBuffer([]byte{})
+	writer, err := flate.NewWriter(buffer, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+//One byte more than the size is read,so data that inflates further is detected without inflating all of it
+func (*flateCompressor) Decompress(data []byte, size int) ([]byte, error) {
+	reader := flate.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+	//the size can be corrupted as well,so the buffer grows with the inflated data
+	buffer := bytes.NewBuffer([]byte{})
+	_, err := buffer.ReadFrom(io.LimitReader(reader, int64(size)+1))
+	return buffer.Bytes(), err
+}
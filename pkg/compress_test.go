@@ -0,0 +1,133 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func jsonValue(i int) []byte {
+	return []byte(fmt.Sprintf(`{"id":%d,"name":"developer","team":"storage","skills":["go","lsm","vlog"],"active":true}`, i))
+}
+
+func TestCompressor_Flate(t *testing.T) {
+	compressor, err := CompressorByName("flate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat(jsonValue(1), 10)
+	compressed, id, err := compress(compressor, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != flateCompression || len(compressed) >= len(data) {
+		t.Fatalf("Repeated json had to be compressed, %d bytes became %d", len(data), len(compressed))
+	}
+	decompressed, err := decompress(id, compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("Decompressed data doesn't match")
+	}
+	//small values are stored as is
+	if _, id, _ := compress(compressor, []byte("ab")); id != noCompression {
+		t.Fatal("Value that doesn't shrink has to be stored uncompressed")
+	}
+	//data that inflates past the stored length is a corruption
+	bomb, _ := (&flateCompressor{}).Compress(make([]byte, 1<<20))
+	if _, err := decompress(flateCompression, append([]byte{10}, bomb...)); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Data longer than the stored length has to be a corruption, got %v", err)
+	}
+	if _, err := decompress(flateCompression, append([]byte{0x80, 0x80, 0x80, 0x80, 0x10}, bomb...)); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Data shorter than the stored length has to be a corruption, got %v", err)
+	}
+	if _, err := decompress(200, compressed); err == nil {
+		t.Fatal("Unknown compression type has to fail")
+	}
+	if _, err := CompressorByName("brotli"); err == nil {
+		t.Fatal("Unknown compressor name has to fail")
+	}
+	if err := RegisterCompressor(&flateCompressor{}); err == nil {
+		t.Fatal("Compressor can't be registered twice")
+	}
+}
+
+func TestSSTable_CompressedBlocks(t *testing.T) {
+	compressor, _ := CompressorByName("flate")
+	entries := tenantEntries(500)
+	plain := writeTestTable(t, entries, defaultBlockSize, nil)
+	defer os.Remove(plain.reader.Name())
+	defer plain.Close()
+	compressed := writeTestTable(t, entries, defaultBlockSize, compressor)
+	defer os.Remove(compressed.reader.Name())
+	defer compressed.Close()
+	plainStat, _ := plain.reader.Stat()
+	compressedStat, _ := compressed.reader.Stat()
+	if compressedStat.Size() >= plainStat.Size() {
+		t.Fatalf("Compressed table is %d bytes,plain is %d", compressedStat.Size(), plainStat.Size())
+	}
+	for _, entry := range entries {
+		found, ok, err := compressed.lookup(entry.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || found.timeStamp != entry.timeStamp || !bytes.Equal(found.value, entry.value) {
+			t.Fatalf("Wrong entry for key %s in compressed table", entry.key)
+		}
+	}
+}
+
+func TestLsmTree_Compression(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 1000, 30)
+	compressor, _ := CompressorByName("flate")
+	users, err := tree.CreateColumnFamily("users", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//only the family is compressed
+	users.SetCompressor(compressor)
+	value := bytes.Repeat(jsonValue(1), 5)
+	err = users.Put(&TableEntry{key: []byte("ANITA"), value: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressedSize := tree.log.size
+	err = tree.Put(&TableEntry{key: []byte("ANITA"), value: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compressedSize >= tree.log.size-compressedSize {
+		t.Fatalf("Compressed vlog record is %d bytes,plain is %d", compressedSize, tree.log.size-compressedSize)
+	}
+	batch := NewWriteBatch()
+	batch.Put("users", []byte("BNITA"), value)
+	err = tree.Write(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(tree *LsmTree, users *LsmTree) {
+		for _, family := range []*LsmTree{tree, users} {
+			found, err := family.Get([]byte("ANITA"))
+			if err != nil || !bytes.Equal(found, value) {
+				t.Fatal("Wrong value of compressed entry")
+			}
+		}
+		found, err := users.Get([]byte("BNITA"))
+		if err != nil || !bytes.Equal(found, value) {
+			t.Fatal("Wrong value of compressed batch entry")
+		}
+	}
+	check(tree, users)
+	err = tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(tree, users)
+	//compression type is stored with data,so it's readable without configured compressor
+	newTree := reopenTestLsm(t, tree, 1000)
+	newUsers, _ := newTree.ColumnFamily("users")
+	check(newTree, newUsers)
+}
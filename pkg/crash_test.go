@@ -0,0 +1,184 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+const (
+	crashPut = iota
+	crashDelete
+	crashFlush
+	crashMerge
+	crashGc
+)
+
+type crashOp struct {
+	kind  int
+	key   []byte
+	value []byte
+}
+
+func (op crashOp) String() string {
+	return fmt.Sprintf("%s(%s)", []string{"put", "delete", "flush", "merge", "gc"}[op.kind], op.key)
+}
+
+//Keys are taken from a small set,so keys are overwritten and deleted often
+func randomCrashWorkload(rnd *rand.Rand, length int) []crashOp {
+	ops := make([]crashOp, length)
+	for i := range ops {
+		op := crashOp{key: []byte(fmt.Sprintf("key-%02d", rnd.Intn(12)))}
+		switch n := rnd.Intn(100); {
+		case n < 55:
+			op.kind = crashPut
+			op.value = []byte(fmt.Sprintf("value-%d-%s", i, bytes.Repeat([]byte("v"), rnd.Intn(40))))
+		case n < 75:
+			op.kind = crashDelete
+		case n < 85:
+			op.kind = crashFlush
+		case n < 92:
+			op.kind = crashMerge
+		default:
+			op.kind = crashGc
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+func crashOptions(fs FS, sync SyncMode) Options {
+	//workers are disabled,merges and gc are part of the workload
+	return Options{Dir: "/data", FS: fs, SyncMode: sync, MemtableSize: 4, GcEntries: 3, CompactionStrategy: NoCompaction}
+}
+
+//Values that every key may have after a crash
+type crashModel struct {
+	durable map[string][]byte   //value that has to be recovered,nil means the key is deleted
+	pending map[string][][]byte //values that may or may not be recovered
+}
+
+func newCrashModel() *crashModel {
+	return &crashModel{durable: make(map[string][]byte), pending: make(map[string][][]byte)}
+}
+
+//The write may be recovered,but it's not guaranteed
+func (model *crashModel) maybe(op crashOp) {
+	model.pending[string(op.key)] = append(model.pending[string(op.key)], op.value)
+}
+
+//All writes up to now have to be recovered
+func (model *crashModel) persist() {
+	for key, values := range model.pending {
+		model.durable[key] = values[len(values)-1]
+	}
+	model.pending = make(map[string][][]byte)
+}
+
+func sameValue(first []byte, second []byte) bool {
+	return bytes.Equal(first, second) && (first == nil) == (second == nil)
+}
+
+//Run the workload until the first error,the running operation may or may not be applied
+//with SyncAlways every acknowledged write is durable,otherwise only writes before a successful Flush
+func runCrashWorkload(fs FS, sync SyncMode, ops []crashOp) (*crashModel, error) {
+	model := newCrashModel()
+	db, err := Open(crashOptions(fs, sync))
+	if err != nil {
+		return model, err
+	}
+	for _, op := range ops {
+		switch op.kind {
+		case crashPut:
+			err = db.Put(&TableEntry{key: op.key, value: op.value})
+		case crashDelete:
+			err = db.Delete(op.key)
+		case crashFlush:
+			err = db.Flush()
+		case crashMerge:
+			err = db.Merge()
+		case crashGc:
+			err = db.CompressVlog()
+		}
+		if op.kind == crashPut || op.kind == crashDelete {
+			model.maybe(op)
+		}
+		if err != nil {
+			return model, err
+		}
+		if sync == SyncAlways || op.kind == crashFlush {
+			model.persist()
+		}
+	}
+	return model, db.Close()
+}
+
+//Every key has one of the allowed values,keys that were never written must not appear
+func checkRecovered(db *DB, model *crashModel) error {
+	for i := 0; i < 12; i++ {
+		key := fmt.Sprintf("key-%02d", i)
+		value, err := db.Get([]byte(key))
+		if errors.Is(err, ErrNotFound) {
+			value, err = nil, nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		allowed := append([][]byte{model.durable[key]}, model.pending[key]...)
+		found := false
+		for _, candidate := range allowed {
+			found = found || sameValue(value, candidate)
+		}
+		if !found {
+			return fmt.Errorf("%s: recovered %q,expected one of %q", key, value, allowed)
+		}
+	}
+	return nil
+}
+
+//Crash the workload at every sync point,recover and compare the state with acknowledged writes
+func TestCrashConsistency(t *testing.T) {
+	seeds, length := 6, 60
+	if testing.Short() {
+		seeds = 2
+	}
+	for _, sync := range []SyncMode{SyncAlways, SyncNone} {
+		for seed := int64(1); seed <= int64(seeds); seed++ {
+			ops := randomCrashWorkload(rand.New(rand.NewSource(seed)), length)
+			fs := NewFaultFS()
+			if _, err := runCrashWorkload(fs, sync, ops); err != nil {
+				t.Fatalf("sync %s,seed %d: workload failed without crash: %v", sync, seed, err)
+			}
+			syncs := fs.Syncs()
+			for point := 0; point <= syncs; point++ {
+				for _, torn := range []bool{false, true} {
+					fs := NewFaultFS()
+					fs.FailAfterSyncs(point)
+					model, _ := runCrashWorkload(fs, sync, ops)
+					if torn {
+						fs.CrashTorn(rand.New(rand.NewSource(seed*1000 + int64(point))))
+					} else {
+						fs.Crash()
+					}
+					name := fmt.Sprintf("sync %s,seed %d,crash at sync %d of %d,torn %v", sync, seed, point, syncs, torn)
+					db, err := Open(crashOptions(fs, sync))
+					if err != nil {
+						t.Fatalf("%s: reopen failed: %v", name, err)
+					}
+					if err := checkRecovered(db, model); err != nil {
+						t.Fatalf("%s: %v", name, err)
+					}
+					//recovered database keeps working
+					if err := db.Put(&TableEntry{key: []byte("key-00"), value: []byte("after crash")}); err != nil {
+						t.Fatalf("%s: put after recovery failed: %v", name, err)
+					}
+					if err := db.Close(); err != nil {
+						t.Fatalf("%s: close after recovery failed: %v", name, err)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package wiskey
+
+import (
+	"errors"
+	"io"
+)
+
+//Database with background workers,it has to be closed by Close
+//the data directory is locked until Close,so only one DB can use it
+type DB struct {
+	*LsmTree
+	lock io.Closer //exclusive lock on LOCK file
+}
+
+//Open the database and start background workers,zero options are replaced by defaults
+//with ReadOnly the database has to exist and Close doesn't flush anything
+//if Dir is set then all paths that aren't set explicitly are taken from its MANIFEST,
+//otherwise the lock is taken in the sstable directory
+func Open(opts Options) (*DB, error) {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	var lock io.Closer
+	var err error
+	if opts.Dir != "" {
+		lock, err = openDataDir(&opts)
+	} else if opts.ReadOnly {
+		if _, err = opts.FS.Stat(opts.SStableDir); err == nil {
+			lock, err = lockDir(opts.FS, opts.SStableDir)
+		}
+	} else if err = opts.FS.MkdirAll(opts.SStableDir, 0755); err == nil {
+		lock, err = lockDir(opts.FS, opts.SStableDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var log *vlog
+	if opts.ReadOnly {
+		log, err = openReadOnlyVlog(opts.FS, opts.VlogPath, opts.CheckpointPath)
+	} else {
+		log, err = newVlog(opts.FS, opts.VlogPath, opts.CheckpointPath)
+	}
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	log.sync = opts.SyncMode == SyncAlways
+	tree, err := openLsmTree(log, opts.SStableDir, NewMemTable(opts.MemtableSize), opts)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	//a read only tree doesn't run workers,so the garbage estimate of Stats is sampled once
+	if opts.ReadOnly {
+		err = tree.estimateGarbage()
+	} else {
+		tree.startWorkers()
+	}
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return &DB{LsmTree: tree, lock: lock}, nil
+}
+
+//Flush all memtables,persist the vlog head,stop background workers and release the lock
+//the lock is released even if the flush failed,so the data can be recovered by the next Open
+func (db *DB) Close() error {
+	err := db.LsmTree.Close()
+	if errors.Is(err, ErrClosed) {
+		return err
+	}
+	if unlockErr := db.lock.Close(); err == nil {
+		err = unlockErr
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func testOptions(t *testing.T) Options {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Options{
+		SStableDir:         dir + "/sst",
+		VlogPath:           dir + "/vlog",
+		CheckpointPath:     dir + "/checkpoint",
+		MemtableSize:       100,
+		CompactionInterval: 10 * time.Millisecond,
+	}
+}
+
+func TestDB_OpenClose(t *testing.T) {
+	opts := testOptions(t)
+	defer os.RemoveAll(filepath.Dir(opts.SStableDir))
+	goroutines := runtime.NumGoroutine()
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		err = db.Put(&TableEntry{key: []byte{byte('A' + i)}, value: []byte("DEVELOPER")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.Put(&TableEntry{key: []byte("ANITA"), value: []byte("DEVELOPER")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//let the compaction run at least once
+	time.Sleep(50 * time.Millisecond)
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.NumGoroutine() > goroutines {
+		t.Fatalf("Background workers are still running,%d goroutines instead of %d", runtime.NumGoroutine(), goroutines)
+	}
+	if _, err := db.Get([]byte("ANITA")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after close has to return ErrClosed, got %v", err)
+	}
+	if err := db.Merge(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Merge after close has to return ErrClosed, got %v", err)
+	}
+	if err := db.Close(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Second close has to return ErrClosed, got %v", err)
+	}
+	//memtable and head were persisted,nothing is restored from the vlog
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.memtable.Size() != 0 {
+		t.Fatalf("Head wasn't persisted,%d entries were restored", db.memtable.Size())
+	}
+	for _, key := range [][]byte{[]byte("A"), []byte("C"), []byte("ANITA")} {
+		value, err := db.Get(key)
+		if err != nil || !bytes.Equal(value, []byte("DEVELOPER")) {
+			t.Fatalf("Value of %s was lost after close, got %v", key, err)
+		}
+	}
+	if _, err := Open(Options{}); err == nil {
+		t.Fatal("Empty options have to be rejected")
+	}
+}
@@ -0,0 +1,180 @@
+package wiskey
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	lockFile        = "LOCK"
+	currentFile     = "CURRENT"
+	manifestFile    = "MANIFEST"
+	manifestVersion = 1
+	sstableSubdir   = "sst"
+	vlogSubdir      = "vlog"
+)
+
+//Returned by Open if another process or DB in this process uses the same data
+var ErrLocked = errors.New("database is locked by another process")
+
+//Layout of the data directory,it's created on the first Open and never changes
+//paths are relative to the data directory unless they are absolute
+//+-----------------------------+
+//| wiskey-manifest <version>   |
+//| comparator <name>           |
+//| sstables <path>             |
+//| vlog <path>                 |
+//| checkpoint <path>           |
+//+-----------------------------+
+//CURRENT keeps the name of the manifest,it's written after the manifest so a crash in the middle of
+//creation leaves a directory without CURRENT that is created again on the next Open
+type manifest struct {
+	comparator string
+	sstables   string
+	vlog       string
+	checkpoint string
+}
+
+//Layout of a new data directory
+func newManifest(comparator string) *manifest {
+	return &manifest{
+		comparator: comparator,
+		sstables:   sstableSubdir,
+		vlog:       filepath.Join(vlogSubdir, "000001.vlog"),
+		checkpoint: filepath.Join(vlogSubdir, "checkpoint"),
+	}
+}
+
+func (m *manifest) asByteArray() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	fmt.Fprintf(buffer, "wiskey-manifest %d\n", manifestVersion)
+	fmt.Fprintf(buffer, "comparator %s\n", m.comparator)
+	fmt.Fprintf(buffer, "sstables %s\n", m.sstables)
+	fmt.Fprintf(buffer, "vlog %s\n", m.vlog)
+	fmt.Fprintf(buffer, "checkpoint %s\n", m.checkpoint)
+	return buffer.Bytes()
+}
+
+func parseManifest(data []byte) (*manifest, error) {
+	m := &manifest{}
+	fields := map[string]*string{"comparator": &m.comparator, "sstables": &m.sstables, "vlog": &m.vlog, "checkpoint": &m.checkpoint}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || scanner.Text() != "wiskey-manifest "+strconv.Itoa(manifestVersion) {
+		return nil, fmt.Errorf("%w: unsupported manifest", ErrCorruption)
+	}
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), " ", 2)
+		field, ok := fields[parts[0]]
+		if !ok || len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("%w: invalid manifest line %q", ErrCorruption, scanner.Text())
+		}
+		*field = parts[1]
+		delete(fields, parts[0])
+	}
+	if len(fields) != 0 {
+		return nil, fmt.Errorf("%w: manifest is incomplete", ErrCorruption)
+	}
+	return m, nil
+}
+
+//Read the manifest that CURRENT points to or create a new one
+func loadManifest(fs FS, dir string, comparator string) (*manifest, error) {
+	current, err := readFile(fs, filepath.Join(dir, currentFile))
+	if errors.Is(err, os.ErrNotExist) {
+		m := newManifest(comparator)
+		if err := writeFileAtomically(fs, filepath.Join(dir, manifestFile), m.asByteArray()); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomically(fs, filepath.Join(dir, currentFile), []byte(manifestFile+"\n")); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(string(current))
+	if name == "" || filepath.Base(name) != name {
+		return nil, fmt.Errorf("%w: CURRENT points to %q", ErrCorruption, name)
+	}
+	data, err := readFile(fs, filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: manifest %s is missing", ErrCorruption, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	if m.comparator != comparator {
+		return nil, fmt.Errorf("database was created with comparator %q,not %q", m.comparator, comparator)
+	}
+	return m, nil
+}
+
+//Create the data directory,lock it and fill paths that weren't overridden
+//a read only open requires an existing data directory and creates only the LOCK file
+//the lock has to be released by the caller
+func openDataDir(opts *Options) (io.Closer, error) {
+	if opts.ReadOnly {
+		if _, err := opts.FS.Stat(filepath.Join(opts.Dir, currentFile)); err != nil {
+			return nil, fmt.Errorf("%s isn't a data directory: %w", opts.Dir, err)
+		}
+	} else if err := opts.FS.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+	lock, err := lockDir(opts.FS, opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loadManifest(opts.FS, opts.Dir, opts.Comparator)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(opts.Dir, path)
+	}
+	if opts.SStableDir == "" {
+		opts.SStableDir = resolve(m.sstables)
+	}
+	if opts.VlogPath == "" {
+		opts.VlogPath = resolve(m.vlog)
+	}
+	if opts.CheckpointPath == "" {
+		opts.CheckpointPath = resolve(m.checkpoint)
+	}
+	//directories of a read only database have to exist already
+	if opts.ReadOnly {
+		return lock, nil
+	}
+	for _, path := range []string{opts.SStableDir, filepath.Dir(opts.VlogPath), filepath.Dir(opts.CheckpointPath)} {
+		if err := opts.FS.MkdirAll(path, 0755); err != nil {
+			lock.Close()
+			return nil, err
+		}
+	}
+	return lock, nil
+}
+
+//Take an exclusive lock on dir/LOCK
+func lockDir(fs FS, dir string) (io.Closer, error) {
+	path := filepath.Join(dir, lockFile)
+	lock, err := fs.Lock(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrLocked, path, err)
+	}
+	return lock, nil
+}
@@ -0,0 +1,178 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_DataDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"sst", "vlog", "MANIFEST", "CURRENT", "LOCK"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Fatalf("%s wasn't created in the data dir", path)
+		}
+	}
+	//the second opener has to fail until the first one is closed
+	if _, err := Open(Options{Dir: dir}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Second open has to return ErrLocked, got %v", err)
+	}
+	err = db.Put(&TableEntry{key: []byte("ANITA"), value: []byte("DEVELOPER")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := db.Get([]byte("ANITA"))
+	if err != nil || !bytes.Equal(value, []byte("DEVELOPER")) {
+		t.Fatalf("Value was lost after reopen, got %v", err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "MANIFEST"), []byte("broken"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(Options{Dir: dir}); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Broken manifest has to return ErrCorruption, got %v", err)
+	}
+}
+
+func TestDB_DataDirOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vlog := filepath.Join(dir, "elsewhere", "vlog")
+	db, err := Open(Options{Dir: filepath.Join(dir, "data"), VlogPath: vlog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.log.file != vlog || db.sstableDir != filepath.Join(dir, "data", "sst") {
+		t.Fatalf("Overrides weren't applied,vlog %s sstables %s", db.log.file, db.sstableDir)
+	}
+	//without data dir the sstable dir is locked
+	opts := testOptions(t)
+	defer os.RemoveAll(filepath.Dir(opts.SStableDir))
+	first, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	if _, err := Open(opts); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Second open of the same sstable dir has to return ErrLocked, got %v", err)
+	}
+}
+
+//Paths and contents of all files in the directory
+func dirContents(t *testing.T, dir string) map[string]string {
+	contents := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		contents[path] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contents
+}
+
+func TestDB_ReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	missing := filepath.Join(dir, "missing")
+	if _, err := Open(Options{Dir: missing, ReadOnly: true}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Read only open of a missing dir has to fail, got %v", err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Fatal("Read only open created the data dir")
+	}
+	db, err := Open(Options{Dir: dir, CompactionStrategy: NoCompaction})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"ANITA", "BNITA"} {
+		if err := db.Put(&TableEntry{key: []byte(key), value: []byte("DEVELOPER")}); err != nil {
+			t.Fatal(err)
+		}
+		if key == "ANITA" {
+			if err := db.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	//the process died,BNITA is only in the vlog
+	db.lock.Close()
+	before := dirContents(t, dir)
+	readOnly, err := Open(Options{Dir: dir, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"ANITA", "BNITA"} {
+		if value, err := readOnly.Get([]byte(key)); err != nil || string(value) != "DEVELOPER" {
+			t.Fatalf("Read only open returned %q %v for %s", value, err, key)
+		}
+	}
+	if err := readOnly.Put(&TableEntry{key: []byte("CNITA"), value: []byte("DEVELOPER")}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Put has to return ErrReadOnly, got %v", err)
+	}
+	if err := readOnly.Flush(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Flush has to return ErrReadOnly, got %v", err)
+	}
+	if _, err := readOnly.CreateColumnFamily("users", 100); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("New column family has to return ErrReadOnly, got %v", err)
+	}
+	//workers don't run,so the garbage estimate is sampled by Open
+	if stats, err := readOnly.Stats(); err != nil || stats.Vlog.SampledBytes == 0 {
+		t.Fatalf("Read only stats returned %+v %v", stats.Vlog, err)
+	}
+	if err := readOnly.Close(); err != nil {
+		t.Fatal(err)
+	}
+	after := dirContents(t, dir)
+	if len(after) != len(before) {
+		t.Fatalf("Files were changed by read only open,%d before and %d after", len(before), len(after))
+	}
+	for path, content := range before {
+		if after[path] != content {
+			t.Fatalf("%s was changed by read only open", path)
+		}
+	}
+	//the entry is still restored by the next read write open
+	db, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if value, err := db.Get([]byte("BNITA")); err != nil || string(value) != "DEVELOPER" {
+		t.Fatalf("Unflushed entry was lost after read only open, got %q %v", value, err)
+	}
+}
@@ -3,74 +3,59 @@ package wiskey
 import (
 	"bytes"
 	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"time"
 )
 
+const (
+	keyLengthMask     = 0x00FFFFFF //the lowest 3 bytes of the key length in vlog keep the actual length
+	rangeDeletionFlag = 1 << 31    //vlog entry is a range tombstone, key is the start and value is the end of the range
+	familyFlag        = 1 << 30    //vlog entry belongs to a named column family, the family id follows the value length
+	batchFlag         = 1 << 29    //vlog entry is an atomic batch, value keeps all entries of the batch
+	compressedFlag    = 1 << 28    //vlog value is compressed, the first byte of the value is the compressor id
+	checksumFlag      = 1 << 27    //vlog entry ends with crc32 of its bytes, entries written before it don't have it
+	inlineValueFlag   = 1 << 31    //sstable entry keeps the value itself instead of the vlog offset
+)
 
 // SSTABLE Entry
 type sstableEntry struct {
 	key         []byte //key
 	timeStamp   uint64 //when it was created
-	valueOffset uint32 //offset of the value to read
+	valueOffset uint64 //offset of the value to read
 	valueLength uint32 //the length of the value
+	inline      bool   //small value that is stored in sstable instead of offset and length
+	value       []byte //inlined value
 }
 
 func DeletedSstableEntry(key []byte) *sstableEntry {
 	return &sstableEntry{
 		key:       key,
-		timeStamp: uint64(time.Now().Unix()),
+		timeStamp: uint64(time.Now().UnixNano()),
 	}
 }
 
 func NewSStableEntry(key []byte, meta *ValueMeta) *sstableEntry {
 	return &sstableEntry{
 		key:         key,
-		timeStamp:   uint64(time.Now().Unix()),
+		timeStamp:   uint64(time.Now().UnixNano()),
 		valueOffset: meta.offset,
 		valueLength: meta.length,
+		inline:      meta.inline,
+		value:       meta.value,
 	}
 }
 
-//write entry to sstable
-//Format [key length + key +  timestamp + meta + offset + length]
-// +------------+-----+-----------+------------+------------+
-// | Key Length | Key | timestamp | vlogoffset | vloglength |
-// +------------+-----+-----------+------------+------------+
-func (entry *sstableEntry) writeTo(writer io.Writer) (uint32, error) {
-	buffer := bytes.NewBuffer([]byte{})
-	//key length
-	if err := binary.Write(buffer, binary.BigEndian, uint32(len(entry.key))); err != nil {
-		return 0, err
-	}
-	//key
-	if err := binary.Write(buffer, binary.BigEndian, entry.key); err != nil {
-		return 0, err
-	}
-	//timestamp
-	if err := binary.Write(buffer, binary.BigEndian, entry.timeStamp); err != nil {
-		return 0, err
-	}
-	//offset
-	if err := binary.Write(buffer, binary.BigEndian, entry.valueOffset); err != nil {
-		return 0, err
-	}
-	//length
-	if err := binary.Write(buffer, binary.BigEndian, entry.valueLength); err != nil {
-		return 0, err
-	}
-	length, err := writer.Write(buffer.Bytes())
-	return uint32(length), err
-}
-
 /// TableEntry
 
 // entries that are stored in the vlog file
 // key and value are byte arrays so they support anything that
 // can be converted to byte array
 type TableEntry struct {
-	key   []byte
-	value []byte
+	key    []byte
+	value  []byte
+	flags  uint32 //stored in the highest byte of the key length
+	family uint32 //column family id, 0 is the default family
 }
 
 func DeletedEntry(key []byte) *TableEntry {
@@ -80,24 +65,76 @@ func DeletedEntry(key []byte) *TableEntry {
 	}
 }
 
+//Range tombstone that deletes all keys in [start,end)
+func DeletedRangeEntry(start []byte, end []byte) *TableEntry {
+	return &TableEntry{
+		key:   start,
+		value: end,
+		flags: rangeDeletionFlag,
+	}
+}
+
+func (entry *TableEntry) isRangeDeletion() bool {
+	return entry.flags&rangeDeletionFlag != 0
+}
+
+func (entry *TableEntry) isBatch() bool {
+	return entry.flags&batchFlag != 0
+}
+
+//Copy of the entry that belongs to given column family
+func (entry TableEntry) withFamily(family uint32) *TableEntry {
+	entry.family = family
+	return &entry
+}
+
+//Copy of the entry with compressed value,the entry is returned as is if compression doesn't help
+func (entry *TableEntry) compressed(compressor Compressor) (*TableEntry, error) {
+	value, compression, err := compress(compressor, entry.value)
+	if err != nil {
+		return nil, err
+	}
+	if compression == noCompression {
+		return entry, nil
+	}
+	copied := *entry
+	copied.value = append([]byte{compression}, value...)
+	copied.flags |= compressedFlag
+	return &copied, nil
+}
+
 func NewEntry(key []byte, value []byte) TableEntry {
 	return TableEntry{key: key, value: value}
 }
 
 //Write entry to vlog
-//+------------+--------------+-----+-------+
-//| Key Length | Value length | Key | Value |
-//+------------+--------------+-----+-------+
+//the highest byte of the key length keeps entry flags
+//family is written only for named column families
+//compressed value starts with the id of the compressor
+//checksum is crc32 of all bytes before it,so a torn entry with valid lengths isn't read as data
+//+------------+--------------+--------+-----+-------+----------+
+//| Key Length | Value length | Family | Key | Value | Checksum |
+//+------------+--------------+--------+-----+-------+----------+
 func (entry *TableEntry) writeTo(writer io.Writer) (uint32, error) {
 	buffer := bytes.NewBuffer([]byte{})
+	flags := entry.flags | checksumFlag
+	if entry.family != 0 {
+		flags |= familyFlag
+	}
 	//key length
-	if err := binary.Write(buffer, binary.BigEndian, uint32(len(entry.key))); err != nil {
+	if err := binary.Write(buffer, binary.BigEndian, uint32(len(entry.key))|flags); err != nil {
 		return 0, err
 	}
 	//value length
 	if err := binary.Write(buffer, binary.BigEndian, uint32(len(entry.value))); err != nil {
 		return 0, err
 	}
+	//family
+	if entry.family != 0 {
+		if err := binary.Write(buffer, binary.BigEndian, entry.family); err != nil {
+			return 0, err
+		}
+	}
 	//key
 	if err := binary.Write(buffer, binary.BigEndian, entry.key); err != nil {
 		return 0, err
@@ -106,6 +143,10 @@ func (entry *TableEntry) writeTo(writer io.Writer) (uint32, error) {
 	if err := binary.Write(buffer, binary.BigEndian, entry.value); err != nil {
 		return 0, err
 	}
+	//checksum
+	if err := binary.Write(buffer, binary.BigEndian, crc32.Checksum(buffer.Bytes(), crcTable)); err != nil {
+		return 0, err
+	}
 	length, err := writer.Write(buffer.Bytes())
 	return uint32(length), err
 }
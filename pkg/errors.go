@@ -0,0 +1,27 @@
+package wiskey
+
+import (
+	"errors"
+	"fmt"
+)
+
+const maxKeyLength = keyLengthMask //key length shares uint32 with vlog entry flags
+
+var (
+	ErrNotFound    = errors.New("key not found")
+	ErrCorruption  = errors.New("corruption")
+	ErrClosed      = errors.New("lsm tree is closed")
+	ErrReadOnly    = errors.New("lsm tree is opened read only")
+	ErrKeyTooLarge = fmt.Errorf("key is longer than %d bytes", maxKeyLength)
+
+	//a required value of the write batch was changed,nothing was written
+	ErrConditionFailed = errors.New("write batch condition failed")
+)
+
+//Check that the key fits into vlog and sstable entries
+func validateKey(key []byte) error {
+	if len(key) > maxKeyLength {
+		return ErrKeyTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestLsmTree_Errors(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	if _, err := tree.Get([]byte("ANITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Missing key has to return ErrNotFound, got %v", err)
+	}
+	huge := make([]byte, maxKeyLength+1)
+	if err := tree.Put(&TableEntry{key: huge, value: []byte("V")}); !errors.Is(err, ErrKeyTooLarge) {
+		t.Fatalf("Huge key has to be rejected, got %v", err)
+	}
+	err := tree.Put(&TableEntry{key: []byte("ANITA"), value: []byte("DEVELOPER")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Get([]byte("ANITA")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after close has to return ErrClosed, got %v", err)
+	}
+	if err := tree.Put(&TableEntry{key: []byte("BNITA"), value: []byte("V")}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Put after close has to return ErrClosed, got %v", err)
+	}
+	if err := tree.Close(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Second close has to return ErrClosed, got %v", err)
+	}
+	//close flushed the memtable
+	newTree := reopenTestLsm(t, tree, 100)
+	value, err := newTree.Get([]byte("ANITA"))
+	if err != nil || !bytes.Equal(value, []byte("DEVELOPER")) {
+		t.Fatal("Value wasn't flushed on close")
+	}
+}
+
+func TestLsmTree_CorruptionIsReturned(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	err := tree.Put(&TableEntry{key: []byte("ANITA"), value: []byte("DEVELOPER")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a file that only looks like sstable
+	err = writeFile(tree.fs(), tree.sstableDir+"/broken.sstable", []byte("broken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.sstables = append(tree.sstables, tree.sstableDir+"/broken.sstable")
+	if _, err := tree.Get([]byte("ANITA")); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Broken sstable has to return ErrCorruption, got %v", err)
+	}
+	if _, _, err := tree.MultiGet([][]byte{[]byte("ANITA")}); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Broken sstable has to return ErrCorruption from MultiGet, got %v", err)
+	}
+	tree.sstables = tree.sstables[:len(tree.sstables)-1]
+	//vlog entry was cut
+	err = tree.fs().Truncate(tree.log.file, int64(tree.log.size-3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Get([]byte("ANITA")); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Truncated vlog entry has to return ErrCorruption, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package wiskey
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	defaultFamily = "default" //name of the column family that is stored in the root of sstable directory
+)
+
+var familyName = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+//Column family id that is saved with every vlog entry of this family
+func familyId(name string) uint32 {
+	return crc32.ChecksumIEEE([]byte(name))
+}
+
+//Create a tree for the named column family,it shares vlog and lock with the root tree
+//sstables of the family are stored in the subdirectory of root sstable directory
+func newColumnFamily(root *LsmTree, name string, memtableSize int) (*LsmTree, error) {
+	if !familyName.MatchString(name) || name == defaultFamily {
+		return nil, fmt.Errorf("invalid column family name %q", name)
+	}
+	id := familyId(name)
+	if id == 0 {
+		return nil, fmt.Errorf("column family name %q is reserved", name)
+	}
+	for existingName, family := range root.families {
+		if family.family == id {
+			return nil, fmt.Errorf("column family %q has the same id as %q", name, existingName)
+		}
+	}
+	family := &LsmTree{
+		rwm:        root.rwm,
+		log:        root.log,
+		sstableDir: root.sstableDir + "/" + name,
+		memtable:   NewMemTable(memtableSize),
+		deleted:    make(map[string]bool),
+		family:     id,
+		root:       root,
+		//families inherit the threshold and compression of the default family
+		valueThreshold: root.valueThreshold,
+		compressor:     root.compressor,
+	}
+	err := family.fs().MkdirAll(family.sstableDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = family.fillSstables()
+	if err != nil {
+		return nil, err
+	}
+	tombstones, err := readRangeTombstones(family.fs(), family.rangeTombstonesPath())
+	if err != nil {
+		return nil, err
+	}
+	family.rangeTombstones = tombstones
+	return family, nil
+}
+
+//Open all column families that were created before,every subdirectory of sstable directory is a family
+//they get the same memtable size as the default family
+func (lsm *LsmTree) loadColumnFamilies() error {
+	files, err := lsm.fs().ReadDir(lsm.sstableDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			family, err := newColumnFamily(lsm, f.Name(), lsm.memtable.maxSize)
+			if err != nil {
+				return err
+			}
+			lsm.families[f.Name()] = family
+		}
+	}
+	return nil
+}
+
+//Create a new column family or return the existing one
+//Column families have their own memtable and sstables but share the vlog,
+//so a WriteBatch that touches multiple families is atomic
+func (lsm *LsmTree) CreateColumnFamily(name string, memtableSize int) (*LsmTree, error) {
+	root := lsm.root
+	root.rwm.Lock()
+	defer root.rwm.Unlock()
+	if family, ok := root.families[name]; ok {
+		family.memtable.maxSize = memtableSize
+		return family, nil
+	}
+	if root.options.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	family, err := newColumnFamily(root, name, memtableSize)
+	if err != nil {
+		return nil, err
+	}
+	root.families[name] = family
+	return family, nil
+}
+
+//Find the column family by name
+func (lsm *LsmTree) ColumnFamily(name string) (*LsmTree, bool) {
+	root := lsm.root
+	if name == defaultFamily || name == "" {
+		return root, true
+	}
+	root.rwm.RLock()
+	defer root.rwm.RUnlock()
+	family, ok := root.families[name]
+	return family, ok
+}
+
+//Get the key from every given column family under a single lock,so a concurrent batch is seen whole or not at all
+//Returns values and found flags in the same order as given families,keys of unknown families aren't found
+func (lsm *LsmTree) GetFromFamilies(key []byte, names []string) ([][]byte, []bool, error) {
+	defer lsm.root.stats.operation(opGet, time.Now())
+	root := lsm.root
+	root.rwm.RLock()
+	defer root.rwm.RUnlock()
+	if root.closed {
+		return nil, nil, ErrClosed
+	}
+	values := make([][]byte, len(names))
+	found := make([]bool, len(names))
+	for i, name := range names {
+		family, ok := root.familyByName(name)
+		if !ok {
+			continue
+		}
+		value, err := family.get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i], found[i] = value, true
+	}
+	return values, found, nil
+}
+
+//Names of all column families,the default one goes first
+func (lsm *LsmTree) ColumnFamilies() []string {
+	root := lsm.root
+	root.rwm.RLock()
+	defer root.rwm.RUnlock()
+	names := []string{defaultFamily}
+	for name := range root.families {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+//Find the column family by name,the lock has to be held
+func (lsm *LsmTree) familyByName(name string) (*LsmTree, bool) {
+	root := lsm.root
+	if name == defaultFamily || name == "" {
+		return root, true
+	}
+	family, ok := root.families[name]
+	return family, ok
+}
+
+//Find the column family by id that is saved in the vlog
+func (lsm *LsmTree) familyById(id uint32) *LsmTree {
+	root := lsm.root
+	if id == 0 {
+		return root
+	}
+	for _, family := range root.families {
+		if family.family == id {
+			return family
+		}
+	}
+	return nil
+}
+
+//The default family goes first,then named families sorted by name
+func (lsm *LsmTree) allFamilies() []*LsmTree {
+	root := lsm.root
+	names := make([]string, 0, len(root.families))
+	for name := range root.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := []*LsmTree{root}
+	for _, name := range names {
+		families = append(families, root.families[name])
+	}
+	return families
+}
@@ -0,0 +1,212 @@
+package wiskey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLsmTree_ColumnFamilies(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	users, err := tree.CreateColumnFamily("users", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.CreateColumnFamily("bad/name", 20); err == nil {
+		t.Fatal("Column family name with slash has to be rejected")
+	}
+	entries := FakeEntries()
+	//small memtable of the family forces flushes
+	for _, entry := range entries {
+		err := users.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tree.Put(&TableEntry{key: []byte("ANITA"), value: []byte("DEFAULT")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.sstables) == 0 {
+		t.Fatal("Column family had to be flushed")
+	}
+	//families don't see each other keys
+	if _, err := tree.Get([]byte("BNITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Key of column family was found in the default family")
+	}
+	value, err := users.Get([]byte("ANITA"))
+	if err != nil || string(value) != "DEVELOPER" {
+		t.Fatal("Wrong value in column family")
+	}
+	//after restart families are found in sstable directory and restored from the shared vlog
+	newTree := reopenTestLsm(t, tree, 100)
+	newUsers, ok := newTree.ColumnFamily("users")
+	if !ok {
+		t.Fatal("Column family wasn't restored")
+	}
+	for _, entry := range entries {
+		value, err := newUsers.Get(entry.key)
+		if err != nil || string(value) != string(entry.value) {
+			t.Fatalf("Key %s wasn't restored in column family", entry.key)
+		}
+	}
+	value, err = newTree.Get([]byte("ANITA"))
+	if err != nil || string(value) != "DEFAULT" {
+		t.Fatal("Key of default family wasn't restored")
+	}
+}
+
+func TestLsmTree_WriteBatch(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	users, err := tree.CreateColumnFamily("users", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Put(&TableEntry{key: []byte("GNITA"), value: []byte("DEVELOPER3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := NewWriteBatch()
+	batch.Put("users", []byte("ANITA"), []byte("DEVELOPER"))
+	batch.Put(defaultFamily, []byte("BNITA"), []byte("DEVELOPER2"))
+	batch.Delete(defaultFamily, []byte("GNITA"))
+	err = tree.Write(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Get([]byte("ANITA")); err != nil {
+		t.Fatal("Key from batch wasn't found in column family")
+	}
+	if _, err := tree.Get([]byte("BNITA")); err != nil {
+		t.Fatal("Key from batch wasn't found in default family")
+	}
+	if _, err := tree.Get([]byte("GNITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Key deleted by batch was found")
+	}
+	unknown := NewWriteBatch()
+	unknown.Put("orders", []byte("ANITA"), []byte("DEVELOPER"))
+	if err := tree.Write(unknown); err == nil {
+		t.Fatal("Batch with unknown column family has to fail")
+	}
+	//second batch is torn by a crash, none of its entries has to be restored
+	torn := NewWriteBatch()
+	torn.Put("users", []byte("NNITA"), []byte("DEVELOPER4"))
+	torn.Put(defaultFamily, []byte("TNITA"), []byte("DEVELOPER5"))
+	err = tree.Write(torn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.fs().Truncate(tree.log.file, int64(tree.log.size-3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTree := reopenTestLsm(t, tree, 100)
+	newUsers, _ := newTree.ColumnFamily("users")
+	if _, err := newUsers.Get([]byte("ANITA")); err != nil {
+		t.Fatal("Key from the first batch wasn't restored")
+	}
+	if _, err := newTree.Get([]byte("BNITA")); err != nil {
+		t.Fatal("Key from the first batch wasn't restored")
+	}
+	if _, err := newUsers.Get([]byte("NNITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Key from the torn batch was restored")
+	}
+	if _, err := newTree.Get([]byte("TNITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Key from the torn batch was restored")
+	}
+	//torn entry was cut from the vlog so new entries can be appended
+	err = newTree.Put(&TableEntry{key: []byte("WNITA"), value: []byte("DEVELOPER6")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := newTree.Get([]byte("WNITA"))
+	if err != nil || string(value) != "DEVELOPER6" {
+		t.Fatal("Wrong value after restore of torn vlog")
+	}
+}
+
+//Deletes of missing keys are dropped from the batch,a batch of them writes nothing
+func TestLsmTree_WriteBatchDeleteExisting(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	users, err := tree.CreateColumnFamily("users", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = users.Put(&TableEntry{key: []byte("ANITA"), value: []byte("DEVELOPER")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := tree.log.size
+	missing := NewWriteBatch()
+	missing.DeleteExisting("users", []byte("BNITA"))
+	if err := tree.Write(missing); err != nil {
+		t.Fatal(err)
+	}
+	if tree.log.size != size || users.deleted["BNITA"] {
+		t.Fatal("Tombstone of a missing key was written")
+	}
+	batch := NewWriteBatch()
+	batch.Put("", []byte("ANITA"), []byte("DEVELOPER2"))
+	batch.DeleteExisting("users", []byte("ANITA"))
+	batch.DeleteExisting("users", []byte("BNITA"))
+	if err := tree.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+	values, found, err := tree.GetFromFamilies([]byte("ANITA"), []string{"", "users", "orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found[0] || string(values[0]) != "DEVELOPER2" || found[1] || found[2] {
+		t.Fatalf("Wrong values after the batch %q %v", values, found)
+	}
+	if users.deleted["BNITA"] {
+		t.Fatal("Tombstone of a missing key was written")
+	}
+	//tombstones are kept in memory only until they are flushed
+	if err := tree.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(users.deleted) != 0 {
+		t.Fatalf("Flushed tombstones are still in memory %v", users.deleted)
+	}
+	if _, err := users.Get([]byte("ANITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Flushed tombstone doesn't delete the key")
+	}
+}
+
+func TestLsmTree_WriteBatchRequire(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	if _, err := tree.CreateColumnFamily("users", 100); err != nil {
+		t.Fatal(err)
+	}
+	batch := NewWriteBatch()
+	batch.Put("users", []byte("ANITA"), []byte("1"))
+	if err := tree.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+	size := tree.log.size
+	for _, required := range [][]byte{[]byte("2"), nil} {
+		key := []byte("ANITA")
+		if required == nil {
+			key = []byte("BNITA")
+		}
+		conditional := NewWriteBatch()
+		conditional.Require("users", key, required)
+		conditional.Delete("users", []byte("ANITA"))
+		if err := tree.Write(conditional); !errors.Is(err, ErrConditionFailed) {
+			t.Fatalf("Batch with a changed or missing value has to fail, got %v", err)
+		}
+	}
+	if tree.log.size != size {
+		t.Fatal("Failed batch was written")
+	}
+	conditional := NewWriteBatch()
+	conditional.Require("users", []byte("ANITA"), []byte("1"))
+	conditional.Delete("users", []byte("ANITA"))
+	if err := tree.Write(conditional); err != nil {
+		t.Fatal(err)
+	}
+	users, _ := tree.ColumnFamily("users")
+	if _, err := users.Get([]byte("ANITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Batch with the required value wasn't written")
+	}
+}
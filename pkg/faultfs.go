@@ -0,0 +1,228 @@
+package wiskey
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"syscall"
+)
+
+//In memory file system that injects failures,it's used to test crashes and io errors
+//Crash drops every write that wasn't synced,FailWritesAfter tears writes after given amount of bytes,
+//FailAfterSyncs stops the disk at a sync point and FailWith makes every operation return the error
+type FaultFS struct {
+	*MemFS
+	mutex     sync.Mutex
+	budget    int64 //bytes that still can be written,negative means unlimited
+	syncsLeft int   //syncs that still succeed,negative means unlimited
+	syncs     int   //successful syncs
+	err       error //returned by every operation,nil disables it
+}
+
+func NewFaultFS() *FaultFS {
+	return &FaultFS{MemFS: NewMemFS(), budget: -1, syncsLeft: -1}
+}
+
+//Simulate power loss,everything that wasn't synced is lost and injected failures are cleared
+func (fs *FaultFS) Crash() {
+	fs.reset()
+	fs.MemFS.dropUnsynced(nil)
+}
+
+//Simulate power loss where the disk persisted a random prefix of unsynced appends to every file
+//writes that overwrote synced data are still lost
+func (fs *FaultFS) CrashTorn(rnd *rand.Rand) {
+	fs.reset()
+	fs.MemFS.dropUnsynced(rnd)
+}
+
+func (fs *FaultFS) reset() {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.budget = -1
+	fs.syncsLeft = -1
+	fs.err = nil
+}
+
+//After n more successful syncs the disk stops,the next sync and every following operation fail with EIO
+//negative n removes the limit
+func (fs *FaultFS) FailAfterSyncs(n int) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.syncsLeft = n
+}
+
+//How many syncs succeeded,it's used to find all sync points of a workload
+func (fs *FaultFS) Syncs() int {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.syncs
+}
+
+//Count the sync or stop the disk if it was the last allowed one
+func (fs *FaultFS) allowSync(name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if fs.err != nil {
+		return pathError("sync", name, fs.err)
+	}
+	if fs.syncsLeft == 0 {
+		fs.err = syscall.EIO
+		return pathError("sync", name, fs.err)
+	}
+	if fs.syncsLeft > 0 {
+		fs.syncsLeft--
+	}
+	fs.syncs++
+	return nil
+}
+
+//Writes fail with EIO after n more bytes,the write that crosses the limit is partially applied
+//negative n removes the limit
+func (fs *FaultFS) FailWritesAfter(n int64) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.budget = n
+}
+
+//Every following operation returns err,for example syscall.EIO,nil stops failing
+func (fs *FaultFS) FailWith(err error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.err = err
+}
+
+func (fs *FaultFS) injected(op string, name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if fs.err != nil {
+		return pathError(op, name, fs.err)
+	}
+	return nil
+}
+
+//How many bytes of the write are allowed,error is returned if the write has to be torn
+func (fs *FaultFS) allowWrite(name string, length int) (int, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if fs.err != nil {
+		return 0, pathError("write", name, fs.err)
+	}
+	if fs.budget < 0 {
+		return length, nil
+	}
+	if int64(length) <= fs.budget {
+		fs.budget -= int64(length)
+		return length, nil
+	}
+	allowed := int(fs.budget)
+	fs.budget = 0
+	return allowed, pathError("write", name, syscall.EIO)
+}
+
+func (fs *FaultFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if err := fs.injected("open", name); err != nil {
+		return nil, err
+	}
+	file, err := fs.MemFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{File: file, fs: fs}, nil
+}
+
+func (fs *FaultFS) Remove(name string) error {
+	if err := fs.injected("remove", name); err != nil {
+		return err
+	}
+	return fs.MemFS.Remove(name)
+}
+
+func (fs *FaultFS) Rename(oldpath string, newpath string) error {
+	if err := fs.injected("rename", oldpath); err != nil {
+		return err
+	}
+	return fs.MemFS.Rename(oldpath, newpath)
+}
+
+func (fs *FaultFS) Stat(name string) (os.FileInfo, error) {
+	if err := fs.injected("stat", name); err != nil {
+		return nil, err
+	}
+	return fs.MemFS.Stat(name)
+}
+
+func (fs *FaultFS) MkdirAll(path string, perm os.FileMode) error {
+	if err := fs.injected("mkdir", path); err != nil {
+		return err
+	}
+	return fs.MemFS.MkdirAll(path, perm)
+}
+
+func (fs *FaultFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	if err := fs.injected("open", dirname); err != nil {
+		return nil, err
+	}
+	return fs.MemFS.ReadDir(dirname)
+}
+
+func (fs *FaultFS) Truncate(name string, size int64) error {
+	if err := fs.injected("truncate", name); err != nil {
+		return err
+	}
+	return fs.MemFS.Truncate(name, size)
+}
+
+//Directory sync is a sync point as well
+func (fs *FaultFS) SyncDir(dirname string) error {
+	if err := fs.allowSync(dirname); err != nil {
+		return err
+	}
+	return fs.MemFS.SyncDir(dirname)
+}
+
+func (fs *FaultFS) Lock(name string) (io.Closer, error) {
+	if err := fs.injected("lock", name); err != nil {
+		return nil, err
+	}
+	return fs.MemFS.Lock(name)
+}
+
+type faultFile struct {
+	File
+	fs *FaultFS
+}
+
+func (file *faultFile) Read(buffer []byte) (int, error) {
+	if err := file.fs.injected("read", file.Name()); err != nil {
+		return 0, err
+	}
+	return file.File.Read(buffer)
+}
+
+func (file *faultFile) ReadAt(buffer []byte, offset int64) (int, error) {
+	if err := file.fs.injected("read", file.Name()); err != nil {
+		return 0, err
+	}
+	return file.File.ReadAt(buffer, offset)
+}
+
+func (file *faultFile) Write(buffer []byte) (int, error) {
+	allowed, err := file.fs.allowWrite(file.Name(), len(buffer))
+	if allowed == 0 {
+		return 0, err
+	}
+	written, writeErr := file.File.Write(buffer[:allowed])
+	if writeErr != nil {
+		return written, writeErr
+	}
+	return written, err
+}
+
+func (file *faultFile) Sync() error {
+	if err := file.fs.allowSync(file.Name()); err != nil {
+		return err
+	}
+	return file.File.Sync()
+}
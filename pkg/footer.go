@@ -3,57 +3,131 @@ package wiskey
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 )
 
 const (
-	footerSize = 4 //how many bytes are in the footer(indexOffset)
+	footerSize    = blockHandleSize*3 + uint32Size*2 + int64Size //how many bytes are in the footer
+	tableMagic    = 0x7769736b65797374                           //"wiskeyst"
+	formatVersion = 1                                            //prefix compressed blocks with compression type and checksum
+	checksumSize  = uint32Size
 )
 
-//footer in the sstable file, it shows where the index starts in the file
-type Footer struct {
-	indexOffset uint32 // the Offset where indexes starts
+//all of them are ErrCorruption
+var (
+	ErrNotSSTable        = fmt.Errorf("%w: file is not an sstable", ErrCorruption)
+	ErrUnsupportedFormat = fmt.Errorf("%w: unsupported sstable format", ErrCorruption)
+	ErrChecksumMismatch  = fmt.Errorf("%w: sstable checksum mismatch", ErrCorruption)
+	ErrCorruptedTable    = fmt.Errorf("%w: sstable is corrupted", ErrCorruption)
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+//Position of a block in the sstable
+type blockHandle struct {
+	offset uint64
+	length uint64
 }
 
-func DefaultFooter() *Footer {
-	return &Footer{
-		indexOffset: 0,
-	}
+const blockHandleSize = int64Size * 2
+
+//footer in the sstable file, it shows where the index,filter and properties are in the file
+//+--------------+---------------+-------------------+---------+-----+-------+
+//| Index handle | Filter handle | Properties handle | Version | CRC | Magic |
+//+--------------+---------------+-------------------+---------+-----+-------+
+//every handle is offset and length,filter is reserved and empty for now
+//crc covers handles and version
+type Footer struct {
+	index      blockHandle
+	filter     blockHandle
+	properties blockHandle
+	version    uint32
 }
 
-func NewFooter(buffer []byte) *Footer {
+//Parse the footer and validate it
+func NewFooter(buffer []byte) (*Footer, error) {
 	if len(buffer) != footerSize {
-		panic("Invalid header length")
+		return nil, fmt.Errorf("%w: footer has %d bytes", ErrNotSSTable, len(buffer))
 	}
-	offset := binary.BigEndian.Uint32(buffer[:footerSize])
-	return &Footer{indexOffset: offset}
+	if binary.BigEndian.Uint64(buffer[footerSize-int64Size:]) != tableMagic {
+		return nil, fmt.Errorf("%w: wrong magic number", ErrNotSSTable)
+	}
+	crcOffset := blockHandleSize*3 + uint32Size
+	if crc32.Checksum(buffer[:crcOffset], crcTable) != binary.BigEndian.Uint32(buffer[crcOffset:]) {
+		return nil, fmt.Errorf("%w: footer", ErrChecksumMismatch)
+	}
+	footer := &Footer{}
+	handles := []*blockHandle{&footer.index, &footer.filter, &footer.properties}
+	for i, handle := range handles {
+		handle.offset = binary.BigEndian.Uint64(buffer[i*blockHandleSize:])
+		handle.length = binary.BigEndian.Uint64(buffer[i*blockHandleSize+int64Size:])
+	}
+	footer.version = binary.BigEndian.Uint32(buffer[blockHandleSize*3:])
+	if footer.version != formatVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrUnsupportedFormat, footer.version)
+	}
+	return footer, nil
 }
 
-//save the header in the given writeCloser
-func (h *Footer) writeTo(writer io.Writer) int {
-	offset, err := writer.Write(h.asByteArray())
-	if err != nil {
-		panic(err)
-	}
-	return offset
+//save the footer in the given writeCloser
+func (h *Footer) writeTo(writer io.Writer) (int, error) {
+	return writer.Write(h.asByteArray())
 }
 
-//convert header to binary array
+//convert footer to binary array
 func (h *Footer) asByteArray() []byte {
 	buffer := bytes.NewBuffer(make([]byte, 0, footerSize))
-	err := binary.Write(buffer, binary.BigEndian, h)
-	if err != nil {
-		panic(err)
+	for _, handle := range []blockHandle{h.index, h.filter, h.properties} {
+		binary.Write(buffer, binary.BigEndian, handle.offset)
+		binary.Write(buffer, binary.BigEndian, handle.length)
 	}
+	binary.Write(buffer, binary.BigEndian, h.version)
+	binary.Write(buffer, binary.BigEndian, crc32.Checksum(buffer.Bytes(), crcTable))
+	binary.Write(buffer, binary.BigEndian, uint64(tableMagic))
 	return buffer.Bytes()
 }
 
-
 //Read the footer
-func readFooter(stats os.FileInfo, reader *os.File) *Footer {
+func readFooter(stats os.FileInfo, reader io.ReaderAt) (*Footer, error) {
+	if stats.Size() < footerSize {
+		return nil, fmt.Errorf("%w: file has only %d bytes", ErrNotSSTable, stats.Size())
+	}
 	buf := make([]byte, footerSize)
-	reader.Seek(stats.Size()-footerSize, 0)
-	reader.Read(buf)
-	return NewFooter(buf)
+	if _, err := reader.ReadAt(buf, stats.Size()-footerSize); err != nil {
+		return nil, err
+	}
+	footer, err := NewFooter(buf)
+	if err != nil {
+		return nil, err
+	}
+	//all blocks have to be before the footer
+	end := uint64(stats.Size() - footerSize)
+	for _, handle := range []blockHandle{footer.index, footer.filter, footer.properties} {
+		if handle.offset > end || handle.length > end-handle.offset {
+			return nil, fmt.Errorf("%w: block handle is out of the file", ErrCorruptedTable)
+		}
+	}
+	return footer, nil
+}
+
+//Append the checksum to the block
+func withChecksum(block []byte) []byte {
+	checksum := make([]byte, checksumSize)
+	binary.BigEndian.PutUint32(checksum, crc32.Checksum(block, crcTable))
+	return append(block, checksum...)
+}
+
+//Validate the checksum at the end of the block and return the block without it
+func verifyChecksum(block []byte) ([]byte, error) {
+	if len(block) < checksumSize {
+		return nil, fmt.Errorf("%w: block is shorter than checksum", ErrCorruptedTable)
+	}
+	end := len(block) - checksumSize
+	if crc32.Checksum(block[:end], crcTable) != binary.BigEndian.Uint32(block[end:]) {
+		return nil, ErrChecksumMismatch
+	}
+	return block[:end], nil
 }
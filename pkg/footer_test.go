@@ -1,6 +1,8 @@
 package wiskey
 
 import (
+	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -10,13 +12,102 @@ func TestFooterWriteAndRead(t *testing.T) {
 	file, _ := ioutil.TempFile("", "")
 	defer file.Close()
 	defer os.Remove(file.Name())
-	header := Footer{ indexOffset: 100}
+	header := Footer{index: blockHandle{offset: 100, length: 20}, properties: blockHandle{offset: 120, length: 30}, version: formatVersion}
 	writeToFile(file.Name(), header)
 	buf := readFromFile(file.Name())
-	headerFromFile := NewFooter(buf)
+	headerFromFile, err := NewFooter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *headerFromFile != header {
+		t.Error("Footers don't match")
+	}
+	//any changed byte is detected
+	for i := range buf {
+		changed := append([]byte{}, buf...)
+		changed[i] ^= 1
+		if _, err := NewFooter(changed); err == nil {
+			t.Fatalf("Changed byte %d of footer wasn't detected", i)
+		}
+	}
+	if _, err := NewFooter(buf[1:]); !errors.Is(err, ErrNotSSTable) {
+		t.Fatalf("Short footer has to be rejected, got %v", err)
+	}
+}
 
-	if headerFromFile.indexOffset != header.indexOffset {
-		t.Error("Index offsets don't match")
+//Handles and index offsets of tables bigger than 4GiB don't wrap
+func TestFooter_HandlesAbove4GiB(t *testing.T) {
+	footer := Footer{index: blockHandle{offset: 5 << 30, length: 1 << 20}, properties: blockHandle{offset: 5<<30 + 1<<20, length: 30}, version: formatVersion}
+	parsed, err := NewFooter(footer.asByteArray())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *parsed != footer {
+		t.Fatalf("Footer %+v was read as %+v", footer, *parsed)
+	}
+	buffer := bytes.NewBuffer([]byte{})
+	index := tableIndex{Offset: 5 << 30, BlockLength: 4096, LastKey: []byte("key")}
+	index.writeTo(buffer)
+	indexes, err := readIndexBlock(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 1 || indexes[0].Offset != index.Offset || indexes[0].BlockLength != index.BlockLength {
+		t.Fatalf("Index %+v was read as %+v", index, indexes)
+	}
+}
+
+func TestSSTable_Validation(t *testing.T) {
+	entries := tenantEntries(100)
+	table := writeTestTable(t, entries, 512, nil)
+	defer os.Remove(table.reader.Name())
+	table.Close()
+	properties := table.Properties()
+	if properties.Entries != uint64(len(entries)) || properties.Comparator != bytewiseComparator ||
+		!bytes.Equal(properties.MinKey, entries[0].key) || !bytes.Equal(properties.MaxKey, entries[len(entries)-1].key) ||
+		properties.MinSequence != 0 || properties.MaxSequence != uint64(len(entries)-1) || properties.CreatedAt.IsZero() {
+		t.Fatalf("Wrong table properties %+v", properties)
+	}
+	content, err := ioutil.ReadFile(table.reader.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func(content []byte) error {
+		file, _ := ioutil.TempFile("", "")
+		defer os.Remove(file.Name())
+		file.Write(content)
+		file.Close()
+		reader, _ := os.Open(file.Name())
+		defer reader.Close()
+		table, err := ReadTable(reader, nil)
+		if err != nil {
+			return err
+		}
+		//data blocks are validated when they are read
+		for _, entry := range entries {
+			if _, _, err := table.lookup(entry.key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := open(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := open([]byte("not an sstable")); !errors.Is(err, ErrNotSSTable) {
+		t.Fatalf("Short file has to be rejected, got %v", err)
+	}
+	if err := open(content[:len(content)-10]); !errors.Is(err, ErrNotSSTable) {
+		t.Fatalf("Truncated file has to be rejected, got %v", err)
+	}
+	//change a byte in the first data block,in the index and in the properties
+	footer, _ := NewFooter(content[len(content)-footerSize:])
+	for _, position := range []uint64{10, footer.index.offset + 1, footer.properties.offset + 1} {
+		changed := append([]byte{}, content...)
+		changed[position] ^= 1
+		if err := open(changed); !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("Changed byte %d has to fail the checksum, got %v", position, err)
+		}
 	}
 }
 
@@ -24,7 +115,7 @@ func readFromFile(fileName string) []byte {
 	reader, _ := os.Open(fileName)
 	buf := make([]byte, footerSize)
 	stats, _ := reader.Stat()
-	reader.Seek(stats.Size()-footerSize,0)
+	reader.Seek(stats.Size()-footerSize, 0)
 	reader.Read(buf)
 	reader.Close()
 	return buf
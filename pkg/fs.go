@@ -0,0 +1,158 @@
+package wiskey
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+//File system used by the engine,all files of vlog,sstables,checkpoint and manifest go through it
+//see NewOsFS,NewMemFS and NewFaultFS
+type FS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Remove(name string) error
+	Rename(oldpath string, newpath string) error
+	Stat(name string) (os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	//entries of the directory sorted by name
+	ReadDir(dirname string) ([]os.FileInfo, error)
+	Truncate(name string, size int64) error
+	//Make created,renamed and removed entries of the directory survive a crash
+	SyncDir(dirname string) error
+	//Take an exclusive lock on the file,it's released by Close of the returned closer
+	Lock(name string) (io.Closer, error)
+}
+
+//Opened file,*os.File implements it
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+	Sync() error
+	Stat() (os.FileInfo, error)
+	Name() string
+}
+
+//File system of the operating system
+type osFS struct{}
+
+func NewOsFS() FS {
+	return osFS{}
+}
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	file, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) Rename(oldpath string, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	file, err := os.Open(dirname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	infos, err := file.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+func (osFS) Truncate(name string, size int64) error {
+	return os.Truncate(name, size)
+}
+
+func (osFS) SyncDir(dirname string) error {
+	return syncDir(dirname)
+}
+
+func (osFS) Lock(name string) (io.Closer, error) {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFileExclusive(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &osLock{file: file}, nil
+}
+
+//flock is released with the file
+type osLock struct {
+	file *os.File
+}
+
+func (lock *osLock) Close() error {
+	if err := unlockFile(lock.file); err != nil {
+		lock.file.Close()
+		return err
+	}
+	return lock.file.Close()
+}
+
+//Read the whole file
+func readFile(fs FS, name string) ([]byte, error) {
+	file, err := fs.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	buffer := bytes.NewBuffer([]byte{})
+	_, err = io.Copy(buffer, file)
+	return buffer.Bytes(), err
+}
+
+//Replace the content of the file and sync it
+func writeFile(fs FS, name string, data []byte) error {
+	file, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//write to temp file first so a crash doesn't leave half written file
+//the temp file is synced before rename,otherwise a crash can leave the new name with empty content
+//and the directory is synced after it,otherwise a crash can bring the old content back
+func writeFileAtomically(fs FS, path string, data []byte) error {
+	tempPath := path + ".tmp"
+	if err := writeFile(fs, tempPath, data); err != nil {
+		return err
+	}
+	if err := fs.Rename(tempPath, path); err != nil {
+		return err
+	}
+	return fs.SyncDir(filepath.Dir(path))
+}
@@ -0,0 +1,193 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+//Both file systems have to behave the same way
+func TestFS_Conformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, fs := range map[string]FS{"os": NewOsFS(), "mem": NewMemFS()} {
+		root := filepath.Join(dir, name)
+		if err := fs.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.OpenFile(filepath.Join(root, "missing"), os.O_RDONLY, 0); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s: missing file has to return ErrNotExist, got %v", name, err)
+		}
+		path := filepath.Join(root, "file")
+		if err := writeFile(fs, path, []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		file, err := fs.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(" world"))
+		file.Close()
+		file, err = fs.OpenFile(path, os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffer := make([]byte, 5)
+		if _, err := file.ReadAt(buffer, 6); err != nil || string(buffer) != "world" {
+			t.Fatalf("%s: wrong ReadAt %q %v", name, buffer, err)
+		}
+		file.Seek(6, 0)
+		if _, err := file.Read(buffer); err != nil || string(buffer) != "world" {
+			t.Fatalf("%s: wrong Read after Seek %q %v", name, buffer, err)
+		}
+		file.Close()
+		if err := fs.Truncate(path, 5); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Rename(path, filepath.Join(root, "renamed")); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.SyncDir(root); err != nil {
+			t.Fatalf("%s: directory can't be synced, got %v", name, err)
+		}
+		content, err := readFile(fs, filepath.Join(root, "renamed"))
+		if err != nil || string(content) != "hello" {
+			t.Fatalf("%s: wrong content after truncate and rename %q %v", name, content, err)
+		}
+		infos, err := fs.ReadDir(root)
+		if err != nil || len(infos) != 2 || infos[0].Name() != "renamed" || infos[1].Name() != "sub" || !infos[1].IsDir() {
+			t.Fatalf("%s: wrong directory listing %v", name, err)
+		}
+		if err := fs.Remove(filepath.Join(root, "renamed")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(filepath.Join(root, "renamed")); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s: removed file still exists", name)
+		}
+		lock, err := fs.Lock(filepath.Join(root, "LOCK"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Lock(filepath.Join(root, "LOCK")); err == nil {
+			t.Fatalf("%s: lock was taken twice", name)
+		}
+		lock.Close()
+		lock, err = fs.Lock(filepath.Join(root, "LOCK"))
+		if err != nil {
+			t.Fatalf("%s: released lock can't be taken, got %v", name, err)
+		}
+		lock.Close()
+	}
+}
+
+func TestFaultFS(t *testing.T) {
+	fs := NewFaultFS()
+	file, err := fs.OpenFile("/file", os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("synced"))
+	file.Sync()
+	fs.SyncDir("/")
+	file.Write([]byte(" lost"))
+	//writes are torn after the limit
+	fs.FailWritesAfter(2)
+	if written, err := file.Write([]byte("torn")); written != 2 || !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Write has to be torn after 2 bytes, %d bytes were written, got %v", written, err)
+	}
+	content, _ := readFile(fs, "/file")
+	if string(content) != "synced lostto" {
+		t.Fatalf("Wrong content before crash %q", content)
+	}
+	fs.Crash()
+	content, _ = readFile(fs, "/file")
+	if string(content) != "synced" {
+		t.Fatalf("Unsynced writes have to be lost after crash, got %q", content)
+	}
+	fs.FailWith(syscall.EIO)
+	if _, err := fs.OpenFile("/file", os.O_RDONLY, 0); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Injected error has to be returned, got %v", err)
+	}
+	fs.FailWith(nil)
+	if _, err := readFile(fs, "/file"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLsmTree_FaultFS(t *testing.T) {
+	fs := NewFaultFS()
+	db, err := Open(Options{Dir: "/data", FS: fs, SyncMode: SyncAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Put(&TableEntry{key: []byte("ANITA"), value: []byte("DEVELOPER")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.FailWith(syscall.EIO)
+	if err := db.Put(&TableEntry{key: []byte("BNITA"), value: []byte("V")}); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Io error has to be returned by Put, got %v", err)
+	}
+	//synced write survives the crash
+	fs.Crash()
+	db, err = Open(Options{Dir: "/data", FS: fs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	value, err := db.Get([]byte("ANITA"))
+	if err != nil || !bytes.Equal(value, []byte("DEVELOPER")) {
+		t.Fatalf("Synced value was lost after crash, got %v", err)
+	}
+}
+
+//Created,renamed and removed files are rolled back by a crash until the directory is synced
+func TestMemFS_UnsyncedDirectoryEntries(t *testing.T) {
+	fs := NewFaultFS()
+	for _, name := range []string{"/removed", "/renamed"} {
+		if err := writeFile(fs, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs.SyncDir("/")
+	if err := writeFile(fs, "/created", []byte("created")); err != nil {
+		t.Fatal(err)
+	}
+	fs.Remove("/removed")
+	fs.Rename("/renamed", "/new")
+	fs.Crash()
+	infos, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	if strings.Join(names, ",") != "removed,renamed" {
+		t.Fatalf("Directory has to be as of the last sync, got %v", names)
+	}
+	content, _ := readFile(fs, "/renamed")
+	if string(content) != "/renamed" {
+		t.Fatalf("Wrong content of the file that got its old name back %q", content)
+	}
+	//synced directory keeps its entries
+	writeFile(fs, "/created", []byte("created"))
+	fs.Rename("/renamed", "/new")
+	fs.SyncDir("/")
+	fs.Crash()
+	if _, err := fs.Stat("/created"); err != nil {
+		t.Fatalf("Synced file was lost, got %v", err)
+	}
+	if _, err := fs.Stat("/renamed"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Synced rename was rolled back, got %v", err)
+	}
+}
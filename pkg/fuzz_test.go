@@ -0,0 +1,338 @@
+//go:build go1.18
+// +build go1.18
+
+package wiskey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+//Decoders read bytes from the disk,any input has to return an error instead of a panic
+//run a single target with go test -run=^$ -fuzz=FuzzReadEntry
+
+func FuzzReadEntry(f *testing.F) {
+	compressed, _ := (&TableEntry{key: []byte("key"), value: bytes.Repeat(jsonValue(1), 5)}).compressed(&flateCompressor{})
+	for _, entry := range []*TableEntry{{key: []byte("key"), value: []byte("value")}, DeletedRangeEntry([]byte("a"), []byte("b")), {key: []byte("key"), family: 3}, compressed} {
+		buffer := bytes.NewBuffer([]byte{})
+		entry.writeTo(buffer)
+		f.Add(buffer.Bytes())
+	}
+	f.Add([]byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		entry, length, err := readEntry(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if int(length) > len(data) {
+			t.Fatalf("entry of %d bytes was read from %d bytes", length, len(data))
+		}
+		unbatch(entry, &ValueMeta{length: length})
+	})
+}
+
+//Compressed vlog values and sstable blocks are behind checksums,so the decoder is fuzzed directly
+func FuzzDecompress(f *testing.F) {
+	compressed, _, _ := compress(&flateCompressor{}, bytes.Repeat(jsonValue(1), 10))
+	f.Add(compressed)
+	f.Add(append([]byte{0x01}, compressed[1:]...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decompressed, err := decompress(flateCompression, data)
+		if err != nil {
+			return
+		}
+		if size, _ := binary.Uvarint(data); uint64(len(decompressed)) != size {
+			t.Fatalf("%d bytes were decompressed,%d were stored", len(decompressed), size)
+		}
+	})
+}
+
+func FuzzEntryRoundTrip(f *testing.F) {
+	f.Add([]byte("key"), []byte("value"), uint32(0), false, false)
+	f.Add([]byte("start"), []byte("end"), uint32(7), true, true)
+	f.Fuzz(func(t *testing.T, key []byte, value []byte, family uint32, rangeDeletion bool, compressed bool) {
+		entry := &TableEntry{key: key, value: value, family: family}
+		if rangeDeletion {
+			entry.flags = rangeDeletionFlag
+		}
+		written := entry
+		if compressed {
+			var err error
+			written, err = entry.compressed(&flateCompressor{})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		buffer := bytes.NewBuffer([]byte{})
+		length, err := written.writeTo(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, readLength, err := readEntry(bytes.NewReader(buffer.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if readLength != length || int(length) != buffer.Len() {
+			t.Fatalf("wrote %d bytes,read %d", length, readLength)
+		}
+		if !bytes.Equal(read.key, key) || !bytes.Equal(read.value, value) || read.family != family || read.flags != entry.flags {
+			t.Fatalf("wrote %+v,read %+v", entry, read)
+		}
+	})
+}
+
+//Vlog file with given content and no checkpoint
+func fuzzVlog(t *testing.T, data []byte) (*MemFS, *vlog, error) {
+	fs := NewMemFS()
+	if err := writeFile(fs, "/vlog", data); err != nil {
+		t.Fatal(err)
+	}
+	log, err := newVlog(fs, "/vlog", "/checkpoint")
+	return fs, log, err
+}
+
+func FuzzVlogGet(f *testing.F) {
+	buffer := bytes.NewBuffer([]byte{})
+	for _, entry := range FakeEntries() {
+		entry.writeTo(buffer)
+	}
+	f.Add(buffer.Bytes(), uint64(0), uint32(17))
+	f.Add(buffer.Bytes(), uint64(17), uint32(0xFFFFFFF0))
+	f.Add(buffer.Bytes(), uint64(0xFFFFFFFFFFFFFFF0), uint32(17))
+	f.Fuzz(func(t *testing.T, data []byte, offset uint64, length uint32) {
+		_, log, err := fuzzVlog(t, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta := &ValueMeta{offset: offset, length: length}
+		log.Get(*meta)
+		log.GetMany([]*ValueMeta{meta, {offset: 0, length: length}, nil})
+	})
+}
+
+func FuzzRestoreTo(f *testing.F) {
+	buffer := bytes.NewBuffer([]byte{})
+	for _, entry := range FakeEntries() {
+		entry.writeTo(buffer)
+	}
+	DeletedRangeEntry([]byte("A"), []byte("B")).writeTo(buffer)
+	f.Add(buffer.Bytes())
+	f.Add(buffer.Bytes()[:buffer.Len()-3])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fs, log, err := fuzzVlog(t, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		options := DefaultOptions()
+		options.FS = fs
+		tree, err := openLsmTree(log, "/sstable", NewMemTable(1000), options)
+		if err != nil {
+			return
+		}
+		//restored entries are flushed to an sstable
+		tree.Close()
+	})
+}
+
+func FuzzNewFooter(f *testing.F) {
+	footer := Footer{index: blockHandle{offset: 100, length: 20}, properties: blockHandle{offset: 120, length: 30}, version: formatVersion}
+	f.Add(footer.asByteArray())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parsed, err := NewFooter(data)
+		if err != nil {
+			return
+		}
+		if !bytes.Equal(parsed.asByteArray(), data) {
+			t.Fatalf("footer %+v is written differently", parsed)
+		}
+	})
+}
+
+func FuzzReadIndexBlock(f *testing.F) {
+	buffer := bytes.NewBuffer([]byte{})
+	for _, index := range []tableIndex{{Offset: 0, BlockLength: 10, LastKey: []byte("b")}, {Offset: 10, BlockLength: 5, LastKey: []byte("d")}} {
+		index.writeTo(buffer)
+	}
+	f.Add(buffer.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		result, err := readIndexBlock(data)
+		if err != nil {
+			return
+		}
+		written := bytes.NewBuffer([]byte{})
+		for _, index := range result {
+			index.writeTo(written)
+		}
+		if !bytes.Equal(written.Bytes(), data) {
+			t.Fatal("indexes are written differently")
+		}
+	})
+}
+
+func FuzzReadProperties(f *testing.F) {
+	properties := &TableProperties{Entries: 2, MinKey: []byte("a"), MaxKey: []byte("b"), MaxSequence: 2, CreatedAt: time.Unix(0, 1), Comparator: bytewiseComparator}
+	f.Add(properties.asByteArray())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		readProperties(data)
+	})
+}
+
+func FuzzBlockReader(f *testing.F) {
+	block := newBlockBuilder()
+	for _, entry := range tenantEntries(40) {
+		block.add(entry)
+	}
+	f.Add(block.finish(), []byte("tenant-0000000001/users/00017"))
+	f.Add([]byte{0x00, 0x00, 0x00, 0x01}, []byte{})
+	f.Fuzz(func(t *testing.T, data []byte, key []byte) {
+		reader, err := newBlockReader(data)
+		if err != nil {
+			return
+		}
+		//every entry takes at least one byte,so the loop ends
+		for reader.hasNext() {
+			if _, err := reader.next(); err != nil {
+				break
+			}
+		}
+		reader.find(key)
+	})
+}
+
+//Write the entries to an sstable in memory and open it
+func fuzzTable(t testing.TB, entries []*sstableEntry, blockSize uint32, compressor Compressor) ([]byte, *SSTable, error) {
+	fs := NewMemFS()
+	file, err := fs.OpenFile("/table", os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewWriter(file, blockSize, compressor)
+	for _, entry := range entries {
+		if err := writer.WriteEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := readFile(fs, "/table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := fs.OpenFile("/table", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := ReadTable(reader, nil)
+	return data, table, err
+}
+
+func FuzzReadTable(f *testing.F) {
+	data, _, _ := fuzzTable(f, tenantEntries(20), 128, nil)
+	f.Add(data)
+	data, _, _ = fuzzTable(f, tenantEntries(20), 128, &flateCompressor{})
+	f.Add(data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fs := NewMemFS()
+		if err := writeFile(fs, "/table", data); err != nil {
+			t.Fatal(err)
+		}
+		reader, err := fs.OpenFile("/table", os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := ReadTable(reader, nil)
+		if err != nil {
+			return
+		}
+		defer table.Close()
+		iterator := table.iterator()
+		for {
+			entry, err := iterator.next()
+			if err != nil || entry == nil {
+				break
+			}
+			table.lookup(entry.key)
+		}
+	})
+}
+
+//Entries are made of zero separated keys of the input,every second value is inline
+func FuzzTableRoundTrip(f *testing.F) {
+	f.Add([]byte("apple\x00banana\x00cherry"), uint16(0), false)
+	f.Add(bytes.Repeat([]byte("tenant-0000000001/users/\x00"), 50), uint16(100), true)
+	f.Fuzz(func(t *testing.T, data []byte, blockSize uint16, compressed bool) {
+		unique := make(map[string]bool)
+		for _, key := range bytes.Split(data, []byte{0}) {
+			unique[string(key)] = true
+		}
+		keys := make([]string, 0, len(unique))
+		for key := range unique {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		entries := make([]*sstableEntry, len(keys))
+		for i, key := range keys {
+			entries[i] = &sstableEntry{key: []byte(key), timeStamp: uint64(i), valueOffset: uint64(i), valueLength: uint32(len(key))}
+			if i%2 == 1 {
+				entries[i] = &sstableEntry{key: []byte(key), timeStamp: uint64(i), inline: true, value: []byte(key + "value")}
+			}
+		}
+		var compressor Compressor
+		if compressed {
+			compressor = &flateCompressor{}
+		}
+		_, table, err := fuzzTable(t, entries, 64+uint32(blockSize), compressor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer table.Close()
+		if table.Properties().Entries != uint64(len(entries)) {
+			t.Fatalf("table has %d entries,%d were written", table.Properties().Entries, len(entries))
+		}
+		iterator := table.iterator()
+		for i, expected := range entries {
+			entry, err := iterator.next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameTableEntry(entry, expected) {
+				t.Fatalf("entry %d: wrote %+v,read %+v", i, expected, entry)
+			}
+			found, ok, err := table.lookup(expected.key)
+			if err != nil || !ok || !sameTableEntry(found, expected) {
+				t.Fatalf("lookup of %q returned %+v,%v,%v", expected.key, found, ok, err)
+			}
+		}
+		if entry, err := iterator.next(); entry != nil || err != nil {
+			t.Fatalf("unexpected entry %+v after the last one,%v", entry, err)
+		}
+	})
+}
+
+func sameTableEntry(first *sstableEntry, second *sstableEntry) bool {
+	return bytes.Equal(first.key, second.key) && first.timeStamp == second.timeStamp && first.inline == second.inline &&
+		bytes.Equal(first.value, second.value) && first.valueOffset == second.valueOffset && first.valueLength == second.valueLength
+}
+
+//Lengths of a corrupted entry overflow,so the sum of them is smaller than the key length
+func TestReadEntry_CorruptedLengths(t *testing.T) {
+	entry := make([]byte, uint32Size*2)
+	binary.BigEndian.PutUint32(entry, 16)
+	binary.BigEndian.PutUint32(entry[uint32Size:], 0xFFFFFFF8)
+	entry = append(entry, "keyvalue"...)
+	if _, _, err := readEntry(bytes.NewReader(entry)); err == nil {
+		t.Fatal("entry longer than the input has to be rejected")
+	}
+	_, log, err := fuzzVlog(t, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Get(ValueMeta{offset: 0, length: 0xFFFFFFFF}); err == nil {
+		t.Fatal("entry out of the file has to be rejected")
+	}
+}
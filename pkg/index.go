@@ -6,28 +6,57 @@ import (
 	"io"
 )
 
+const indexHeaderSize = uint32Size*2 + int64Size //block length,offset and last key length
+
 //indexes to find an entry in a file
 type tableIndex struct {
-	Offset      uint32 //Offset of the file where index starts
+	Offset      uint64 //Offset of the file where index starts
 	BlockLength uint32 //the length of the index
+	LastKey     []byte //the biggest key in the block
 }
 
-//Write index to the end of sstable
-//+-------------+--------+
-//| BlockLength | Offset |
-//+-------------+--------+
-func (index *tableIndex) WriteTo(w io.Writer) error {
+//Write index to the end of sstable,the offset is uint64 so tables can be bigger than 4GiB
+//+-------------+--------+-----------------+----------+
+//| BlockLength | Offset | Last key length | Last key |
+//+-------------+--------+-----------------+----------+
+func (index *tableIndex) writeTo(w io.Writer) (uint32, error) {
 	buf := bytes.NewBuffer([]byte{})
 
 	if err := binary.Write(buf, binary.BigEndian, index.BlockLength); err != nil {
-		return err
+		return 0, err
 	}
 
 	if err := binary.Write(buf, binary.BigEndian, index.Offset); err != nil {
-		return err
+		return 0, err
+	}
+
+	if err := binary.Write(buf, binary.BigEndian, uint32(len(index.LastKey))); err != nil {
+		return 0, err
 	}
+	buf.Write(index.LastKey)
 
-	_, err := w.Write(buf.Bytes())
+	length, err := w.Write(buf.Bytes())
 	//can be null
-	return err
+	return uint32(length), err
+}
+
+//Read all indexes from the index part of sstable
+func readIndexBlock(buffer []byte) (indexes, error) {
+	result := indexes{}
+	for start := 0; start != len(buffer); {
+		if len(buffer)-start < indexHeaderSize {
+			return nil, errCorruptedBlock
+		}
+		blockLength := binary.BigEndian.Uint32(buffer[start:])
+		blockOffset := binary.BigEndian.Uint64(buffer[start+uint32Size:])
+		keyLength := binary.BigEndian.Uint32(buffer[start+uint32Size+int64Size:])
+		start += indexHeaderSize
+		if uint64(keyLength) > uint64(len(buffer)-start) {
+			return nil, errCorruptedBlock
+		}
+		lastKey := buffer[start : start+int(keyLength)]
+		start += int(keyLength)
+		result = append(result, tableIndex{Offset: blockOffset, BlockLength: blockLength, LastKey: lastKey})
+	}
+	return result, nil
 }
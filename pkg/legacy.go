@@ -0,0 +1,134 @@
+package wiskey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+//Tables written before the block based format,they don't have a footer with the magic number
+//+---------+-----+---------+---------+-----+---------+--------------+
+//| Entry 1 | ... | Entry N | Index 1 | ... | Index M | Index offset |
+//+---------+-----+---------+---------+-----+---------+--------------+
+//Entry
+//+------------+-----+-----------+-------------+-------------+
+//| Key Length | Key | Timestamp | Vlog offset | Vlog length |
+//+------------+-----+-----------+-------------+-------------+
+//Index points to a block of whole entries that follow each other
+//+--------------+--------+
+//| Block length | Offset |
+//+--------------+--------+
+//they are rewritten in the current format when the tree is opened
+const legacyIndexSize = uint32Size * 2
+
+//Parse the table in the format before the block based one
+//ok is false if the file doesn't have this layout,blocks have to cover all entries exactly
+func readLegacyTable(data []byte) ([]*sstableEntry, bool) {
+	if len(data) < uint32Size {
+		return nil, false
+	}
+	end := uint64(len(data) - uint32Size)
+	indexOffset := uint64(binary.BigEndian.Uint32(data[end:]))
+	if indexOffset > end || (end-indexOffset)%legacyIndexSize != 0 {
+		return nil, false
+	}
+	var entries []*sstableEntry
+	offset := uint64(0)
+	for position := indexOffset; position < end; position += legacyIndexSize {
+		length := uint64(binary.BigEndian.Uint32(data[position:]))
+		blockOffset := uint64(binary.BigEndian.Uint32(data[position+uint32Size:]))
+		if blockOffset != offset || length == 0 || length > indexOffset-offset {
+			return nil, false
+		}
+		block := data[offset : offset+length]
+		for len(block) != 0 {
+			entry, size, ok := readLegacyEntry(block)
+			if !ok || (len(entries) != 0 && bytes.Compare(entries[len(entries)-1].key, entry.key) > 0) {
+				return nil, false
+			}
+			entries = append(entries, entry)
+			block = block[size:]
+		}
+		offset += length
+	}
+	//blocks end where the index starts
+	if offset != indexOffset || len(entries) == 0 {
+		return nil, false
+	}
+	return entries, true
+}
+
+//Returns the entry at the beginning of data and its size
+func readLegacyEntry(data []byte) (*sstableEntry, int, bool) {
+	if len(data) < uint32Size {
+		return nil, 0, false
+	}
+	keyLength := uint64(binary.BigEndian.Uint32(data))
+	size := uint32Size + keyLength + int64Size + uint32Size*2
+	if uint64(len(data)) < size {
+		return nil, 0, false
+	}
+	meta := data[uint32Size+keyLength:]
+	return &sstableEntry{
+		key:         append([]byte{}, data[uint32Size:uint32Size+keyLength]...),
+		timeStamp:   binary.BigEndian.Uint64(meta),
+		valueOffset: uint64(binary.BigEndian.Uint32(meta[int64Size:])),
+		valueLength: binary.BigEndian.Uint32(meta[int64Size+uint32Size:]),
+	}, int(size), true
+}
+
+//Rewrite the table in the current format if it has the old layout,returns the path of the table
+//the old file is removed after the new one is committed,a crash in between leaves the same entries twice
+func (lsm *LsmTree) migrateLegacyTable(path string) (string, error) {
+	stat, err := lsm.fs().Stat(path)
+	if err != nil {
+		return "", err
+	}
+	//tables of the current format are recognized by the footer without reading the whole file
+	if stat.Size() >= footerSize {
+		file, err := lsm.fs().OpenFile(path, os.O_RDONLY, 0)
+		if err != nil {
+			return "", err
+		}
+		_, err = readFooter(stat, file)
+		file.Close()
+		if err == nil {
+			return path, nil
+		}
+	}
+	data, err := readFile(lsm.fs(), path)
+	if err != nil {
+		return "", err
+	}
+	entries, ok := readLegacyTable(data)
+	if !ok {
+		//ReadTable reports the corruption when the table is used
+		return path, nil
+	}
+	if lsm.root.options.ReadOnly {
+		return "", fmt.Errorf("sstable %s has the format before the block based one,it's rewritten by the first read write open", path)
+	}
+	writer, tempPath, err := lsm.newTable()
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if err := writer.WriteEntry(entry); err != nil {
+			writer.Close()
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	newPath, err := lsm.commitTable(tempPath)
+	if err != nil {
+		return "", err
+	}
+	lsm.root.options.Logger.Printf("sstable %s was rewritten in the block based format as %s", path, newPath)
+	if err := lsm.fs().Remove(path); err != nil {
+		return "", err
+	}
+	return newPath, lsm.fs().SyncDir(lsm.sstableDir)
+}
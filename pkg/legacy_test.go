@@ -0,0 +1,141 @@
+package wiskey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+)
+
+//Table in the format before the block based one,a block is closed once it has blockLength bytes
+func writeLegacyTable(entries []*sstableEntry, blockLength int) []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	index := bytes.NewBuffer([]byte{})
+	blockStart := 0
+	closeBlock := func() {
+		if buffer.Len() > blockStart {
+			binary.Write(index, binary.BigEndian, uint32(buffer.Len()-blockStart))
+			binary.Write(index, binary.BigEndian, uint32(blockStart))
+			blockStart = buffer.Len()
+		}
+	}
+	for _, entry := range entries {
+		binary.Write(buffer, binary.BigEndian, uint32(len(entry.key)))
+		buffer.Write(entry.key)
+		binary.Write(buffer, binary.BigEndian, entry.timeStamp)
+		binary.Write(buffer, binary.BigEndian, uint32(entry.valueOffset))
+		binary.Write(buffer, binary.BigEndian, entry.valueLength)
+		if buffer.Len()-blockStart >= blockLength {
+			closeBlock()
+		}
+	}
+	closeBlock()
+	indexOffset := uint32(buffer.Len())
+	buffer.Write(index.Bytes())
+	binary.Write(buffer, binary.BigEndian, indexOffset)
+	return buffer.Bytes()
+}
+
+func TestLsmTree_MigrateLegacyTable(t *testing.T) {
+	fs := NewMemFS()
+	vlog, err := newVlog(fs, "/vlog", "/checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the old tree wrote values and tombstones to the vlog and pointed to them from the table
+	values := map[string]string{"ANITA": "DEVELOPER", "BNITA": tombstone, "GNITA": "DEVELOPER3", "WNITA": "DEVELOPER6"}
+	var entries []*sstableEntry
+	for _, key := range []string{"ANITA", "BNITA", "GNITA", "WNITA"} {
+		entry := NewEntry([]byte(key), []byte(values[key]))
+		meta, err := vlog.Append(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, &sstableEntry{key: []byte(key), timeStamp: 1600000000, valueOffset: meta.offset, valueLength: meta.length})
+	}
+	if err := vlog.FlushHead(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.MkdirAll("/sstable", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(fs, "/sstable/legacy.sstable", writeLegacyTable(entries, 20)); err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultOptions()
+	options.FS = fs
+	//a read only open can't rewrite the table
+	options.ReadOnly = true
+	if _, err := openLsmTree(vlog, "/sstable", NewMemTable(100), options); err == nil {
+		t.Fatal("Read only open of a legacy table has to fail")
+	}
+	if _, err := fs.Stat("/sstable/legacy.sstable"); err != nil {
+		t.Fatalf("Read only open removed the legacy table, got %v", err)
+	}
+	options.ReadOnly = false
+	tree, err := openLsmTree(vlog, "/sstable", NewMemTable(100), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.sstables) != 1 || tree.sstables[0] == "/sstable/legacy.sstable" {
+		t.Fatalf("Legacy table had to be replaced,tables are %v", tree.sstables)
+	}
+	if _, err := fs.Stat("/sstable/legacy.sstable"); !os.IsNotExist(err) {
+		t.Fatalf("Legacy table had to be removed,got %v", err)
+	}
+	for key, value := range values {
+		found, err := tree.Get([]byte(key))
+		if value == tombstone {
+			if !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Deleted key %s was found", key)
+			}
+			continue
+		}
+		if err != nil || string(found) != value {
+			t.Fatalf("Key %s of the legacy table returned %q %v", key, found, err)
+		}
+	}
+}
+
+func TestReadLegacyTable(t *testing.T) {
+	entries := []*sstableEntry{
+		{key: []byte("ANITA"), timeStamp: 1, valueOffset: 0, valueLength: 17},
+		{key: []byte("BNITA"), timeStamp: 2, valueOffset: 17, valueLength: 18},
+		{key: []byte("CNITA"), timeStamp: 3, valueOffset: 35, valueLength: 18},
+	}
+	//one entry per block and all entries in a single block
+	for _, blockLength := range []int{1, 1000} {
+		read, ok := readLegacyTable(writeLegacyTable(entries, blockLength))
+		if !ok || len(read) != len(entries) {
+			t.Fatalf("Legacy table with blocks of %d bytes wasn't read", blockLength)
+		}
+		for i, entry := range read {
+			if !bytes.Equal(entry.key, entries[i].key) || entry.timeStamp != entries[i].timeStamp ||
+				entry.valueOffset != entries[i].valueOffset || entry.valueLength != entries[i].valueLength {
+				t.Fatalf("Entry %d was read as %+v", i, entry)
+			}
+		}
+	}
+	data := writeLegacyTable(entries, 1)
+	table := writeTestTable(t, entries, defaultBlockSize, nil)
+	stat, err := table.reader.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := make([]byte, stat.Size())
+	if _, err := table.reader.ReadAt(current, 0); err != nil {
+		t.Fatal(err)
+	}
+	for name, invalid := range map[string][]byte{
+		"empty":             {},
+		"current format":    current,
+		"truncated":         data[:len(data)-5],
+		"shifted blocks":    data[1:],
+		"index out of file": {0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		if _, ok := readLegacyTable(invalid); ok {
+			t.Fatalf("Table %s was read as a legacy table", name)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package wiskey
+
+import (
+	"os"
+	"syscall"
+)
+
+//Lock is released when the file is closed or the process dies
+func lockFileExclusive(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
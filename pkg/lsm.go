@@ -2,11 +2,10 @@ package wiskey
 
 import (
 	"bytes"
-	"encoding/binary"
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
@@ -14,95 +13,275 @@ import (
 )
 
 type LsmTree struct {
-	rwm        sync.RWMutex
+	rwm        *sync.RWMutex //shared by all column families because they write to the same vlog
 	gcMutex    sync.RWMutex
 	sstableDir string    //directory with sstables
 	log        *vlog     //vlog
 	memtable   *Memtable //in memory table
 	sstables   []string  //list of created sstables,let's change it to set to speed up the search
 	deleted    map[string]bool
+	//range tombstones that still cover some entries in sstables
+	rangeTombstones []*rangeTombstone
+	valueThreshold  int                   //values smaller than this are stored inline in memtable and sstables
+	blockCache      *blockCache           //shared by all column families,set only in the root
+	compressor      Compressor            //compression of sstable blocks and vlog values,nil disables it
+	family          uint32                //column family id,0 for the default family
+	root            *LsmTree              //tree of the default family,it owns all named families
+	families        map[string]*LsmTree   //named column families,set only in the root
+	options         Options               //set only in the root
+	closed          bool                  //set only in the root
+	cancel          context.CancelFunc    //stops background workers,set only in the root
+	workers         sync.WaitGroup        //running background workers,used only in the root
+	stats           *engineStats          //counters since Open,set only in the root
+	watchers        map[*Watcher]struct{} //watchers of all column families,set only in the root
 }
 
-func NewLsmTree(log *vlog, sstableDir string, memtable *Memtable, gc uint) *LsmTree {
+//Open the tree and start merging sstables every gc seconds
+//Open configures the rest of the settings with Options
+func NewLsmTree(log *vlog, sstableDir string, memtable *Memtable, gc uint) (*LsmTree, error) {
+	options := DefaultOptions()
+	options.CompactionInterval = time.Duration(gc) * time.Second
+	lsm, err := openLsmTree(log, sstableDir, memtable, options)
+	if err != nil {
+		return nil, err
+	}
+	lsm.startWorkers()
+	return lsm, nil
+}
+
+//Open the tree without background workers,options have to be valid
+func openLsmTree(log *vlog, sstableDir string, memtable *Memtable, options Options) (*LsmTree, error) {
+	compressor, err := CompressorByName(options.Compression)
+	if err != nil {
+		return nil, err
+	}
 	lsm := &LsmTree{
-		log:        log,
-		sstableDir: sstableDir,
-		memtable:   memtable,
-		deleted:    make(map[string]bool),
+		rwm:            &sync.RWMutex{},
+		log:            log,
+		sstableDir:     sstableDir,
+		memtable:       memtable,
+		deleted:        make(map[string]bool),
+		families:       make(map[string]*LsmTree),
+		valueThreshold: options.ValueThreshold,
+		compressor:     compressor,
+		options:        options,
+		stats:          &engineStats{},
+		watchers:       make(map[*Watcher]struct{}),
+	}
+	lsm.root = lsm
+	if options.BlockCacheSize > 0 {
+		lsm.blockCache = newBlockCache(options.BlockCacheSize)
+	}
+	if options.ValueCacheSize > 0 {
+		log.SetCache(options.ValueCacheSize)
 	}
 	//create sstable path if doesn't exist
-	if _, err := os.Stat(sstableDir); os.IsNotExist(err) {
-		err := os.Mkdir(sstableDir, os.ModeDir|0755)
-		if err != nil {
-			panic(err)
-		}
+	if !options.ReadOnly {
+		err = lsm.fs().MkdirAll(sstableDir, 0755)
+	} else {
+		_, err = lsm.fs().Stat(sstableDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = lsm.fillSstables()
+	if err != nil {
+		return nil, err
+	}
+	tombstones, err := readRangeTombstones(lsm.fs(), lsm.rangeTombstonesPath())
+	if err != nil {
+		return nil, err
+	}
+	lsm.rangeTombstones = tombstones
+	//families have to be known before restore because they share the vlog
+	err = lsm.loadColumnFamilies()
+	if err != nil {
+		return nil, err
 	}
-	lsm.fillSstables()
-	err := lsm.restore()
+	err = lsm.restore()
 	if err != nil {
-		fmt.Print(err.Error())
-		panic(err)
-	}
-	//run job to periodically merge sstables
-	go func(tree *LsmTree, gc uint) {
-		fmt.Println("Gc thread was initialized")
-		for true {
-			time.Sleep(time.Duration(gc) * time.Second)
-			fmt.Println("SSTABLE GC started")
-			err := lsm.Merge()
+		return nil, err
+	}
+	return lsm, nil
+}
+
+//Run background jobs configured in options,they are stopped by Close
+func (lsm *LsmTree) startWorkers() {
+	ctx, cancel := context.WithCancel(context.Background())
+	lsm.root.cancel = cancel
+	options := lsm.root.options
+	if options.CompactionStrategy != NoCompaction {
+		lsm.root.runPeriodically(ctx, "sstable merge", options.CompactionInterval, lsm.root.mergeAll)
+	}
+	if options.GcInterval > 0 {
+		lsm.root.runPeriodically(ctx, "vlog gc", options.GcInterval, lsm.root.CompressVlog)
+	}
+	//Stats only returns the estimate,it's refreshed as often as gc runs or as sstables are merged without gc
+	estimateInterval := options.CompactionInterval
+	if options.GcInterval > 0 {
+		estimateInterval = options.GcInterval
+	}
+	lsm.root.runPeriodically(ctx, "vlog garbage estimate", estimateInterval, lsm.root.estimateGarbage)
+}
+
+//Run the job every interval until the context is cancelled or the job fails
+//a running job is never interrupted,Close waits for it
+func (lsm *LsmTree) runPeriodically(ctx context.Context, name string, interval time.Duration, job func() error) {
+	logger := lsm.options.Logger
+	lsm.workers.Add(1)
+	go func() {
+		defer lsm.workers.Done()
+		logger.Printf("%s worker was started,it runs every %v", name, interval)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			err := job()
+			if errors.Is(err, ErrClosed) {
+				return
+			}
 			if err != nil {
-				fmt.Println("Gc encountered an error " + err.Error() + " Stop gc thread")
+				logger.Printf("%s encountered an error %v,stop the worker", name, err)
 				return
 			}
 		}
-	}(lsm, gc)
-	return lsm
+	}()
 }
 
-type TableWithIndex struct {
-	index     int
-	tablePath string
+//File system of all column families
+func (lsm *LsmTree) fs() FS {
+	return lsm.root.options.FS
 }
 
+//Merge sstables of all column families
+func (lsm *LsmTree) mergeAll() error {
+	lsm.rwm.RLock()
+	families := lsm.root.allFamilies()
+	lsm.rwm.RUnlock()
+	for _, family := range families {
+		err := family.Merge()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Flush all memtables, persist the vlog head and stop background workers
+//a read only tree isn't flushed,entries restored from the vlog stay there
+//all following calls return ErrClosed
+//a running merge is finished before Close returns
+//sstables and the vlog are opened per operation,so no file handles stay open after Close
+func (lsm *LsmTree) Close() error {
+	root := lsm.root
+	root.rwm.Lock()
+	if root.closed {
+		root.rwm.Unlock()
+		return ErrClosed
+	}
+	root.closed = true
+	root.stopWatchers()
+	var err error
+	if !root.options.ReadOnly {
+		err = root.flush()
+	}
+	root.rwm.Unlock()
+	if root.cancel != nil {
+		root.cancel()
+	}
+	root.workers.Wait()
+	return err
+}
+
+//Writes are rejected when the tree is closed or opened read only
+func (lsm *LsmTree) checkWritable() error {
+	if lsm.root.closed {
+		return ErrClosed
+	}
+	if lsm.root.options.ReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
 
 func (lsm *LsmTree) CompressVlog() error {
-	//TODO: hard coded value, let's make it configurable
-	size := 2
-	return lsm.log.RunGc(size, lsm)
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	if err := lsm.checkWritable(); err != nil {
+		return err
+	}
+	start, tail, end := time.Now(), lsm.log.tail, lsm.log.tail+lsm.log.size
+	err := lsm.log.RunGc(lsm.root.options.GcEntries, lsm.root)
+	if err != nil {
+		return err
+	}
+	//collected entries are read from the tail and live ones are appended to the end
+	lsm.root.stats.gc.record(start, int64(lsm.log.tail-tail), int64(lsm.log.tail+lsm.log.size-end))
+	return nil
 }
 
-//Check if given key was deleted
-func (lsm *LsmTree) Exists(key []byte) []TableWithIndex {
-	var tableWithIndexes []TableWithIndex
+//Check if the latest version of the key is stored in the vlog at given offset
+//only flushed entries are collected,so a key in memtable always has a newer version
+func (lsm *LsmTree) pointsTo(key []byte, offset uint64) (bool, error) {
+	if _, found := lsm.memtable.Get(key); found {
+		return false, nil
+	}
+	entry, found, err := lsm.latestInSStables(key)
+	if err != nil {
+		return false, err
+	}
+	//entries covered by a range tombstone are garbage,inline entries don't use the vlog
+	return found && !entry.inline && entry.valueOffset == offset && !lsm.isRangeDeleted(key, entry.timeStamp), nil
+}
+
+//Find the sstable entry with the latest timestamp without reading its value
+func (lsm *LsmTree) latestInSStables(key []byte) (*sstableEntry, bool, error) {
+	var latestEntry *sstableEntry
 	for _, tablePath := range lsm.sstables {
-		reader, _ := os.Open(tablePath)
-		sstable := ReadTable(reader, lsm.log)
-		found, index := sstable.KeyAtIndex(key)
-		if found {
-			tableWithIndexes = append(tableWithIndexes, TableWithIndex{index: index, tablePath: tablePath})
+		sstable, err := lsm.openTable(tablePath)
+		if err != nil {
+			return nil, false, err
 		}
+		entry, found, err := sstable.lookup(key)
 		sstable.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("can't read sstable %s: %w", tablePath, err)
+		}
+		if found && (latestEntry == nil || entry.timeStamp > latestEntry.timeStamp) {
+			latestEntry = entry
+		}
 	}
-	return tableWithIndexes
+	return latestEntry, latestEntry != nil, nil
 }
 
 //Merge sstables, the final result is the sstable files with amount decreased by x2
 func (lsm *LsmTree) Merge() error {
 	lsm.rwm.Lock()
 	defer lsm.rwm.Unlock()
+	if err := lsm.checkWritable(); err != nil {
+		return err
+	}
 	var newSstableFiles []string
 	index := 0
 	if len(lsm.sstables)%2 == 0 {
-		for _, sstable := range lsm.sstables {
-			_, err := os.Stat(sstable)
-			fmt.Printf("%v exists %v\n", sstable, !os.IsNotExist(err))
+		start := time.Now()
+		read, err := lsm.tablesSize(lsm.sstables)
+		if err != nil {
+			return err
 		}
 		for index < len(lsm.sstables) {
-			firstReader, _ := os.Open(lsm.sstables[index])
-			secondReader, _ := os.Open(lsm.sstables[index+1])
 			//read two sstables
-			firstSStable := ReadTable(firstReader, lsm.log)
-			secondSStable := ReadTable(secondReader, lsm.log)
+			firstSStable, err := lsm.openTable(lsm.sstables[index])
+			if err != nil {
+				return err
+			}
+			secondSStable, err := lsm.openTable(lsm.sstables[index+1])
+			if err != nil {
+				firstSStable.Close()
+				return err
+			}
 			//merge them together into the single file
 			filePath, err, empty := lsm.mergeFiles(firstSStable, secondSStable)
 			if err != nil {
@@ -116,46 +295,82 @@ func (lsm *LsmTree) Merge() error {
 			index += 2
 		}
 		for _, sstable := range lsm.sstables {
-			err := os.Remove(sstable)
+			err := lsm.fs().Remove(sstable)
 			if err != nil {
 				return err
 			}
+			lsm.forgetCachedBlocks(sstable)
+		}
+		//merged tables can't come back after a crash
+		if err := lsm.fs().SyncDir(lsm.sstableDir); err != nil {
+			return err
 		}
 		lsm.sstables = newSstableFiles
+		written, err := lsm.tablesSize(newSstableFiles)
+		if err != nil {
+			return err
+		}
+		if index != 0 {
+			lsm.root.stats.compaction.record(start, read, written)
+		}
+		//all sstables were rewritten without covered entries,so range tombstones are not needed anymore
+		if len(lsm.rangeTombstones) != 0 {
+			lsm.rangeTombstones = nil
+			return writeRangeTombstones(lsm.fs(), lsm.rangeTombstonesPath(), lsm.rangeTombstones)
+		}
 	}
 	return nil
 }
-func (lsm *LsmTree) Get(key []byte) ([]byte, bool) {
+
+//Returns ErrNotFound if the key doesn't exist or was deleted
+func (lsm *LsmTree) Get(key []byte) ([]byte, error) {
+	defer lsm.root.stats.operation(opGet, time.Now())
+	lsm.rwm.RLock()
+	defer lsm.rwm.RUnlock()
+	if lsm.root.closed {
+		return nil, ErrClosed
+	}
+	return lsm.get(key)
+}
+
+func (lsm *LsmTree) get(key []byte) ([]byte, error) {
 	_, ok := lsm.deleted[string(key)]
 	if ok {
-		return nil, false
+		return nil, ErrNotFound
 	}
 	meta, found := lsm.memtable.Get(key)
 	//first check in memory table
 	if found {
-		entry, err := lsm.log.Get(*meta)
-		if err != nil {
-			panic(err)
+		entry := &TableEntry{key: key, value: meta.value}
+		if !meta.inline {
+			var err error
+			entry, err = lsm.log.Get(*meta)
+			if err != nil {
+				return nil, err
+			}
 		}
 		//check if it's a tombstone
 		if len(entry.value) == len(tombstone) && bytes.Compare(entry.value, []byte(tombstone)) == 0 {
-			return nil, false
+			return nil, ErrNotFound
 		} else {
-			return entry.value, true
+			return entry.value, nil
 		}
 	} else {
 		//if not in memory then try to find in sstables
 		//multiple sstables can have the same key
 		//choose the one with the latest timestamp
-		foundEntry, found := lsm.findInSStables(key)
-		if !found {
-			return nil, false
+		foundEntry, found, err := lsm.findInSStables(key)
+		if err != nil {
+			return nil, err
+		}
+		if !found || lsm.isRangeDeleted(key, foundEntry.timestamp) {
+			return nil, ErrNotFound
 		} else {
 			//if the value in vlog is tombstone it means that value was deleted
 			if len(foundEntry.value) == len(tombstone) && bytes.Compare(foundEntry.value, []byte(tombstone)) == 0 {
-				return nil, false
+				return nil, ErrNotFound
 			} else {
-				return foundEntry.value, true
+				return foundEntry.value, nil
 			}
 		}
 	}
@@ -163,8 +378,15 @@ func (lsm *LsmTree) Get(key []byte) ([]byte, bool) {
 
 //Save tombstone in vlog
 func (lsm *LsmTree) Delete(key []byte) error {
+	defer lsm.root.stats.operation(opDelete, time.Now())
+	if err := validateKey(key); err != nil {
+		return err
+	}
 	lsm.rwm.Lock()
 	defer lsm.rwm.Unlock()
+	if err := lsm.checkWritable(); err != nil {
+		return err
+	}
 	_, ok := lsm.deleted[string(key)]
 	//already deleted and it's still in memory
 	if ok {
@@ -174,32 +396,139 @@ func (lsm *LsmTree) Delete(key []byte) error {
 	return lsm.save(DeletedEntry(key))
 }
 
+//Delete all keys in [start,end) with a single range tombstone
+func (lsm *LsmTree) DeleteRange(start []byte, end []byte) error {
+	defer lsm.root.stats.operation(opDeleteRange, time.Now())
+	if bytes.Compare(start, end) >= 0 {
+		return errors.New("start of the range has to be smaller than the end")
+	}
+	if err := validateKey(start); err != nil {
+		return err
+	}
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	if err := lsm.checkWritable(); err != nil {
+		return err
+	}
+	//save in vlog so the range is restored if it wasn't flushed
+	_, err := lsm.log.Append(DeletedRangeEntry(start, end).withFamily(lsm.family))
+	if err != nil {
+		return err
+	}
+	if err := lsm.applyRangeTombstone(start, end); err != nil {
+		return err
+	}
+	lsm.notify(WatchEvent{Key: start, Deleted: true, End: end})
+	return nil
+}
+
+//Remove the range from memtable and save the tombstone for sstables
+func (lsm *LsmTree) applyRangeTombstone(start []byte, end []byte) error {
+	//keys in memtable are deleted right away,so everything that is in memtable is newer than the tombstone
+	lsm.memtable.DeleteRange(start, end)
+	timestamp := uint64(time.Now().UnixNano())
+	for _, tombstone := range lsm.rangeTombstones {
+		//the same range was deleted again or restored from vlog
+		if bytes.Equal(tombstone.start, start) && bytes.Equal(tombstone.end, end) {
+			tombstone.timestamp = timestamp
+			return writeRangeTombstones(lsm.fs(), lsm.rangeTombstonesPath(), lsm.rangeTombstones)
+		}
+	}
+	lsm.rangeTombstones = append(lsm.rangeTombstones, &rangeTombstone{
+		start:     start,
+		end:       end,
+		timestamp: timestamp,
+	})
+	return writeRangeTombstones(lsm.fs(), lsm.rangeTombstonesPath(), lsm.rangeTombstones)
+}
+
+//Check if sstable entry with given key and timestamp was deleted by a range tombstone
+func (lsm *LsmTree) isRangeDeleted(key []byte, timestamp uint64) bool {
+	for _, tombstone := range lsm.rangeTombstones {
+		if tombstone.covers(key, timestamp) {
+			return true
+		}
+	}
+	return false
+}
+
+func (lsm *LsmTree) rangeTombstonesPath() string {
+	return lsm.sstableDir + "/" + rangeTombstonesFile
+}
+
 //save entry in vlog first then in sstable
 func (lsm *LsmTree) Put(entry *TableEntry) error {
+	defer lsm.root.stats.operation(opPut, time.Now())
+	if err := validateKey(entry.key); err != nil {
+		return err
+	}
 	lsm.rwm.Lock()
 	defer lsm.rwm.Unlock()
+	if err := lsm.checkWritable(); err != nil {
+		return err
+	}
 	//before put let's delete this key from deleted map
 	delete(lsm.deleted, string(entry.key))
 	return lsm.save(entry)
 }
 
-//Flush in memory red black tree to sstable on disk
+//Flush in memory red black trees of all column families to sstables on disk
 func (lsm *LsmTree) Flush() error {
-	sstablePath := lsm.sstableDir + "/" + RandStringBytes(sstableFileLength) + ".sstable"
-	file, err := os.OpenFile(sstablePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	if err := lsm.checkWritable(); err != nil {
+		return err
+	}
+	return lsm.flush()
+}
+
+//families share the vlog,so the head can be moved only when all of them are flushed
+//new sstables point to the vlog,so it's synced before them even if writes aren't synced
+func (lsm *LsmTree) flush() error {
+	if !lsm.log.sync {
+		if err := lsm.log.syncFile(); err != nil {
+			return err
+		}
+	}
+	start, written := time.Now(), int64(0)
+	for _, family := range lsm.root.allFamilies() {
+		tables := len(family.sstables)
+		err := family.flushMemtable()
+		if err != nil {
+			return err
+		}
+		size, err := family.tablesSize(family.sstables[tables:])
+		if err != nil {
+			return err
+		}
+		written += size
+	}
+	if written != 0 {
+		lsm.root.stats.flush.record(start, 0, written)
+	}
+	return lsm.log.FlushHead()
+}
+
+//Flush memtable of this column family to a new sstable
+func (lsm *LsmTree) flushMemtable() error {
+	if lsm.memtable.Size() == 0 {
+		return nil
+	}
+	writer, tempPath, err := lsm.newTable()
 	if err != nil {
 		return err
 	}
-	writer := NewWriter(file, uint32(20))
 	err = lsm.memtable.Flush(writer)
 	if err != nil {
 		return err
 	}
+	//tombstones of the memtable are in the sstable now
+	lsm.deleted = make(map[string]bool)
 	err = writer.Close()
 	if err != nil {
 		return err
 	}
-	err = lsm.log.FlushHead()
+	sstablePath, err := lsm.commitTable(tempPath)
 	if err != nil {
 		return err
 	}
@@ -207,201 +536,273 @@ func (lsm *LsmTree) Flush() error {
 	return nil
 }
 
+//Create a temporary file for a new sstable,fillSstables ignores it until commitTable
+//so a crash never leaves a half written sstable
+func (lsm *LsmTree) newTable() (*SSTableWriter, string, error) {
+	tempPath := lsm.sstableDir + "/" + RandStringBytes(lsm.root.options.SStableNameLength) + ".sstable" + tempTableSuffix
+	file, err := lsm.fs().OpenFile(tempPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, "", err
+	}
+	return NewWriter(file, lsm.root.options.BlockSize, lsm.compressor), tempPath, nil
+}
+
+//Give the closed and synced table its final name,the directory is synced
+//so the vlog head is never moved past a table that a crash can lose
+func (lsm *LsmTree) commitTable(tempPath string) (string, error) {
+	sstablePath := strings.TrimSuffix(tempPath, tempTableSuffix)
+	if err := lsm.fs().Rename(tempPath, sstablePath); err != nil {
+		return "", err
+	}
+	return sstablePath, lsm.fs().SyncDir(lsm.sstableDir)
+}
+
 func (lsm *LsmTree) save(entry *TableEntry) error {
+	//the old value of the key won't be read anymore
+	if previous, found := lsm.memtable.Get(entry.key); found && !previous.inline {
+		lsm.log.uncache(previous.offset)
+	}
+	compressed, err := entry.withFamily(lsm.family).compressed(lsm.compressor)
+	if err != nil {
+		return err
+	}
 	//append to log
-	meta, err := lsm.log.Append(entry)
+	meta, err := lsm.log.Append(compressed)
 	if err != nil {
 		return err
 	}
 	//save to memtable
-	err = lsm.memtable.Put(entry.key, meta)
+	err = lsm.memtable.Put(entry.key, lsm.inlined(entry, meta))
 	if err != nil {
 		return err
 	}
+	lsm.notify(writeEvent(entry))
 	//if full flush memtable to sstable
 	if lsm.memtable.isFull() {
-		err := lsm.Flush()
+		//the write waits for the flush
+		start := time.Now()
+		err := lsm.flush()
 		if err != nil {
 			return err
 		}
+		lsm.root.stats.writeStall.record(start, 0, 0)
 	}
 	return nil
 }
 
-func (lsm *LsmTree) findInSStables(key []byte) (*SearchEntry, bool) {
-	var latestEntry *SearchEntry
-	for _, tablePath := range lsm.sstables {
-		reader, e := os.Open(tablePath)
-		if e != nil {
-			panic(e)
-		}
-		sstable := ReadTable(reader, lsm.log)
-		searchEntry, found := sstable.Get(key)
-		if found {
-			if latestEntry == nil {
-				latestEntry = searchEntry
-			} else {
-				if searchEntry.timestamp > latestEntry.timestamp {
-					latestEntry = searchEntry
-				}
-			}
-		}
-		sstable.Close()
+func (lsm *LsmTree) findInSStables(key []byte) (*SearchEntry, bool, error) {
+	//only the latest version is read from the vlog,older ones can be already collected by gc
+	entry, found, err := lsm.latestInSStables(key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	searchEntry, err := lsm.log.fetch(entry)
+	if err != nil {
+		return nil, false, err
 	}
-	return latestEntry, latestEntry != nil
+	return searchEntry, true, nil
 }
 
+//Restore entries after the head from the vlog,the head is read from checkpoint when the vlog is opened
+//if there is no checkpoint then nothing was flushed yet and the whole vlog is restored
 func (lsm *LsmTree) restore() error {
-	reader, err := os.OpenFile(lsm.log.checkpoint, os.O_RDONLY, 0666)
-	//if file doesn't exist
-	if errors.Is(err, os.ErrNotExist) {
-		return nil
-	} else {
-		defer reader.Close()
-		stat, err := reader.Stat()
+	return lsm.log.RestoreTo(lsm.log.head, lsm)
+}
+
+//save all sstable paths in memory
+func (lsm *LsmTree) fillSstables() error {
+	//if sstable dir exists then try to get all sstable files from it
+	if _, err := lsm.fs().Stat(lsm.sstableDir); !os.IsNotExist(err) {
+		files, err := lsm.fs().ReadDir(lsm.sstableDir)
 		if err != nil {
 			return err
 		}
-		//if empty => skip
-		if stat.Size() == int64(0) {
-			return nil
-		} else {
-			headBuffer := make([]byte, uint32Size)
-			_, err := reader.Read(headBuffer)
-			if err != nil {
-				return err
+		//subdirectories keep sstables of named column families
+		for _, f := range files {
+			if !f.IsDir() {
+				r, err := regexp.MatchString(sstableExtension, f.Name())
+				if err == nil && r {
+					path, err := lsm.migrateLegacyTable(lsm.sstableDir + "/" + f.Name())
+					if err != nil {
+						return err
+					}
+					lsm.sstables = append(lsm.sstables, path)
+				}
 			}
-			headOffset := binary.BigEndian.Uint32(headBuffer)
-			return lsm.log.RestoreTo(headOffset, lsm.memtable)
 		}
 	}
+	return nil
 }
 
-//save all sstable paths in memory
-func (lsm *LsmTree) fillSstables() {
-	//if sstable dir exists then try to get all sstable files from it
-	if _, err := os.Stat(lsm.sstableDir); !os.IsNotExist(err) {
-		err := filepath.Walk(
-			lsm.sstableDir,
-			func(path string, f os.FileInfo, _ error) error {
-				if !f.IsDir() {
-					r, err := regexp.MatchString(sstableExtension, f.Name())
-					if err == nil && r {
-						lsm.sstables = append(lsm.sstables, lsm.sstableDir+"/"+f.Name())
-					}
-				}
-				return nil
-			},
-		)
-		if err != nil {
-			panic(err)
-		}
+//Values smaller than threshold are stored inline in memtable and sstables,
+//so reading them doesn't touch the vlog and gc skips them.
+//They are still appended to the vlog to be restored after a crash
+func (lsm *LsmTree) SetValueThreshold(threshold int) {
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	lsm.valueThreshold = threshold
+}
+
+//Keep the value in meta if it's small enough
+func (lsm *LsmTree) inlined(entry *TableEntry, meta *ValueMeta) *ValueMeta {
+	if len(entry.value) < lsm.valueThreshold {
+		meta.inline = true
+		meta.value = entry.value
+	}
+	return meta
+}
+
+//Cache sstable blocks of all column families in memory,capacity is in bytes
+func (lsm *LsmTree) SetBlockCache(capacity int) {
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	lsm.root.blockCache = newBlockCache(capacity)
+}
+
+//Hits and misses of the block cache
+func (lsm *LsmTree) BlockCacheStats() CacheStats {
+	if lsm.root.blockCache == nil {
+		return CacheStats{}
+	}
+	return lsm.root.blockCache.cache.stats()
+}
+
+//Size of sstable blocks,bigger blocks make the index smaller but every lookup reads more bytes
+func (lsm *LsmTree) SetBlockSize(size uint32) {
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	lsm.root.options.BlockSize = size
+}
+
+//Compress sstable blocks and vlog values of this column family,nil disables compression
+//column families created after this call inherit the compressor of the default family
+//data that was already written is still readable because every block and value keeps its compression type
+func (lsm *LsmTree) SetCompressor(compressor Compressor) {
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	lsm.compressor = compressor
+}
+
+//Keep recently read vlog entries in memory,capacity is in bytes
+func (lsm *LsmTree) SetValueCache(capacity int) {
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	lsm.log.SetCache(capacity)
+}
+
+func (lsm *LsmTree) ValueCacheStats() CacheStats {
+	if lsm.log.cache == nil {
+		return CacheStats{}
+	}
+	return lsm.log.cache.stats()
+}
+
+//Open sstable for lookups,blocks are read through the block cache of the tree
+func (lsm *LsmTree) openTable(tablePath string) (*SSTable, error) {
+	reader, err := lsm.fs().OpenFile(tablePath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	sstable, err := ReadTable(reader, lsm.log)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("can't read sstable %s: %w", tablePath, err)
+	}
+	if cache := lsm.root.blockCache; cache != nil {
+		sstable.cache = cache
+		sstable.id = cache.tableId(tablePath)
 	}
+	return sstable, nil
+}
+
+//The table was removed or changed on disk so its cached blocks are stale
+func (lsm *LsmTree) forgetCachedBlocks(tablePath string) {
+	if cache := lsm.root.blockCache; cache != nil {
+		cache.forget(tablePath)
+	}
+}
+
+//Check if sstable entry has to survive the merge
+func (lsm *LsmTree) isLive(key []byte, timestamp uint64) (bool, error) {
+	_, err := lsm.get(key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !lsm.isRangeDeleted(key, timestamp), nil
 }
 
 func (lsm *LsmTree) mergeFiles(first *SSTable, second *SSTable) (string, error, bool) {
-	sstablePath := lsm.sstableDir + "/" + RandStringBytes(sstableFileLength) + ".sstable"
-	file, err := os.OpenFile(sstablePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	writer, tempPath, err := lsm.newTable()
 	empty := true
 	if err != nil {
 		return "", err, true
 	}
-	writer := NewWriter(file, uint32(20))
-	var i1, i2 int
-	for i1 < len(first.indexes) && i2 < len(second.indexes) {
-		firstReader := NewReader(first.reader, int64(first.indexes[i1].Offset))
-		secondReader := NewReader(second.reader, int64(second.indexes[i2].Offset))
-		firstKey := string(firstReader.readKey(firstReader.readKeyLength()))
-		secondKey := string(secondReader.readKey(secondReader.readKeyLength()))
-		compare := strings.Compare(firstKey, secondKey)
+	//write only entries that weren't deleted
+	write := func(entry *sstableEntry) error {
+		live, err := lsm.isLive(entry.key, entry.timeStamp)
+		if err != nil || !live {
+			return err
+		}
+		empty = false
+		return writer.WriteEntry(entry)
+	}
+	firstIterator, secondIterator := first.iterator(), second.iterator()
+	firstEntry, err := firstIterator.next()
+	if err != nil {
+		return "", err, true
+	}
+	secondEntry, err := secondIterator.next()
+	if err != nil {
+		return "", err, true
+	}
+	for firstEntry != nil || secondEntry != nil {
+		compare := 0
+		if firstEntry == nil {
+			compare = 1
+		} else if secondEntry == nil {
+			compare = -1
+		} else {
+			compare = bytes.Compare(firstEntry.key, secondEntry.key)
+		}
 		if compare > 0 {
-			timestamp := secondReader.readTimestamp()
-			offset := secondReader.readValueOffset()
-			length := secondReader.readValueLength()
-			_, found := lsm.Get([]byte(secondKey))
-			if found {
-				_, err := writer.WriteEntry(&sstableEntry{key: []byte(secondKey), timeStamp: timestamp, valueOffset: offset, valueLength: length})
-				if err != nil {
-					return "", err, true
-				}
-				empty = false
+			err = write(secondEntry)
+			if err == nil {
+				secondEntry, err = secondIterator.next()
 			}
-			i2++
 		} else if compare < 0 {
-			timestamp := firstReader.readTimestamp()
-			offset := firstReader.readValueOffset()
-			length := firstReader.readValueLength()
-			_, found := lsm.Get([]byte(firstKey))
-			if found {
-				_, err := writer.WriteEntry(&sstableEntry{key: []byte(firstKey), timeStamp: timestamp, valueOffset: offset, valueLength: length})
-				if err != nil {
-					return "", err, true
-				}
-				empty = false
+			err = write(firstEntry)
+			if err == nil {
+				firstEntry, err = firstIterator.next()
 			}
-			i1++
 		} else {
-			firstTm := firstReader.readTimestamp()
-			secondTm := secondReader.readTimestamp()
-			_, found := lsm.Get([]byte(firstKey))
-			if found {
-				if firstTm > secondTm {
-					offset := firstReader.readValueOffset()
-					length := firstReader.readValueLength()
-					_, err := writer.WriteEntry(&sstableEntry{key: []byte(firstKey), timeStamp: firstTm, valueOffset: offset, valueLength: length})
-					if err != nil {
-						return "", err, true
-					}
-				} else {
-					offset := secondReader.readValueOffset()
-					length := secondReader.readValueLength()
-					_, err := writer.WriteEntry(&sstableEntry{key: []byte(firstKey), timeStamp: secondTm, valueOffset: offset, valueLength: length})
-					if err != nil {
-						return "", err, true
-					}
-				}
-				empty = false
+			//the same key in both tables,keep the latest one
+			entry := secondEntry
+			if firstEntry.timeStamp > secondEntry.timeStamp {
+				entry = firstEntry
 			}
-			i1++
-			i2++
-		}
-	}
-	for i1 < len(first.indexes) {
-		reader := NewReader(first.reader, int64(first.indexes[i1].Offset))
-		key := reader.readKey(reader.readKeyLength())
-		timestamp := reader.readTimestamp()
-		offset := reader.readValueOffset()
-		length := reader.readValueLength()
-		_, found := lsm.Get(key)
-		if found {
-			_, err := writer.WriteEntry(&sstableEntry{key: key, timeStamp: timestamp, valueOffset: offset, valueLength: length})
-			if err != nil {
-				return "", err, true
+			err = write(entry)
+			if err == nil {
+				firstEntry, err = firstIterator.next()
 			}
-			empty = true
-		}
-		i1++
-	}
-	for i2 < len(second.indexes) {
-		reader := NewReader(second.reader, int64(second.indexes[i2].Offset))
-		key := reader.readKey(reader.readKeyLength())
-		timestamp := reader.readTimestamp()
-		offset := reader.readValueOffset()
-		length := reader.readValueLength()
-		_, found := lsm.Get(key)
-		if found {
-			_, err := writer.WriteEntry(&sstableEntry{key: key, timeStamp: timestamp, valueOffset: offset, valueLength: length})
-			if err != nil {
-				return "", err, true
+			if err == nil {
+				secondEntry, err = secondIterator.next()
 			}
-			empty = false
 		}
-
-		i2++
+		if err != nil {
+			return "", err, true
+		}
 	}
 	err = writer.Close()
 	if err != nil {
 		return "", err, true
 	}
-	return sstablePath, nil, empty
+	//nothing is alive,the table isn't needed
+	if empty {
+		return "", lsm.fs().Remove(tempPath), true
+	}
+	sstablePath, err := lsm.commitTable(tempPath)
+	return sstablePath, err, false
 }
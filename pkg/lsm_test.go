@@ -2,7 +2,8 @@ package wiskey
 
 import (
 	"bytes"
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -20,19 +21,50 @@ func FakeEntries() []TableEntry {
 	}
 }
 
-func InitTestLsmWithMeta(size int, gc uint) *LsmTree {
-	tempDir, _ := ioutil.TempDir("", "")
-	vlogFile, _ := ioutil.TempFile("", "")
-	checkpoint, _ := ioutil.TempFile("", "")
-	vlog := NewVlog(vlogFile.Name(), checkpoint.Name())
-	return NewLsmTree(vlog, tempDir, NewMemTable(size), gc)
+//Tree on the in memory file system,gc is the merge interval in seconds
+//it's closed at the end of the test,so background workers don't outlive it
+func InitTestLsmWithMeta(t *testing.T, size int, gc uint) *LsmTree {
+	fs := NewMemFS()
+	vlog, err := newVlog(fs, "/vlog", "/checkpoint")
+	if err != nil {
+		panic(err)
+	}
+	options := DefaultOptions()
+	options.FS = fs
+	options.CompactionInterval = time.Duration(gc) * time.Second
+	tree, err := openLsmTree(vlog, "/sstable", NewMemTable(size), options)
+	if err != nil {
+		panic(err)
+	}
+	tree.startWorkers()
+	t.Cleanup(func() {
+		tree.Close()
+	})
+	return tree
+}
+
+//Amount of sstables,background merge can change them at the same time
+func tableCount(tree *LsmTree) int {
+	tree.rwm.RLock()
+	defer tree.rwm.RUnlock()
+	return len(tree.sstables)
+}
+
+//Open the same files again as after restart
+func reopenTestLsm(t *testing.T, tree *LsmTree, size int) *LsmTree {
+	vlog, err := newVlog(tree.fs(), tree.log.file, tree.log.checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTree, err := openLsmTree(vlog, tree.sstableDir, NewMemTable(size), tree.root.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTree
 }
 
 func TestLsmTree_GetDeletedValue(t *testing.T) {
-	tree := InitTestLsmWithMeta(100, 30)
-	defer os.RemoveAll(tree.sstableDir)
-	defer os.Remove(tree.log.file)
-	defer os.Remove(tree.log.checkpoint)
+	tree := InitTestLsmWithMeta(t, 100, 30)
 	key := []byte("ANITA")
 	value := []byte("DEVELOPER")
 	//save entry and flush to sstable
@@ -54,17 +86,14 @@ func TestLsmTree_GetDeletedValue(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, found := tree.Get(key)
-	if found {
+	_, err = tree.Get(key)
+	if !errors.Is(err, ErrNotFound) {
 		t.Fatal("Deleted key was found")
 	}
 }
 
 func TestLsmTree_PutAndGetFromSSTable(t *testing.T) {
-	tree := InitTestLsmWithMeta(100, 30)
-	defer os.RemoveAll(tree.sstableDir)
-	defer os.Remove(tree.log.file)
-	defer os.Remove(tree.log.checkpoint)
+	tree := InitTestLsmWithMeta(t, 100, 30)
 	entries := FakeEntries()
 	//save entries in unsorted order, it will be sorted by memtable
 	for _, entry := range entries {
@@ -79,8 +108,8 @@ func TestLsmTree_PutAndGetFromSSTable(t *testing.T) {
 	}
 	//fetch entries
 	for _, entry := range entries {
-		result, found := tree.Get(entry.key)
-		if !found {
+		result, err := tree.Get(entry.key)
+		if err != nil {
 			t.Fatal("Key wasn't found in sstable")
 		}
 		if bytes.Compare(result, entry.value) != 0 {
@@ -88,18 +117,15 @@ func TestLsmTree_PutAndGetFromSSTable(t *testing.T) {
 		}
 	}
 	//try to find non existing key
-	_, found := tree.Get([]byte("NON EXISTING KEY"))
-	if found {
+	_, err = tree.Get([]byte("NON EXISTING KEY"))
+	if !errors.Is(err, ErrNotFound) {
 		t.Error("Found non existing key")
 	}
 }
 
 //Test get when in memory
 func TestLsmTree_GetInMemory(t *testing.T) {
-	tree := InitTestLsmWithMeta(100, 30)
-	defer os.RemoveAll(tree.sstableDir)
-	defer os.Remove(tree.log.file)
-	defer os.Remove(tree.log.checkpoint)
+	tree := InitTestLsmWithMeta(t, 100, 30)
 	entries := FakeEntries()
 	//save entries but don't flush
 	for _, entry := range entries {
@@ -109,8 +135,8 @@ func TestLsmTree_GetInMemory(t *testing.T) {
 		}
 	}
 	for _, entry := range entries {
-		value, found := tree.Get(entry.key)
-		if !found {
+		value, err := tree.Get(entry.key)
+		if err != nil {
 			t.Fatal("Value was not found in lsm tree")
 		}
 		if bytes.Compare(value, entry.value) != 0 {
@@ -119,12 +145,45 @@ func TestLsmTree_GetInMemory(t *testing.T) {
 	}
 }
 
+//Flush is exported,so it can run at the same time as puts and has to take the lock itself
+func TestLsmTree_ConcurrentFlush(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 1000, 30)
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 50; i++ {
+			if err := tree.Flush(); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for i := 0; i < 500; i++ {
+		entry := NewEntry([]byte(fmt.Sprintf("key%03d", i)), []byte("value"))
+		if err := tree.Put(&entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		value, err := tree.Get([]byte(fmt.Sprintf("key%03d", i)))
+		if err != nil || string(value) != "value" {
+			t.Fatalf("Key %d returned %q %v", i, value, err)
+		}
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Flush(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Flush of closed tree returned %v", err)
+	}
+}
+
 func TestLsmTree_Merge(t *testing.T) {
 	//init lsm with merge time 5 sec
-	tree := InitTestLsmWithMeta(20, 4)
-	defer os.RemoveAll(tree.sstableDir)
-	defer os.Remove(tree.log.file)
-	defer os.Remove(tree.log.checkpoint)
+	tree := InitTestLsmWithMeta(t, 20, 4)
 	entries := FakeEntries()
 	//save entries ,because size is only 20 it had to be flushed
 	savedCnt := 0
@@ -135,7 +194,7 @@ func TestLsmTree_Merge(t *testing.T) {
 			t.Fatal(err)
 		}
 		//store exactly 3 sstables
-		if len(tree.sstables) == 3 {
+		if tableCount(tree) == 3 {
 			break
 		}
 	}
@@ -148,11 +207,11 @@ func TestLsmTree_Merge(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	amount := len(tree.sstables)
+	amount := tableCount(tree)
 	t.Logf("Lsm has %d files before merge", amount)
 	//wait for merge
 	time.Sleep(6 * time.Second)
-	sizeAfterGc := len(tree.sstables)
+	sizeAfterGc := tableCount(tree)
 	if sizeAfterGc != 2 {
 		t.Fatal("Amount of sstables after merge had to be decreased by 2 times")
 	}
@@ -161,16 +220,16 @@ func TestLsmTree_Merge(t *testing.T) {
 			break
 		}
 		savedCnt--
-		_, found := tree.Get(entry.key)
-		if !found && i != 0 && i != 1 {
+		_, err := tree.Get(entry.key)
+		if err != nil && i != 0 && i != 1 {
 			t.Fatal("Wasn't able to find key after merge")
 		}
 	}
-	stat, err := os.Stat(tree.log.file)
+	stat, err := tree.fs().Stat(tree.log.file)
 	if err != nil {
 		t.Fatal(err)
 	}
-	sizeBefore := uint32(stat.Size())
+	sizeBefore := uint64(stat.Size())
 	err = tree.CompressVlog()
 	if err != nil {
 		t.Fatal(err)
@@ -182,10 +241,7 @@ func TestLsmTree_Merge(t *testing.T) {
 }
 
 func TestLsmTree_Restore(t *testing.T) {
-	tree := InitTestLsmWithMeta(100, 30)
-	defer os.RemoveAll(tree.sstableDir)
-	defer os.Remove(tree.log.file)
-	defer os.Remove(tree.log.checkpoint)
+	tree := InitTestLsmWithMeta(t, 100, 30)
 	entries := FakeEntries()
 	//save entries but don't flush
 	for index, entry := range entries {
@@ -202,18 +258,16 @@ func TestLsmTree_Restore(t *testing.T) {
 		}
 	}
 	//now before flush we create a new lsm tree
-	vlog := NewVlog(tree.log.file, tree.log.checkpoint)
 	//this tree has to have last half of entries restored from the vlog
-	newTree := NewLsmTree(vlog, tree.sstableDir, NewMemTable(100), 30)
+	newTree := reopenTestLsm(t, tree, 100)
 	for index := len(entries)/2 + 1; index < len(entries); index++ {
-		_, found := newTree.Get(entries[index].key)
-		if !found {
+		_, err := newTree.Get(entries[index].key)
+		if err != nil {
 			t.Fatal("Didn't restore the key from vlog")
 		}
 	}
 	//if we try to restore it again it will be restored because we didn't flush a previous one
-	vlog = NewVlog(tree.log.file, tree.log.checkpoint)
-	newTree = NewLsmTree(vlog, tree.sstableDir, NewMemTable(100), 30)
+	newTree = reopenTestLsm(t, tree, 100)
 	if newTree.memtable.Size() == 0 {
 		t.Fatal("Should restore not flushed entries")
 	}
@@ -222,9 +276,381 @@ func TestLsmTree_Restore(t *testing.T) {
 		t.Fatal(err)
 	}
 	//now it was flushed so memtable has to be empty
-	vlog = NewVlog(tree.log.file, tree.log.checkpoint)
-	newTree = NewLsmTree(vlog, tree.sstableDir, NewMemTable(100), 30)
+	newTree = reopenTestLsm(t, tree, 100)
 	if newTree.memtable.Size() != 0 {
 		t.Fatal("Memtable has to be empty after flush")
 	}
 }
+
+//Nothing was flushed yet,so there is no checkpoint and the whole vlog is restored
+func TestLsmTree_RestoreWithoutCheckpoint(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 1000, 30)
+	entries := FakeEntries()
+	for _, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tree.fs().Stat(tree.log.checkpoint); !os.IsNotExist(err) {
+		t.Fatalf("Checkpoint has to be written only by a flush,got %v", err)
+	}
+	newTree := reopenTestLsm(t, tree, 1000)
+	for _, entry := range entries {
+		value, err := newTree.Get(entry.key)
+		if err != nil || !bytes.Equal(value, entry.value) {
+			t.Fatalf("Key %s wasn't restored from vlog", entry.key)
+		}
+	}
+}
+
+//The last entry was partially written by a crash,it's dropped and the vlog is truncated to the previous entry
+func TestLsmTree_RestoreTornTail(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	entries := FakeEntries()
+	for _, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := tree.log.size
+	buffer := bytes.NewBuffer([]byte{})
+	torn := NewEntry([]byte("TORN"), []byte("DEVELOPER"))
+	if _, err := torn.writeTo(buffer); err != nil {
+		t.Fatal(err)
+	}
+	file, err := tree.fs().OpenFile(tree.log.file, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(buffer.Bytes()[:buffer.Len()-3]); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	newTree := reopenTestLsm(t, tree, 100)
+	if newTree.log.size != size {
+		t.Fatalf("Vlog has to be truncated to %d bytes,but it has %d", size, newTree.log.size)
+	}
+	if _, err := newTree.Get(torn.key); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Torn entry was restored")
+	}
+	for _, entry := range entries {
+		if _, err := newTree.Get(entry.key); err != nil {
+			t.Fatalf("Key %s before the torn entry wasn't restored", entry.key)
+		}
+	}
+	//new entries are appended right after the last whole entry
+	err = newTree.Put(&TableEntry{key: []byte("ZNITA"), value: []byte("DEVELOPER7")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTree = reopenTestLsm(t, tree, 100)
+	value, err := newTree.Get([]byte("ZNITA"))
+	if err != nil || string(value) != "DEVELOPER7" {
+		t.Fatal("Entry written after the truncation wasn't restored")
+	}
+}
+
+//The last entry has valid lengths but its bytes never reached the disk,the checksum drops it like a cut entry
+//while the same damage before other entries is a corruption
+func TestLsmTree_RestoreChecksumMismatch(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	entries := FakeEntries()
+	for _, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := tree.log.size
+	buffer := bytes.NewBuffer([]byte{})
+	torn := NewEntry([]byte("TORN"), []byte("DEVELOPER"))
+	if _, err := torn.writeTo(buffer); err != nil {
+		t.Fatal(err)
+	}
+	damaged := append([]byte{}, buffer.Bytes()...)
+	copy(damaged[uint32Size*2+len(torn.key):], make([]byte, len(torn.value)))
+	file, err := tree.fs().OpenFile(tree.log.file, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(damaged); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	newTree := reopenTestLsm(t, tree, 100)
+	if newTree.log.size != size {
+		t.Fatalf("Vlog has to be truncated to %d bytes,but it has %d", size, newTree.log.size)
+	}
+	if _, err := newTree.Get(torn.key); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Entry with wrong checksum was restored")
+	}
+	file, err = tree.fs().OpenFile(tree.log.file, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(damaged)
+	file.Write(buffer.Bytes())
+	file.Close()
+	vlog, err := newVlog(tree.fs(), tree.log.file, tree.log.checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openLsmTree(vlog, tree.sstableDir, NewMemTable(100), tree.root.options); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Damaged entry before the last one has to be a corruption, got %v", err)
+	}
+}
+
+//Offsets only grow after gc,so they pass 4GiB even if the vlog file is small
+func TestLsmTree_OffsetsAbove4GiB(t *testing.T) {
+	fs := NewMemFS()
+	tail := uint64(1<<32 - 20)
+	if err := writeFile(fs, "/checkpoint", testCheckpoint(tail, tail)); err != nil {
+		t.Fatal(err)
+	}
+	vlog, err := newVlog(fs, "/vlog", "/checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultOptions()
+	options.FS = fs
+	tree, err := openLsmTree(vlog, "/sstable", NewMemTable(1000), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := FakeEntries()
+	for _, entry := range entries {
+		if err := tree.Put(&entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tree.log.tail+tree.log.size <= 1<<32 {
+		t.Fatalf("Vlog has to end after 4GiB,it ends at %d", tree.log.tail+tree.log.size)
+	}
+	if err := tree.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	//offsets are read from the sstable and the checkpoint after restart
+	newTree := reopenTestLsm(t, tree, 1000)
+	for _, entry := range entries {
+		value, err := newTree.Get(entry.key)
+		if err != nil || !bytes.Equal(value, entry.value) {
+			t.Fatalf("Key %s wasn't found after 4GiB,got %q %v", entry.key, value, err)
+		}
+	}
+}
+
+func TestLsmTree_DeleteRange(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	entries := FakeEntries()
+	for _, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//this one stays in memtable
+	err = tree.Put(&TableEntry{key: []byte("CNITA"), value: []byte("DEVELOPER7")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//deletes BNITA,CNITA,GNITA and NNITA
+	err = tree.DeleteRange([]byte("B"), []byte("O"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := []string{"BNITA", "CNITA", "GNITA", "NNITA"}
+	for _, key := range deleted {
+		if _, err := tree.Get([]byte(key)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Key %s in deleted range was found", key)
+		}
+	}
+	for _, key := range []string{"ANITA", "TNITA", "WNITA"} {
+		if _, err := tree.Get([]byte(key)); err != nil {
+			t.Fatalf("Key %s outside of deleted range wasn't found", key)
+		}
+	}
+	//key written after the range tombstone is visible
+	err = tree.Put(&TableEntry{key: []byte("GNITA"), value: []byte("DEVELOPER8")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := tree.Get([]byte("GNITA"))
+	if err != nil || string(value) != "DEVELOPER8" {
+		t.Fatal("Key written after the range tombstone wasn't found")
+	}
+	//range tombstone has to survive restart
+	newTree := reopenTestLsm(t, tree, 100)
+	if _, err := newTree.Get([]byte("BNITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Range tombstone wasn't restored")
+	}
+	//merge drops covered entries together with the tombstone
+	err = newTree.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newTree.rangeTombstones) != 0 {
+		t.Fatal("Range tombstones have to be removed after merge")
+	}
+	for _, key := range deleted {
+		if key == "GNITA" {
+			continue
+		}
+		if _, err := newTree.Get([]byte(key)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Key %s in deleted range was found after merge", key)
+		}
+	}
+	if _, err := newTree.Get([]byte("GNITA")); err != nil {
+		t.Fatal("Key written after the range tombstone wasn't found after merge")
+	}
+}
+
+func TestLsmTree_InlineValues(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 200, 30)
+	tree.SetValueThreshold(32)
+	entries := FakeEntries()
+	for _, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	large := NewEntry([]byte("LARGE"), bytes.Repeat([]byte("V"), 40))
+	err := tree.Put(&large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := tree.memtable.Get(large.key)
+	if meta.inline {
+		t.Fatal("Value bigger than threshold was inlined")
+	}
+	err = tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//gc doesn't relocate inline values,so the size decreases by the size of collected entries
+	sizeBefore := tree.log.size
+	err = tree.CompressVlog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collected := uint64(0)
+	for _, entry := range entries[:2] {
+		collected += uint64(uint32Size*3 + len(entry.key) + len(entry.value))
+	}
+	if tree.log.size != sizeBefore-collected {
+		t.Fatalf("Gc had to skip inline values, size was %d became %d", sizeBefore, tree.log.size)
+	}
+	//inline values are read without vlog
+	err = tree.fs().Truncate(tree.log.file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		value, err := tree.Get(entry.key)
+		if err != nil || bytes.Compare(value, entry.value) != 0 {
+			t.Fatalf("Inline value of %s wasn't found", entry.key)
+		}
+	}
+}
+
+func TestLsmTree_CompressVlogKeepsLiveValues(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	entries := FakeEntries()
+	for _, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	//the first version of ANITA becomes garbage
+	err := tree.Put(&TableEntry{key: []byte("ANITA"), value: []byte("MANAGER")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Delete([]byte("GNITA"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//collect the whole flushed part of the vlog
+	head := tree.log.head
+	for tree.log.tail < head {
+		err := tree.CompressVlog()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[string]string{}
+	for _, entry := range entries {
+		expected[string(entry.key)] = string(entry.value)
+	}
+	expected["ANITA"] = "MANAGER"
+	delete(expected, "GNITA")
+	check := func(tree *LsmTree) {
+		for _, entry := range entries {
+			value, err := tree.Get(entry.key)
+			want, ok := expected[string(entry.key)]
+			if (err == nil) != ok || string(value) != want {
+				t.Fatalf("Wrong value of %s after gc: %s", entry.key, value)
+			}
+		}
+	}
+	check(tree)
+	//relocated values are flushed and survive restart together with the tail
+	err = tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTree := reopenTestLsm(t, tree, 100)
+	if newTree.log.tail != tree.log.tail {
+		t.Fatalf("Tail wasn't saved in checkpoint, expected %d got %d", tree.log.tail, newTree.log.tail)
+	}
+	check(newTree)
+}
+
+//Deleting the same range again has to delete keys that were flushed after the first delete
+func TestLsmTree_DeleteRangeAgain(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	err := tree.DeleteRange([]byte("B"), []byte("O"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Put(&TableEntry{key: []byte("GNITA"), value: []byte("DEVELOPER3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Get([]byte("GNITA")); err != nil {
+		t.Fatal("Key written after the range tombstone wasn't found")
+	}
+	err = tree.DeleteRange([]byte("B"), []byte("O"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.rangeTombstones) != 1 {
+		t.Fatalf("The same range has to keep a single tombstone,got %d", len(tree.rangeTombstones))
+	}
+	if _, err := tree.Get([]byte("GNITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Key flushed before the second delete was found")
+	}
+	newTree := reopenTestLsm(t, tree, 100)
+	if _, err := newTree.Get([]byte("GNITA")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Key flushed before the second delete was found after restart")
+	}
+}
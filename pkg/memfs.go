@@ -0,0 +1,404 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//File system that keeps everything in memory,it's used by tests
+//every file remembers the content of the last Sync and every directory the files of the last SyncDir,
+//so a crash can be simulated by dropUnsynced,directories themselves are durable immediately
+type MemFS struct {
+	mutex   sync.Mutex
+	files   map[string]*memData
+	durable map[string]*memData //files as of the last SyncDir of their directory
+	dirs    map[string]bool
+	locks   map[string]bool
+}
+
+//Content of the file,open files keep it even after the file was renamed or removed
+type memData struct {
+	data    []byte
+	synced  []byte
+	modTime time.Time
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files:   make(map[string]*memData),
+		durable: make(map[string]*memData),
+		dirs:    map[string]bool{"/": true, ".": true},
+		locks:   make(map[string]bool),
+	}
+}
+
+func pathError(op string, path string, err error) error {
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
+
+func (fs *MemFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	name = filepath.Clean(name)
+	data, exists := fs.files[name]
+	if fs.dirs[name] {
+		return nil, pathError("open", name, errors.New("is a directory"))
+	}
+	if !exists {
+		if flag&os.O_CREATE == 0 {
+			return nil, pathError("open", name, os.ErrNotExist)
+		}
+		if !fs.dirs[filepath.Dir(name)] {
+			return nil, pathError("open", name, os.ErrNotExist)
+		}
+		data = &memData{modTime: time.Now()}
+		fs.files[name] = data
+	} else if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, pathError("open", name, os.ErrExist)
+	}
+	if flag&os.O_TRUNC != 0 {
+		data.data = nil
+	}
+	return &memFile{fs: fs, name: name, content: data, flag: flag}, nil
+}
+
+func (fs *MemFS) Remove(name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	name = filepath.Clean(name)
+	if _, ok := fs.files[name]; ok {
+		delete(fs.files, name)
+		return nil
+	}
+	if fs.dirs[name] {
+		if len(fs.children(name)) != 0 {
+			return pathError("remove", name, errors.New("directory not empty"))
+		}
+		delete(fs.dirs, name)
+		return nil
+	}
+	return pathError("remove", name, os.ErrNotExist)
+}
+
+func (fs *MemFS) Rename(oldpath string, newpath string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	data, ok := fs.files[oldpath]
+	if !ok {
+		return pathError("rename", oldpath, os.ErrNotExist)
+	}
+	if !fs.dirs[filepath.Dir(newpath)] {
+		return pathError("rename", newpath, os.ErrNotExist)
+	}
+	delete(fs.files, oldpath)
+	fs.files[newpath] = data
+	return nil
+}
+
+func (fs *MemFS) Stat(name string) (os.FileInfo, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.stat(filepath.Clean(name))
+}
+
+func (fs *MemFS) stat(name string) (os.FileInfo, error) {
+	if fs.dirs[name] {
+		return &memInfo{name: filepath.Base(name), dir: true}, nil
+	}
+	if data, ok := fs.files[name]; ok {
+		return &memInfo{name: filepath.Base(name), size: int64(len(data.data)), modTime: data.modTime}, nil
+	}
+	return nil, pathError("stat", name, os.ErrNotExist)
+}
+
+func (fs *MemFS) MkdirAll(path string, perm os.FileMode) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	for path = filepath.Clean(path); !fs.dirs[path]; path = filepath.Dir(path) {
+		if _, ok := fs.files[path]; ok {
+			return pathError("mkdir", path, errors.New("not a directory"))
+		}
+		fs.dirs[path] = true
+	}
+	return nil
+}
+
+func (fs *MemFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	dirname = filepath.Clean(dirname)
+	if !fs.dirs[dirname] {
+		return nil, pathError("open", dirname, os.ErrNotExist)
+	}
+	var infos []os.FileInfo
+	for _, child := range fs.children(dirname) {
+		info, _ := fs.stat(child)
+		infos = append(infos, info)
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+//Files and directories directly in the directory
+func (fs *MemFS) children(dirname string) []string {
+	var children []string
+	for name := range fs.files {
+		if filepath.Dir(name) == dirname {
+			children = append(children, name)
+		}
+	}
+	for name := range fs.dirs {
+		if name != dirname && filepath.Dir(name) == dirname {
+			children = append(children, name)
+		}
+	}
+	return children
+}
+
+func (fs *MemFS) Truncate(name string, size int64) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	data, ok := fs.files[filepath.Clean(name)]
+	if !ok {
+		return pathError("truncate", name, os.ErrNotExist)
+	}
+	data.data = resize(data.data, size)
+	return nil
+}
+
+func (fs *MemFS) SyncDir(dirname string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	dirname = filepath.Clean(dirname)
+	if !fs.dirs[dirname] {
+		return pathError("sync", dirname, os.ErrNotExist)
+	}
+	for name := range fs.durable {
+		if _, ok := fs.files[name]; !ok && filepath.Dir(name) == dirname {
+			delete(fs.durable, name)
+		}
+	}
+	for name, data := range fs.files {
+		if filepath.Dir(name) == dirname {
+			fs.durable[name] = data
+		}
+	}
+	return nil
+}
+
+func (fs *MemFS) Lock(name string) (io.Closer, error) {
+	file, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	name = filepath.Clean(name)
+	if fs.locks[name] {
+		return nil, pathError("lock", name, errors.New("resource temporarily unavailable"))
+	}
+	fs.locks[name] = true
+	return &memLock{fs: fs, name: name}, nil
+}
+
+type memLock struct {
+	fs   *MemFS
+	name string
+}
+
+func (lock *memLock) Close() error {
+	lock.fs.mutex.Lock()
+	defer lock.fs.mutex.Unlock()
+	delete(lock.fs.locks, lock.name)
+	return nil
+}
+
+//Lose everything that wasn't synced and release all locks as if the process died with the machine
+//files that were created,renamed or removed after the last SyncDir get their old names back
+//if rnd is set then a random prefix of data appended after the last sync survives
+func (fs *MemFS) dropUnsynced(rnd *rand.Rand) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.files = make(map[string]*memData, len(fs.durable))
+	for name, data := range fs.durable {
+		fs.files[name] = data
+		for dir := filepath.Dir(name); !fs.dirs[dir]; dir = filepath.Dir(dir) {
+			fs.dirs[dir] = true
+		}
+	}
+	for _, data := range fs.files {
+		kept := append([]byte{}, data.synced...)
+		if rnd != nil && bytes.HasPrefix(data.data, data.synced) && len(data.data) > len(data.synced) {
+			kept = append(kept, data.data[len(data.synced):len(data.synced)+rnd.Intn(len(data.data)-len(data.synced)+1)]...)
+		}
+		data.data = kept
+		//whatever survived the crash is on the disk
+		data.synced = append([]byte{}, kept...)
+	}
+	fs.locks = make(map[string]bool)
+}
+
+//Grow the slice with zeros or cut it
+func resize(data []byte, size int64) []byte {
+	if int64(len(data)) >= size {
+		return data[:size]
+	}
+	return append(data, make([]byte, size-int64(len(data)))...)
+}
+
+func sortInfos(infos []os.FileInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		return strings.Compare(infos[i].Name(), infos[j].Name()) < 0
+	})
+}
+
+type memFile struct {
+	fs      *MemFS
+	name    string
+	content *memData
+	flag    int
+	offset  int64
+	closed  bool
+}
+
+func (file *memFile) Read(buffer []byte) (int, error) {
+	read, err := file.ReadAt(buffer, file.offset)
+	file.offset += int64(read)
+	if err == io.EOF && read != 0 {
+		err = nil
+	}
+	return read, err
+}
+
+func (file *memFile) ReadAt(buffer []byte, offset int64) (int, error) {
+	file.fs.mutex.Lock()
+	defer file.fs.mutex.Unlock()
+	if file.closed {
+		return 0, os.ErrClosed
+	}
+	if file.flag&(os.O_WRONLY|os.O_RDWR) == os.O_WRONLY {
+		return 0, pathError("read", file.name, errors.New("bad file descriptor"))
+	}
+	data := file.content.data
+	if offset >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	read := copy(buffer, data[offset:])
+	if read < len(buffer) {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+func (file *memFile) Write(buffer []byte) (int, error) {
+	file.fs.mutex.Lock()
+	defer file.fs.mutex.Unlock()
+	if file.closed {
+		return 0, os.ErrClosed
+	}
+	if file.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, pathError("write", file.name, errors.New("bad file descriptor"))
+	}
+	content := file.content
+	if file.flag&os.O_APPEND != 0 {
+		file.offset = int64(len(content.data))
+	}
+	end := file.offset + int64(len(buffer))
+	if end > int64(len(content.data)) {
+		content.data = resize(content.data, end)
+	}
+	copy(content.data[file.offset:], buffer)
+	file.offset = end
+	content.modTime = time.Now()
+	return len(buffer), nil
+}
+
+func (file *memFile) Seek(offset int64, whence int) (int64, error) {
+	file.fs.mutex.Lock()
+	defer file.fs.mutex.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += file.offset
+	case io.SeekEnd:
+		offset += int64(len(file.content.data))
+	}
+	if offset < 0 {
+		return 0, pathError("seek", file.name, errors.New("invalid argument"))
+	}
+	file.offset = offset
+	return offset, nil
+}
+
+func (file *memFile) Close() error {
+	file.fs.mutex.Lock()
+	defer file.fs.mutex.Unlock()
+	if file.closed {
+		return os.ErrClosed
+	}
+	file.closed = true
+	return nil
+}
+
+func (file *memFile) Sync() error {
+	file.fs.mutex.Lock()
+	defer file.fs.mutex.Unlock()
+	if file.closed {
+		return os.ErrClosed
+	}
+	file.content.synced = append(file.content.synced[:0], file.content.data...)
+	return nil
+}
+
+func (file *memFile) Stat() (os.FileInfo, error) {
+	file.fs.mutex.Lock()
+	defer file.fs.mutex.Unlock()
+	return &memInfo{name: filepath.Base(file.name), size: int64(len(file.content.data)), modTime: file.content.modTime}, nil
+}
+
+func (file *memFile) Name() string {
+	return file.name
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (info *memInfo) Name() string {
+	return info.name
+}
+
+func (info *memInfo) Size() int64 {
+	return info.size
+}
+
+func (info *memInfo) Mode() os.FileMode {
+	if info.dir {
+		return os.ModeDir | 0755
+	}
+	return 0666
+}
+
+func (info *memInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info *memInfo) IsDir() bool {
+	return info.dir
+}
+
+func (info *memInfo) Sys() interface{} {
+	return nil
+}
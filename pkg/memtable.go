@@ -28,7 +28,7 @@ func (memtable *Memtable) Flush(writer *SSTableWriter) error {
 	for iterator.Next() {
 		key := iterator.Key().(string)
 		valueMeta := iterator.Value().(*ValueMeta)
-		_, err := writer.WriteEntry(NewSStableEntry([]byte(key), valueMeta))
+		err := writer.WriteEntry(NewSStableEntry([]byte(key), valueMeta))
 		if err != nil {
 			return err
 		}
@@ -42,8 +42,11 @@ func (memtable *Memtable) Put(key []byte, value *ValueMeta) error {
 	if strings.Compare(string(key), tombstone) == 0 {
 		return errors.New("can't use this key, it's reserved as tombstone")
 	}
+	if old, found := memtable.tree.Get(string(key)); found {
+		memtable.size -= entrySize(key, old.(*ValueMeta))
+	}
 	memtable.tree.Put(string(key), value)
-	memtable.increaseSize(key)
+	memtable.size += entrySize(key, value)
 	return nil
 }
 
@@ -56,6 +59,26 @@ func (memtable *Memtable) Get(key []byte) (*ValueMeta, bool) {
 	}
 }
 
+//Remove all keys in [start,end) from the tree
+func (memtable *Memtable) DeleteRange(start []byte, end []byte) {
+	var keys []string
+	iterator := memtable.tree.Iterator()
+	for iterator.Next() {
+		key := iterator.Key().(string)
+		if key >= string(end) {
+			break
+		}
+		if key >= string(start) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		value, _ := memtable.tree.Get(key)
+		memtable.tree.Remove(key)
+		memtable.size -= entrySize([]byte(key), value.(*ValueMeta))
+	}
+}
+
 func (memtable *Memtable) Size() int {
 	return memtable.tree.Size()
 }
@@ -64,7 +87,7 @@ func (memtable *Memtable) isFull() bool {
 	return memtable.size > memtable.maxSize
 }
 
-func (memtable *Memtable) increaseSize(key []byte) {
-	memtable.size += len(key)
-	memtable.size += uint32Size * 2 //add offset + length from the vlog
+//Bytes of the entry in the tree,the key with offset + length from the vlog and the inlined value
+func entrySize(key []byte, value *ValueMeta) int {
+	return len(key) + int64Size + uint32Size + len(value.value)
 }
@@ -12,7 +12,7 @@ const (
 func TestMemtable_Put(t *testing.T) {
 	table := NewMemTable(memTableSize)
 	key := []byte("myKey")
-	value := &ValueMeta{length: rand.Uint32(), offset: rand.Uint32()}
+	value := &ValueMeta{length: rand.Uint32(), offset: rand.Uint64()}
 	err := table.Put(key, value)
 	if err != nil {
 		t.Error(table)
@@ -36,8 +36,42 @@ func TestMemtable_GetNonExistingKey(t *testing.T) {
 
 func TestMemtable_PutTomb(t *testing.T) {
 	table := NewMemTable(memTableSize)
-	err := table.Put([]byte(tombstone), &ValueMeta{offset: rand.Uint32(), length: rand.Uint32()})
+	err := table.Put([]byte(tombstone), &ValueMeta{offset: rand.Uint64(), length: rand.Uint32()})
 	if err == nil {
 		t.Error("Should not allow to save a tomb")
 	}
 }
+
+func TestMemtable_DeleteRange(t *testing.T) {
+	table := NewMemTable(memTableSize)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		err := table.Put([]byte(key), &ValueMeta{length: rand.Uint32(), offset: rand.Uint64()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	table.DeleteRange([]byte("b"), []byte("d"))
+	if table.Size() != 2 {
+		t.Fatal("Only keys b and c had to be removed")
+	}
+	if _, found := table.Get([]byte("d")); !found {
+		t.Error("End of the range is exclusive")
+	}
+}
+
+func TestMemtable_SizeOfReplacedAndDeletedEntries(t *testing.T) {
+	table := NewMemTable(memTableSize)
+	for i := 0; i < 3; i++ {
+		err := table.Put([]byte("a"), &ValueMeta{length: 1, offset: uint64(i), value: []byte("value")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if table.size != entrySize([]byte("a"), &ValueMeta{value: []byte("value")}) {
+		t.Errorf("Replaced entries are still counted,size %d", table.size)
+	}
+	table.DeleteRange([]byte("a"), []byte("b"))
+	if table.size != 0 {
+		t.Errorf("Deleted entries are still counted,size %d", table.size)
+	}
+}
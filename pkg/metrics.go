@@ -0,0 +1,181 @@
+package wiskey
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Content type of the Prometheus text exposition format
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+//Write metrics in the Prometheus text exposition format
+//samples of the same metric have to be written one after another,HELP and TYPE are written before the first one
+//labels are name value pairs
+type MetricsWriter struct {
+	out  *bufio.Writer
+	last string //name of the metric of the previous sample
+	err  error
+}
+
+func NewMetricsWriter(out io.Writer) *MetricsWriter {
+	return &MetricsWriter{out: bufio.NewWriter(out)}
+}
+
+func (metrics *MetricsWriter) Counter(name string, help string, value float64, labels ...string) {
+	metrics.header(name, help, "counter")
+	metrics.sample(name, value, labels...)
+}
+
+func (metrics *MetricsWriter) Gauge(name string, help string, value float64, labels ...string) {
+	metrics.header(name, help, "gauge")
+	metrics.sample(name, value, labels...)
+}
+
+//Histogram of latencies in seconds,buckets are cumulative as Prometheus expects
+func (metrics *MetricsWriter) Histogram(name string, help string, stats OperationStats, labels ...string) {
+	metrics.header(name, help, "histogram")
+	counts := make(map[time.Duration]uint64)
+	for _, bucket := range stats.Histogram {
+		counts[bucket.UpperBound] = bucket.Count
+	}
+	cumulative := uint64(0)
+	for _, bound := range LatencyBounds {
+		cumulative += counts[bound]
+		metrics.sample(name+"_bucket", float64(cumulative), withLabel(labels, "le", formatMetric(bound.Seconds()))...)
+	}
+	metrics.sample(name+"_bucket", float64(stats.Count), withLabel(labels, "le", "+Inf")...)
+	metrics.sample(name+"_sum", stats.Duration.Seconds(), labels...)
+	metrics.sample(name+"_count", float64(stats.Count), labels...)
+}
+
+//Flush written metrics,returns the first error
+func (metrics *MetricsWriter) Flush() error {
+	if metrics.err != nil {
+		return metrics.err
+	}
+	return metrics.out.Flush()
+}
+
+func (metrics *MetricsWriter) header(name string, help string, kind string) {
+	if metrics.last == name {
+		return
+	}
+	metrics.last = name
+	metrics.write("# HELP " + name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help) + "\n")
+	metrics.write("# TYPE " + name + " " + kind + "\n")
+}
+
+func (metrics *MetricsWriter) sample(name string, value float64, labels ...string) {
+	line := name
+	if len(labels) != 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+`="`+escape.Replace(labels[i+1])+`"`)
+		}
+		line += "{" + strings.Join(pairs, ",") + "}"
+	}
+	metrics.write(line + " " + formatMetric(value) + "\n")
+}
+
+func (metrics *MetricsWriter) write(text string) {
+	if metrics.err == nil {
+		_, metrics.err = metrics.out.WriteString(text)
+	}
+}
+
+//Copy of labels with one more label,so labels of the caller are never changed
+func withLabel(labels []string, name string, value string) []string {
+	return append(append(make([]string, 0, len(labels)+2), labels...), name, value)
+}
+
+func formatMetric(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+//Write engine metrics,every metric starts with wiskey_
+func (stats Stats) WriteMetrics(metrics *MetricsWriter) {
+	operations := make([]string, 0, len(stats.Operations))
+	for name := range stats.Operations {
+		operations = append(operations, name)
+	}
+	sort.Strings(operations)
+	for _, name := range operations {
+		metrics.Counter("wiskey_operations_total", "Operations since start", float64(stats.Operations[name].Count), "op", name)
+	}
+	for _, name := range operations {
+		metrics.Histogram("wiskey_operation_duration_seconds", "Latency of operations", stats.Operations[name], "op", name)
+	}
+	jobs := []struct {
+		name  string
+		stats WorkStats
+	}{{"flush", stats.Flush}, {"compaction", stats.Compaction}, {"gc", stats.Gc}}
+	for _, job := range jobs {
+		metrics.Counter("wiskey_background_runs_total", "Memtable flushes, sstable compactions and vlog gc runs", float64(job.stats.Runs), "job", job.name)
+	}
+	for _, job := range jobs {
+		metrics.Counter("wiskey_background_read_bytes_total", "Bytes read by background jobs", float64(job.stats.ReadBytes), "job", job.name)
+	}
+	for _, job := range jobs {
+		metrics.Counter("wiskey_background_written_bytes_total", "Bytes written by background jobs", float64(job.stats.WrittenBytes), "job", job.name)
+	}
+	for _, job := range jobs {
+		metrics.Counter("wiskey_background_duration_seconds_total", "Time spent in background jobs", job.stats.Duration.Seconds(), "job", job.name)
+	}
+	metrics.Counter("wiskey_write_stalls_total", "Writes that waited for the flush of a full memtable", float64(stats.WriteStall.Runs))
+	metrics.Counter("wiskey_write_stall_seconds_total", "Time writes waited for memtable flushes", stats.WriteStall.Duration.Seconds())
+	families := make([]string, 0, len(stats.Families))
+	for name := range stats.Families {
+		families = append(families, name)
+	}
+	sort.Strings(families)
+	for _, name := range families {
+		metrics.Gauge("wiskey_memtable_entries", "Entries in the memtable", float64(stats.Families[name].Memtable.Entries), "family", name)
+	}
+	for _, name := range families {
+		metrics.Gauge("wiskey_memtable_bytes", "Size of the memtable", float64(stats.Families[name].Memtable.Bytes), "family", name)
+	}
+	for _, name := range families {
+		for _, level := range stats.Families[name].Levels {
+			metrics.Gauge("wiskey_sstables", "Sstables of the level", float64(level.Tables), "family", name, "level", strconv.Itoa(level.Level))
+		}
+	}
+	for _, name := range families {
+		for _, level := range stats.Families[name].Levels {
+			metrics.Gauge("wiskey_sstable_bytes", "Size of sstables of the level", float64(level.Bytes), "family", name, "level", strconv.Itoa(level.Level))
+		}
+	}
+	metrics.Gauge("wiskey_vlog_bytes", "Size of the vlog file", float64(stats.Vlog.Size))
+	metrics.Gauge("wiskey_vlog_head", "Offset of the first entry that isn't flushed to sstables", float64(stats.Vlog.Head))
+	metrics.Gauge("wiskey_vlog_tail", "Offset of the first entry in the vlog file", float64(stats.Vlog.Tail))
+	metrics.Gauge("wiskey_vlog_garbage_ratio", "Estimated part of the oldest vlog entries that gc removes", stats.Vlog.GarbageRatio)
+	caches := []struct {
+		name  string
+		stats CacheUsage
+	}{{"block", stats.BlockCache}, {"value", stats.ValueCache}}
+	for _, cache := range caches {
+		metrics.Counter("wiskey_cache_hits_total", "Lookups served from the cache", float64(cache.stats.Hits), "cache", cache.name)
+	}
+	for _, cache := range caches {
+		metrics.Counter("wiskey_cache_misses_total", "Lookups that missed the cache", float64(cache.stats.Misses), "cache", cache.name)
+	}
+	for _, cache := range caches {
+		metrics.Gauge("wiskey_cache_bytes", "Bytes stored in the cache", float64(cache.stats.Size), "cache", cache.name)
+	}
+	for _, cache := range caches {
+		metrics.Gauge("wiskey_cache_capacity_bytes", "Capacity of the cache", float64(cache.stats.Capacity), "cache", cache.name)
+	}
+}
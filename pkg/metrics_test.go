@@ -0,0 +1,86 @@
+package wiskey
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWriter(t *testing.T) {
+	out := bytes.NewBuffer([]byte{})
+	metrics := NewMetricsWriter(out)
+	metrics.Counter("requests_total", "Requests", 3, "path", `/a"b\`)
+	metrics.Counter("requests_total", "Requests", 1.5, "path", "/c")
+	metrics.Gauge("size", "Size\nin bytes", 10)
+	var histogram LatencyHistogram
+	histogram.Record(3 * time.Microsecond)
+	histogram.Record(time.Millisecond)
+	histogram.Record(time.Hour)
+	metrics.Histogram("latency_seconds", "Latency", histogram.Snapshot(), "op", "get")
+	if err := metrics.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"# HELP requests_total Requests\n# TYPE requests_total counter\n",
+		`requests_total{path="/a\"b\\"} 3` + "\n" + `requests_total{path="/c"} 1.5` + "\n",
+		"# HELP size Size\\nin bytes\n# TYPE size gauge\nsize 10\n",
+		"# TYPE latency_seconds histogram\n",
+		`latency_seconds_bucket{op="get",le="2e-06"} 0` + "\n" + `latency_seconds_bucket{op="get",le="4e-06"} 1` + "\n",
+		`latency_seconds_bucket{op="get",le="0.001024"} 2` + "\n",
+		`latency_seconds_bucket{op="get",le="16.777216"} 2` + "\n" + `latency_seconds_bucket{op="get",le="+Inf"} 3` + "\n",
+		`latency_seconds_sum{op="get"} 3600.001003` + "\n" + `latency_seconds_count{op="get"} 3` + "\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(out.String(), part) {
+			t.Fatalf("%q is missing in\n%s", part, out)
+		}
+	}
+	if strings.Count(out.String(), "# TYPE requests_total") != 1 {
+		t.Fatalf("samples of the same metric share the header:\n%s", out)
+	}
+}
+
+func TestStats_WriteMetrics(t *testing.T) {
+	opts := statsOptions(NewMemFS())
+	opts.MemtableSize = 50
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	//every few puts wait for the flush of the full memtable
+	for i := 0; i < 10; i++ {
+		if err := db.Put(&TableEntry{key: []byte(fmt.Sprintf("key%d", i)), value: []byte("value")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.WriteStall.Runs == 0 || stats.WriteStall.Runs != stats.Flush.Runs {
+		t.Fatalf("every flush stalled a put: %+v %+v", stats.WriteStall, stats.Flush)
+	}
+	out := bytes.NewBuffer([]byte{})
+	metrics := NewMetricsWriter(out)
+	stats.WriteMetrics(metrics)
+	if err := metrics.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`wiskey_operations_total{op="put"} 10`,
+		`wiskey_operation_duration_seconds_count{op="put"} 10`,
+		fmt.Sprintf(`wiskey_background_runs_total{job="flush"} %d`, stats.Flush.Runs),
+		fmt.Sprintf("wiskey_write_stalls_total %d", stats.WriteStall.Runs),
+		fmt.Sprintf(`wiskey_sstables{family="default",level="0"} %d`, stats.Levels[0].Tables),
+		fmt.Sprintf("wiskey_vlog_bytes %d", stats.Vlog.Size),
+		`wiskey_cache_capacity_bytes{cache="block"}`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out.String(), line+"\n") && !strings.Contains(out.String(), line+" ") {
+			t.Fatalf("%q is missing in\n%s", line, out)
+		}
+	}
+}
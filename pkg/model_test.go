@@ -0,0 +1,257 @@
+package wiskey
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//Model based test,the tree is driven by random operations and every Get is compared with a map
+//a failing sequence is shrunk and printed,so it can be added to modelRegressions
+
+type modelKind int
+
+const (
+	modelPut modelKind = iota
+	modelDelete
+	modelGet
+	modelFlush
+	modelMerge
+	modelGc
+	modelReopen
+)
+
+var modelKindNames = []string{"modelPut", "modelDelete", "modelGet", "modelFlush", "modelMerge", "modelGc", "modelReopen"}
+
+type modelOp struct {
+	kind  modelKind
+	key   string
+	value string
+}
+
+func (op modelOp) String() string {
+	return fmt.Sprintf("{%s, %q, %q}", modelKindNames[op.kind], op.key, op.value)
+}
+
+//Sequences that failed once
+var modelRegressions = [][]modelOp{
+	//the tail of the first table was dropped by merge when the second table ended first
+	{{modelPut, "k1", "a"}, {modelPut, "k2", "b"}, {modelPut, "k3", "c"}, {modelFlush, "", ""},
+		{modelPut, "k0", "d"}, {modelFlush, "", ""}, {modelMerge, "", ""}, {modelGet, "k3", ""}},
+	//a value relocated by gc has to survive merge of the table that pointed to the old copy
+	{{modelPut, "k1", strings.Repeat("x", 20)}, {modelFlush, "", ""}, {modelGc, "", ""}, {modelFlush, "", ""},
+		{modelMerge, "", ""}, {modelGet, "k1", ""}},
+}
+
+//Small memtable,some values are inline and the rest is in the vlog
+func modelOptions(fs FS) Options {
+	return Options{Dir: "/data", FS: fs, MemtableSize: 3, ValueThreshold: 8, GcEntries: 2,
+		CompactionStrategy: NoCompaction, Logger: log.New(ioutil.Discard, "", 0)}
+}
+
+func randomModelOps(rnd *rand.Rand, length int, keys int) []modelOp {
+	ops := make([]modelOp, length)
+	for i := range ops {
+		key := fmt.Sprintf("k%d", rnd.Intn(keys))
+		switch kind := rnd.Intn(100); {
+		case kind < 40:
+			ops[i] = modelOp{modelPut, key, fmt.Sprintf("v%d%s", i, strings.Repeat("x", rnd.Intn(20)))}
+		case kind < 55:
+			ops[i] = modelOp{kind: modelDelete, key: key}
+		case kind < 85:
+			ops[i] = modelOp{kind: modelGet, key: key}
+		case kind < 91:
+			ops[i] = modelOp{kind: modelFlush}
+		case kind < 95:
+			ops[i] = modelOp{kind: modelMerge}
+		case kind < 99:
+			ops[i] = modelOp{kind: modelGc}
+		default:
+			ops[i] = modelOp{kind: modelReopen}
+		}
+	}
+	return ops
+}
+
+//Compare the value of the key in the tree with the model
+func checkModelGet(tree *LsmTree, model map[string]string, key string) error {
+	value, err := tree.Get([]byte(key))
+	expected, exists := model[key]
+	if errors.Is(err, ErrNotFound) && !exists {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get %s: %w", key, err)
+	}
+	if !exists || string(value) != expected {
+		return fmt.Errorf("get %s returned %q,expected %q(exists %v)", key, value, expected, exists)
+	}
+	return nil
+}
+
+//Apply operations to a new database,returns the index of the failed operation
+func runModelOps(ops []modelOp) (int, error) {
+	fs := NewMemFS()
+	db, err := Open(modelOptions(fs))
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		db.Close()
+	}()
+	model := make(map[string]string)
+	for i, op := range ops {
+		switch op.kind {
+		case modelPut:
+			err = db.Put(&TableEntry{key: []byte(op.key), value: []byte(op.value)})
+			model[op.key] = op.value
+		case modelDelete:
+			err = db.Delete([]byte(op.key))
+			delete(model, op.key)
+		case modelGet:
+			err = checkModelGet(db.LsmTree, model, op.key)
+		case modelFlush:
+			err = db.Flush()
+		case modelMerge:
+			err = db.Merge()
+		case modelGc:
+			err = db.CompressVlog()
+		case modelReopen:
+			if err = db.Close(); err == nil {
+				db, err = Open(modelOptions(fs))
+			}
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	//every key is checked at the end
+	for key := range model {
+		if err := checkModelGet(db.LsmTree, model, key); err != nil {
+			return len(ops), err
+		}
+	}
+	return len(ops), nil
+}
+
+//Remove operations while the sequence still fails,first big chunks then single operations
+func shrinkModelOps(ops []modelOp) []modelOp {
+	for chunk := len(ops) / 2; chunk >= 1; chunk /= 2 {
+		for start := 0; start+chunk <= len(ops); {
+			candidate := append(append([]modelOp{}, ops[:start]...), ops[start+chunk:]...)
+			if _, err := runModelOps(candidate); err != nil {
+				ops = candidate
+			} else {
+				start += chunk
+			}
+		}
+	}
+	return ops
+}
+
+func failModel(t *testing.T, name string, ops []modelOp, index int, err error) {
+	t.Helper()
+	//operations after the failed one aren't needed
+	if index >= 0 && index < len(ops) {
+		ops = ops[:index+1]
+	}
+	shrunk := shrinkModelOps(ops)
+	_, shrunkErr := runModelOps(shrunk)
+	literal := make([]string, len(shrunk))
+	for i, op := range shrunk {
+		literal[i] = op.String()
+	}
+	t.Fatalf("%s: operation %d failed: %v\nshrunk to %d operations(%v),add to modelRegressions:\n{%s}",
+		name, index, err, len(shrunk), shrunkErr, strings.Join(literal, ", "))
+}
+
+func TestModel_Regressions(t *testing.T) {
+	for i, ops := range modelRegressions {
+		if index, err := runModelOps(ops); err != nil {
+			failModel(t, fmt.Sprintf("regression %d", i), ops, index, err)
+		}
+	}
+}
+
+func TestModel_Random(t *testing.T) {
+	seeds, length := 30, 300
+	if testing.Short() {
+		seeds = 5
+	}
+	for seed := int64(1); seed <= int64(seeds); seed++ {
+		ops := randomModelOps(rand.New(rand.NewSource(seed)), length, 10)
+		if index, err := runModelOps(ops); err != nil {
+			failModel(t, fmt.Sprintf("seed %d", seed), ops, index, err)
+		}
+	}
+}
+
+//Every writer owns its keys,so its own model stays exact while flushes,merges and gc of other goroutines run
+func TestModel_Concurrent(t *testing.T) {
+	seeds, writers, length := 5, 4, 200
+	if testing.Short() {
+		seeds = 2
+	}
+	for seed := int64(1); seed <= int64(seeds); seed++ {
+		db, err := Open(modelOptions(NewMemFS()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		models := make([]map[string]string, writers)
+		errs := make(chan error, writers)
+		var group sync.WaitGroup
+		for writer := 0; writer < writers; writer++ {
+			models[writer] = make(map[string]string)
+			group.Add(1)
+			go func(writer int, model map[string]string) {
+				defer group.Done()
+				rnd := rand.New(rand.NewSource(seed*100 + int64(writer)))
+				for i, op := range randomModelOps(rnd, length, 5) {
+					key := fmt.Sprintf("w%d-%s", writer, op.key)
+					var err error
+					switch op.kind {
+					case modelPut:
+						err = db.Put(&TableEntry{key: []byte(key), value: []byte(op.value)})
+						model[key] = op.value
+					case modelDelete:
+						err = db.Delete([]byte(key))
+						delete(model, key)
+					case modelGet:
+						err = checkModelGet(db.LsmTree, model, key)
+					case modelFlush:
+						err = db.Flush()
+					case modelMerge:
+						err = db.Merge()
+					case modelGc, modelReopen:
+						//other writers keep using the database,so it isn't reopened
+						err = db.CompressVlog()
+					}
+					if err != nil {
+						errs <- fmt.Errorf("seed %d,writer %d,operation %d %v: %w", seed, writer, i, op, err)
+						return
+					}
+				}
+			}(writer, models[writer])
+		}
+		group.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatal(err)
+		}
+		for _, model := range models {
+			for key := range model {
+				if err := checkModelGet(db.LsmTree, model, key); err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+			}
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
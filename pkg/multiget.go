@@ -0,0 +1,130 @@
+package wiskey
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Get values of many keys at once
+//keys are sorted so every sstable is opened once per batch and probed in key order,
+//sstables are probed in parallel and values that are next to each other in the vlog are read together
+//Returns values and found flags in the same order as given keys
+func (lsm *LsmTree) MultiGet(keys [][]byte) ([][]byte, []bool, error) {
+	defer lsm.root.stats.operation(opMultiGet, time.Now())
+	lsm.rwm.RLock()
+	defer lsm.rwm.RUnlock()
+	if lsm.root.closed {
+		return nil, nil, ErrClosed
+	}
+	values := make([][]byte, len(keys))
+	found := make([]bool, len(keys))
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return bytes.Compare(keys[order[a]], keys[order[b]]) < 0
+	})
+	//vlog entries that have to be read
+	metas := make([]*ValueMeta, len(keys))
+	//positions of keys that are not in memtable,they are sorted by key
+	var missing []int
+	for _, i := range order {
+		key := keys[i]
+		if _, ok := lsm.deleted[string(key)]; ok {
+			continue
+		}
+		meta, ok := lsm.memtable.Get(key)
+		if !ok {
+			missing = append(missing, i)
+		} else if meta.inline {
+			values[i], found[i] = meta.value, true
+		} else {
+			metas[i] = meta
+		}
+	}
+	entries, err := lsm.findManyInSStables(keys, missing)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, i := range missing {
+		entry := entries[i]
+		if entry == nil || lsm.isRangeDeleted(keys[i], entry.timeStamp) {
+			continue
+		}
+		if entry.inline {
+			values[i], found[i] = entry.value, true
+		} else {
+			metas[i] = &ValueMeta{offset: entry.valueOffset, length: entry.valueLength}
+		}
+	}
+	vlogEntries, err := lsm.log.GetMany(metas)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, entry := range vlogEntries {
+		if entry != nil {
+			values[i], found[i] = entry.value, true
+		}
+	}
+	//deleted keys
+	for i, value := range values {
+		if found[i] && bytes.Equal(value, []byte(tombstone)) {
+			values[i], found[i] = nil, false
+		}
+	}
+	return values, found, nil
+}
+
+//Find the latest sstable entry for keys at given positions,every sstable is searched in its own goroutine
+//Returns entries in the same order as keys,nil if key wasn't found
+func (lsm *LsmTree) findManyInSStables(keys [][]byte, positions []int) ([]*sstableEntry, error) {
+	latest := make([]*sstableEntry, len(keys))
+	if len(positions) == 0 {
+		return latest, nil
+	}
+	tables := lsm.sstables
+	results := make([][]*sstableEntry, len(tables))
+	errs := make([]error, len(tables))
+	var wg sync.WaitGroup
+	for t, tablePath := range tables {
+		wg.Add(1)
+		go func(t int, tablePath string) {
+			defer wg.Done()
+			sstable, err := lsm.openTable(tablePath)
+			if err != nil {
+				errs[t] = err
+				return
+			}
+			defer sstable.Close()
+			tableEntries := make([]*sstableEntry, len(keys))
+			for _, i := range positions {
+				entry, found, err := sstable.lookup(keys[i])
+				if err != nil {
+					errs[t] = err
+					return
+				}
+				if found {
+					tableEntries[i] = entry
+				}
+			}
+			results[t] = tableEntries
+		}(t, tablePath)
+	}
+	wg.Wait()
+	for t, tableEntries := range results {
+		if errs[t] != nil {
+			return nil, errs[t]
+		}
+		//multiple sstables can have the same key,choose the one with the latest timestamp
+		for _, i := range positions {
+			entry := tableEntries[i]
+			if entry != nil && (latest[i] == nil || entry.timeStamp > latest[i].timeStamp) {
+				latest[i] = entry
+			}
+		}
+	}
+	return latest, nil
+}
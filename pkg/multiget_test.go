@@ -0,0 +1,53 @@
+package wiskey
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLsmTree_MultiGet(t *testing.T) {
+	tree := InitTestLsmWithMeta(t, 100, 30)
+	entries := FakeEntries()
+	for index, entry := range entries {
+		err := tree.Put(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//first half goes to sstable, second half stays in memtable
+		if index == len(entries)/2 {
+			err := tree.Flush()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err := tree.Delete(entries[0].key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := [][]byte{[]byte("NON EXISTING KEY")}
+	for _, entry := range entries {
+		keys = append(keys, entry.key)
+	}
+	//the same key twice
+	keys = append(keys, entries[1].key)
+	values, found, err := tree.MultiGet(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != len(keys) || len(found) != len(keys) {
+		t.Fatal("MultiGet has to return a result for every key")
+	}
+	for i, key := range keys {
+		value, err := tree.Get(key)
+		if found[i] != (err == nil) {
+			t.Fatalf("MultiGet and Get don't agree if key %s exists", key)
+		}
+		if bytes.Compare(values[i], value) != 0 {
+			t.Fatalf("MultiGet and Get return different values for key %s", key)
+		}
+	}
+	if found[1] {
+		t.Fatal("Deleted key was found")
+	}
+}
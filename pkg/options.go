@@ -0,0 +1,147 @@
+package wiskey
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+//How the vlog is synced to the disk
+type SyncMode string
+
+const (
+	SyncNone   SyncMode = "none"   //writes stay in the page cache until the os flushes them
+	SyncAlways SyncMode = "always" //the vlog is fsynced after every write,a write that returned survives a power loss
+)
+
+//How sstables are merged in background
+type CompactionStrategy string
+
+const (
+	PairwiseCompaction CompactionStrategy = "pairwise" //merge neighbour sstables,so their amount is decreased by x2
+	NoCompaction       CompactionStrategy = "none"     //sstables are merged only by explicit Merge call
+)
+
+//Options of the database,zero values are replaced by defaults,see DefaultOptions
+type Options struct {
+	Dir                string             //data directory with sst/,vlog/,MANIFEST,CURRENT and LOCK
+	SStableDir         string             //directory of sstables,overrides the one in Dir
+	VlogPath           string             //vlog file,overrides the one in Dir
+	CheckpointPath     string             //file with the vlog head,overrides the one in Dir
+	MemtableSize       int                //size of keys and vlog pointers in the memtable in bytes before it's flushed
+	ValueThreshold     int                //values smaller than this are stored inline in sstables,0 disables it
+	BlockSize          uint32             //size of sstable blocks in bytes
+	BlockCacheSize     int                //size of sstable block cache in bytes,0 disables it
+	ValueCacheSize     int                //size of vlog value cache in bytes,0 disables it
+	Compression        string             //compression of sstable blocks and vlog values,see CompressorByName
+	SStableNameLength  int                //length of random sstable file names
+	CompactionInterval time.Duration      //how often sstables are merged
+	CompactionStrategy CompactionStrategy //how sstables are merged
+	GcEntries          int                //how many vlog entries a single gc run reads
+	GcInterval         time.Duration      //how often the vlog is collected in background,0 collects it only by CompressVlog
+	SyncMode           SyncMode           //when the vlog is fsynced
+	ReadOnly           bool               //nothing on disk is created or changed,writes return ErrReadOnly
+	Comparator         string             //order of keys,only bytewise is supported
+	Logger             *log.Logger        //where background workers report,stderr by default
+	FS                 FS                 //file system of all files,the os one by default
+}
+
+//Options with every setting set to its default,paths are empty
+func DefaultOptions() Options {
+	return Options{
+		MemtableSize:       4 << 20,
+		BlockSize:          defaultBlockSize,
+		Compression:        "none",
+		SStableNameLength:  10,
+		CompactionInterval: 120 * time.Second,
+		CompactionStrategy: PairwiseCompaction,
+		GcEntries:          2,
+		SyncMode:           SyncNone,
+		Comparator:         bytewiseComparator,
+		Logger:             log.New(os.Stderr, "wiskey ", log.LstdFlags),
+		FS:                 NewOsFS(),
+	}
+}
+
+//Replace zero values by defaults,sizes where zero disables the feature are kept
+func (opts Options) withDefaults() Options {
+	defaults := DefaultOptions()
+	if opts.MemtableSize == 0 {
+		opts.MemtableSize = defaults.MemtableSize
+	}
+	if opts.BlockSize == 0 {
+		opts.BlockSize = defaults.BlockSize
+	}
+	if opts.Compression == "" {
+		opts.Compression = defaults.Compression
+	}
+	if opts.SStableNameLength == 0 {
+		opts.SStableNameLength = defaults.SStableNameLength
+	}
+	if opts.CompactionInterval == 0 {
+		opts.CompactionInterval = defaults.CompactionInterval
+	}
+	if opts.CompactionStrategy == "" {
+		opts.CompactionStrategy = defaults.CompactionStrategy
+	}
+	if opts.GcEntries == 0 {
+		opts.GcEntries = defaults.GcEntries
+	}
+	if opts.SyncMode == "" {
+		opts.SyncMode = defaults.SyncMode
+	}
+	if opts.Comparator == "" {
+		opts.Comparator = defaults.Comparator
+	}
+	if opts.Logger == nil {
+		opts.Logger = defaults.Logger
+	}
+	if opts.FS == nil {
+		opts.FS = defaults.FS
+	}
+	return opts
+}
+
+//Check that options can be used to open the database
+func (opts Options) Validate() error {
+	if opts.Dir == "" && (opts.SStableDir == "" || opts.VlogPath == "" || opts.CheckpointPath == "") {
+		return errors.New("data dir or sstable dir,vlog and checkpoint have to be set")
+	}
+	if opts.MemtableSize <= 0 {
+		return fmt.Errorf("memtable size has to be positive,got %d", opts.MemtableSize)
+	}
+	if opts.ValueThreshold < 0 || opts.BlockCacheSize < 0 || opts.ValueCacheSize < 0 {
+		return errors.New("value threshold and cache sizes can't be negative")
+	}
+	//a block has to fit at least a restart point and a small entry
+	if opts.BlockSize < 64 {
+		return fmt.Errorf("block size has to be at least 64 bytes,got %d", opts.BlockSize)
+	}
+	if _, err := CompressorByName(opts.Compression); err != nil {
+		return err
+	}
+	if opts.SStableNameLength < 8 {
+		return fmt.Errorf("sstable name length has to be at least 8,got %d", opts.SStableNameLength)
+	}
+	if opts.CompactionInterval <= 0 || opts.GcInterval < 0 {
+		return errors.New("compaction and gc intervals can't be negative")
+	}
+	if opts.CompactionStrategy != PairwiseCompaction && opts.CompactionStrategy != NoCompaction {
+		return fmt.Errorf("unknown compaction strategy %q", opts.CompactionStrategy)
+	}
+	if opts.GcEntries <= 0 {
+		return fmt.Errorf("gc entries have to be positive,got %d", opts.GcEntries)
+	}
+	if opts.SyncMode != SyncNone && opts.SyncMode != SyncAlways {
+		return fmt.Errorf("unknown sync mode %q", opts.SyncMode)
+	}
+	if opts.Comparator != bytewiseComparator {
+		return fmt.Errorf("unsupported comparator %q", opts.Comparator)
+	}
+	if opts.Logger == nil || opts.FS == nil {
+		return errors.New("logger and file system have to be set")
+	}
+	return nil
+}
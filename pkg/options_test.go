@@ -0,0 +1,81 @@
+package wiskey
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	valid := Options{SStableDir: "sst", VlogPath: "vlog", CheckpointPath: "checkpoint"}.withDefaults()
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if valid.BlockSize != defaultBlockSize || valid.GcEntries != 2 || valid.CompactionInterval != 120*time.Second ||
+		valid.SyncMode != SyncNone || valid.CompactionStrategy != PairwiseCompaction || valid.Logger == nil {
+		t.Fatalf("Defaults weren't applied %+v", valid)
+	}
+	invalid := map[string]func(opts *Options){
+		"no vlog":            func(opts *Options) { opts.VlogPath = "" },
+		"negative memtable":  func(opts *Options) { opts.MemtableSize = -1 },
+		"tiny block":         func(opts *Options) { opts.BlockSize = 8 },
+		"negative cache":     func(opts *Options) { opts.ValueCacheSize = -1 },
+		"unknown compressor": func(opts *Options) { opts.Compression = "brotli" },
+		"short sstable name": func(opts *Options) { opts.SStableNameLength = 2 },
+		"negative gc":        func(opts *Options) { opts.GcInterval = -time.Second },
+		"unknown strategy":   func(opts *Options) { opts.CompactionStrategy = "leveled" },
+		"unknown sync mode":  func(opts *Options) { opts.SyncMode = "sometimes" },
+		"unknown comparator": func(opts *Options) { opts.Comparator = "reverse" },
+	}
+	for name, change := range invalid {
+		opts := valid
+		change(&opts)
+		if err := opts.Validate(); err == nil {
+			t.Fatalf("Options with %s have to be rejected", name)
+		}
+	}
+}
+
+func TestDB_BackgroundGc(t *testing.T) {
+	opts := testOptions(t)
+	defer os.RemoveAll(filepath.Dir(opts.SStableDir))
+	opts.GcInterval = 10 * time.Millisecond
+	opts.GcEntries = 10
+	opts.CompactionStrategy = NoCompaction
+	opts.SyncMode = SyncAlways
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, value := range []string{"JUNIOR", "DEVELOPER"} {
+		err = db.Put(&TableEntry{key: []byte("ANITA"), value: []byte(value)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//wait for the worker to collect the stale value
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		db.rwm.RLock()
+		tail := db.log.tail
+		db.rwm.RUnlock()
+		if tail > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Vlog wasn't collected in background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	value, err := db.Get([]byte("ANITA"))
+	if err != nil || !bytes.Equal(value, []byte("DEVELOPER")) {
+		t.Fatalf("Live value was lost by gc, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package wiskey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const bytewiseComparator = "bytewise" //keys are compared with bytes.Compare
+
+//Statistics of the sstable,they are saved in the properties block
+//sequence of the entry is its timestamp
+type TableProperties struct {
+	Entries     uint64
+	MinKey      []byte
+	MaxKey      []byte
+	MinSequence uint64
+	MaxSequence uint64
+	CreatedAt   time.Time
+	Comparator  string
+}
+
+//Update properties with the entry,entries are added in key order
+func (properties *TableProperties) add(entry *sstableEntry) {
+	if properties.Entries == 0 {
+		properties.MinKey = append([]byte{}, entry.key...)
+		properties.MinSequence = entry.timeStamp
+	}
+	properties.MaxKey = append(properties.MaxKey[:0], entry.key...)
+	if entry.timeStamp < properties.MinSequence {
+		properties.MinSequence = entry.timeStamp
+	}
+	if entry.timeStamp > properties.MaxSequence {
+		properties.MaxSequence = entry.timeStamp
+	}
+	properties.Entries++
+}
+
+//Write properties
+//+---------+--------------+--------------+------------+---------+---------+------------+
+//| Entries | Min sequence | Max sequence | Created at | Min key | Max key | Comparator |
+//+---------+--------------+--------------+------------+---------+---------+------------+
+//keys and comparator are prefixed with their uint32 length
+func (properties *TableProperties) asByteArray() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	binary.Write(buffer, binary.BigEndian, properties.Entries)
+	binary.Write(buffer, binary.BigEndian, properties.MinSequence)
+	binary.Write(buffer, binary.BigEndian, properties.MaxSequence)
+	binary.Write(buffer, binary.BigEndian, properties.CreatedAt.UnixNano())
+	for _, field := range [][]byte{properties.MinKey, properties.MaxKey, []byte(properties.Comparator)} {
+		binary.Write(buffer, binary.BigEndian, uint32(len(field)))
+		buffer.Write(field)
+	}
+	return buffer.Bytes()
+}
+
+func readProperties(buffer []byte) (*TableProperties, error) {
+	if len(buffer) < int64Size*4 {
+		return nil, fmt.Errorf("%w: properties block is too short", ErrCorruptedTable)
+	}
+	properties := &TableProperties{
+		Entries:     binary.BigEndian.Uint64(buffer[0:]),
+		MinSequence: binary.BigEndian.Uint64(buffer[int64Size:]),
+		MaxSequence: binary.BigEndian.Uint64(buffer[int64Size*2:]),
+		CreatedAt:   time.Unix(0, int64(binary.BigEndian.Uint64(buffer[int64Size*3:]))),
+	}
+	buffer = buffer[int64Size*4:]
+	fields := make([][]byte, 3)
+	for i := range fields {
+		if len(buffer) < uint32Size {
+			return nil, fmt.Errorf("%w: properties block is too short", ErrCorruptedTable)
+		}
+		length := binary.BigEndian.Uint32(buffer)
+		buffer = buffer[uint32Size:]
+		if uint64(length) > uint64(len(buffer)) {
+			return nil, fmt.Errorf("%w: properties block is too short", ErrCorruptedTable)
+		}
+		fields[i] = buffer[:length]
+		buffer = buffer[length:]
+	}
+	properties.MinKey, properties.MaxKey, properties.Comparator = fields[0], fields[1], string(fields[2])
+	if properties.Comparator != bytewiseComparator {
+		return nil, fmt.Errorf("%w: comparator %q", ErrUnsupportedFormat, properties.Comparator)
+	}
+	return properties, nil
+}
@@ -0,0 +1,88 @@
+package wiskey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+)
+
+const (
+	rangeTombstonesFile = "range.tombstones" //file in sstable directory with all active range tombstones
+)
+
+//all keys in [start,end) that were written before timestamp are deleted
+type rangeTombstone struct {
+	start     []byte
+	end       []byte
+	timestamp uint64
+}
+
+//Check if the entry with given key and timestamp is deleted by this tombstone
+func (tombstone *rangeTombstone) covers(key []byte, timestamp uint64) bool {
+	return timestamp < tombstone.timestamp &&
+		bytes.Compare(key, tombstone.start) >= 0 &&
+		bytes.Compare(key, tombstone.end) < 0
+}
+
+//Write tombstone to the file
+//+--------------+------------+-----------+-------+-----+
+//| Start length | End length | timestamp | Start | End |
+//+--------------+------------+-----------+-------+-----+
+func (tombstone *rangeTombstone) writeTo(buffer *bytes.Buffer) error {
+	if err := binary.Write(buffer, binary.BigEndian, uint32(len(tombstone.start))); err != nil {
+		return err
+	}
+	if err := binary.Write(buffer, binary.BigEndian, uint32(len(tombstone.end))); err != nil {
+		return err
+	}
+	if err := binary.Write(buffer, binary.BigEndian, tombstone.timestamp); err != nil {
+		return err
+	}
+	buffer.Write(tombstone.start)
+	buffer.Write(tombstone.end)
+	return nil
+}
+
+//Replace the content of the file with given tombstones
+func writeRangeTombstones(fs FS, path string, tombstones []*rangeTombstone) error {
+	buffer := bytes.NewBuffer([]byte{})
+	for _, tombstone := range tombstones {
+		if err := tombstone.writeTo(buffer); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomically(fs, path, buffer.Bytes())
+}
+
+//Read all tombstones from the file, missing file means there are no tombstones
+func readRangeTombstones(fs FS, path string) ([]*rangeTombstone, error) {
+	buffer, err := readFile(fs, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tombstones []*rangeTombstone
+	position := 0
+	for position != len(buffer) {
+		if len(buffer)-position < uint32Size*2+int64Size {
+			return nil, errors.New("range tombstones file is corrupted")
+		}
+		startLength := int(binary.BigEndian.Uint32(buffer[position : position+uint32Size]))
+		endLength := int(binary.BigEndian.Uint32(buffer[position+uint32Size : position+uint32Size*2]))
+		timestamp := binary.BigEndian.Uint64(buffer[position+uint32Size*2 : position+uint32Size*2+int64Size])
+		position += uint32Size*2 + int64Size
+		if len(buffer)-position < startLength+endLength {
+			return nil, errors.New("range tombstones file is corrupted")
+		}
+		tombstones = append(tombstones, &rangeTombstone{
+			start:     buffer[position : position+startLength],
+			end:       buffer[position+startLength : position+startLength+endLength],
+			timestamp: timestamp,
+		})
+		position += startLength + endLength
+	}
+	return tombstones, nil
+}
@@ -1,8 +1,8 @@
 package wiskey
 
 import (
+	"bytes"
 	"encoding/binary"
-	"os"
 )
 
 const (
@@ -10,56 +10,154 @@ const (
 	int64Size  = 8
 )
 
+//Reader of a single sstable block
+//entries are read one by one with next,seek moves the reader to the given key using restart points
 type SSTableReader struct {
-	reader *os.File
-	offset uint32
+	data     []byte   //entries of the block without restart points
+	restarts []uint32 //offsets of restart points
+	offset   uint32   //offset of the next entry
+	key      []byte   //key of the last read entry,the next entry shares its prefix
 }
 
-//Create a new sstable reader
-//It sets the reader to the given offset
-//All methods have to be called in the following order
-//1. readKeyLength
-//2. readKey
-//3. read timestamp
-//4. read value offset
-//5. read value length
-func NewReader(reader *os.File, offset int64) *SSTableReader {
-	reader.Seek(offset, 0)
-	return &SSTableReader{reader: reader}
+//Create a reader over a single block that was already read from the sstable
+func newBlockReader(block []byte) (*SSTableReader, error) {
+	if len(block) < uint32Size {
+		return nil, errCorruptedBlock
+	}
+	end := len(block) - uint32Size
+	count := binary.BigEndian.Uint32(block[end:])
+	if count == 0 || uint64(count)*uint32Size > uint64(end) {
+		return nil, errCorruptedBlock
+	}
+	entriesEnd := end - int(count)*uint32Size
+	restarts := make([]uint32, count)
+	for i := range restarts {
+		restarts[i] = binary.BigEndian.Uint32(block[entriesEnd+i*uint32Size:])
+		if restarts[i] > uint32(entriesEnd) {
+			return nil, errCorruptedBlock
+		}
+	}
+	return &SSTableReader{data: block[:entriesEnd], restarts: restarts}, nil
 }
 
-func (tableReader *SSTableReader) readKeyLength() uint32 {
-	tableReader.offset += uint32Size
-	keyLengthBuffer := make([]byte, uint32Size)
-	//read key length
-	tableReader.reader.Read(keyLengthBuffer)
-	return binary.BigEndian.Uint32(keyLengthBuffer)
+//Check if there are more entries in the block
+func (tableReader *SSTableReader) hasNext() bool {
+	return int(tableReader.offset) < len(tableReader.data)
 }
 
-func (tableReader *SSTableReader) readKey(keyLength uint32) []byte {
-	tableReader.offset += keyLength
-	keyBuffer := make([]byte, keyLength)
-	tableReader.reader.Read(keyBuffer)
-	return keyBuffer
+//Read the next entry
+func (tableReader *SSTableReader) next() (*sstableEntry, error) {
+	shared, err := tableReader.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	unshared, err := tableReader.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	inline := unshared&1 != 0
+	unshared >>= 1
+	if shared > uint64(len(tableReader.key)) {
+		return nil, errCorruptedBlock
+	}
+	suffix, err := tableReader.readBytes(unshared)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 0, int(shared)+len(suffix))
+	key = append(append(key, tableReader.key[:shared]...), suffix...)
+	timestamp, err := tableReader.readBytes(int64Size)
+	if err != nil {
+		return nil, err
+	}
+	entry := &sstableEntry{key: key, timeStamp: binary.BigEndian.Uint64(timestamp), inline: inline}
+	if inline {
+		valueLength, err := tableReader.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		value, err := tableReader.readBytes(valueLength)
+		if err != nil {
+			return nil, err
+		}
+		entry.value = append([]byte{}, value...)
+	} else {
+		meta, err := tableReader.readBytes(int64Size + uint32Size)
+		if err != nil {
+			return nil, err
+		}
+		entry.valueOffset = binary.BigEndian.Uint64(meta[:int64Size])
+		entry.valueLength = binary.BigEndian.Uint32(meta[int64Size:])
+	}
+	tableReader.key = key
+	return entry, nil
 }
 
-func (tableReader *SSTableReader) readTimestamp() uint64 {
-	tableReader.offset += int64Size
-	timestamp := make([]byte, int64Size)
-	tableReader.reader.Read(timestamp)
-	return binary.BigEndian.Uint64(timestamp)
+//Move the reader to the last restart point with a key smaller than given key,
+//so the next entries are read starting from it
+func (tableReader *SSTableReader) seek(key []byte) error {
+	left := 0
+	right := len(tableReader.restarts) - 1
+	for left < right {
+		middle := (right-left+1)/2 + left
+		tableReader.reset(tableReader.restarts[middle])
+		entry, err := tableReader.next()
+		if err != nil {
+			return err
+		}
+		if bytes.Compare(entry.key, key) < 0 {
+			left = middle
+		} else {
+			right = middle - 1
+		}
+	}
+	tableReader.reset(tableReader.restarts[left])
+	return nil
 }
-func (tableReader *SSTableReader) readValueOffset() uint32 {
-	tableReader.offset += uint32Size
-	valueOffset := make([]byte, uint32Size)
-	//read key length
-	tableReader.reader.Read(valueOffset)
-	return binary.BigEndian.Uint32(valueOffset)
+
+//Find the entry with given key in the block
+func (tableReader *SSTableReader) find(key []byte) (*sstableEntry, bool, error) {
+	err := tableReader.seek(key)
+	if err != nil {
+		return nil, false, err
+	}
+	for tableReader.hasNext() {
+		entry, err := tableReader.next()
+		if err != nil {
+			return nil, false, err
+		}
+		compare := bytes.Compare(entry.key, key)
+		if compare == 0 {
+			return entry, true, nil
+		}
+		//entries are sorted,the key isn't in the block
+		if compare > 0 {
+			break
+		}
+	}
+	return nil, false, nil
+}
+
+func (tableReader *SSTableReader) reset(offset uint32) {
+	tableReader.offset = offset
+	tableReader.key = nil
 }
-func (tableReader *SSTableReader) readValueLength() uint32 {
-	tableReader.offset += uint32Size
-	valueLength := make([]byte, uint32Size)
-	//read key length
-	tableReader.reader.Read(valueLength)
-	return binary.BigEndian.Uint32(valueLength)
+
+func (tableReader *SSTableReader) readUvarint() (uint64, error) {
+	value, length := binary.Uvarint(tableReader.data[tableReader.offset:])
+	if length <= 0 {
+		return 0, errCorruptedBlock
+	}
+	tableReader.offset += uint32(length)
+	return value, nil
+}
+
+func (tableReader *SSTableReader) readBytes(length uint64) ([]byte, error) {
+	if length > uint64(len(tableReader.data))-uint64(tableReader.offset) {
+		return nil, errCorruptedBlock
+	}
+	end := tableReader.offset + uint32(length)
+	buffer := tableReader.data[tableReader.offset:end]
+	tableReader.offset = end
+	return buffer, nil
 }
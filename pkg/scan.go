@@ -0,0 +1,308 @@
+package wiskey
+
+import (
+	"bytes"
+	"sort"
+	"time"
+)
+
+//Range of keys to scan,bounds and the prefix are combined
+type ScanOptions struct {
+	Start   []byte //the smallest key,inclusive
+	End     []byte //keys are smaller than it,nil scans till the last key
+	Prefix  []byte //only keys with this prefix
+	Limit   int    //max amount of entries,0 returns all of them
+	Reverse bool   //from the biggest key to the smallest one
+}
+
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+//Page of the scan
+type ScanResult struct {
+	Entries []KeyValue
+	Next    *ScanOptions //continues the scan after the last entry,nil if there are no keys anymore
+}
+
+//Smallest and biggest keys of the scan,the upper bound is exclusive and nil if there is no bound
+func (options ScanOptions) bounds() ([]byte, []byte) {
+	lower, upper := options.Start, options.End
+	if len(options.Prefix) != 0 {
+		if bytes.Compare(options.Prefix, lower) > 0 {
+			lower = options.Prefix
+		}
+		if end := prefixEnd(options.Prefix); end != nil && (upper == nil || bytes.Compare(end, upper) < 0) {
+			upper = end
+		}
+	}
+	return lower, upper
+}
+
+//The smallest key that is bigger than all keys with the prefix,nil if the prefix has only 0xff bytes
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] != 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+//Ordered page of live keys and values from the memtable and sstables
+//the tree is locked only for the page,so a long scan made of pages doesn't see a single snapshot
+func (lsm *LsmTree) Scan(options ScanOptions) (*ScanResult, error) {
+	defer lsm.root.stats.operation(opScan, time.Now())
+	lsm.rwm.RLock()
+	defer lsm.rwm.RUnlock()
+	if lsm.root.closed {
+		return nil, ErrClosed
+	}
+	iterator, err := lsm.newScanIterator(options)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.close()
+	result := &ScanResult{Entries: []KeyValue{}}
+	for {
+		entry, err := iterator.next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return result, nil
+		}
+		if options.Limit != 0 && len(result.Entries) == options.Limit {
+			//there is at least one more key
+			next := options
+			last := result.Entries[len(result.Entries)-1].Key
+			if options.Reverse {
+				next.End = last
+			} else {
+				next.Start = append(append([]byte{}, last...), 0)
+			}
+			result.Next = &next
+			return result, nil
+		}
+		result.Entries = append(result.Entries, *entry)
+	}
+}
+
+//Merge of the memtable and all sstables in the order of the scan
+type scanIterator struct {
+	lsm     *LsmTree
+	sources []scanSource //the memtable goes first,it has the latest version of its keys
+	tables  []*SSTable
+	reverse bool
+}
+
+//Entries of a single source in the order of the scan
+type scanSource interface {
+	//Returns the current entry or nil at the end
+	current() (*sstableEntry, error)
+	advance()
+}
+
+func (lsm *LsmTree) newScanIterator(options ScanOptions) (*scanIterator, error) {
+	lower, upper := options.bounds()
+	iterator := &scanIterator{lsm: lsm, reverse: options.Reverse}
+	if upper != nil && bytes.Compare(lower, upper) >= 0 {
+		return iterator, nil
+	}
+	iterator.sources = append(iterator.sources, lsm.memtable.scan(lower, upper, options.Reverse))
+	for _, tablePath := range lsm.sstables {
+		table, err := lsm.openTable(tablePath)
+		if err != nil {
+			iterator.close()
+			return nil, err
+		}
+		iterator.tables = append(iterator.tables, table)
+		iterator.sources = append(iterator.sources, newTableScan(table, lower, upper, options.Reverse))
+	}
+	return iterator, nil
+}
+
+//Returns the next live key or nil at the end
+func (iterator *scanIterator) next() (*KeyValue, error) {
+	for {
+		var key []byte
+		found := false
+		for _, source := range iterator.sources {
+			entry, err := source.current()
+			if err != nil {
+				return nil, err
+			}
+			if entry != nil && (!found || iterator.before(entry.key, key)) {
+				key, found = entry.key, true
+			}
+		}
+		if !found {
+			return nil, nil
+		}
+		//the memtable wins,otherwise the latest sstable entry
+		var latest *sstableEntry
+		inMemtable := false
+		for i, source := range iterator.sources {
+			entry, _ := source.current()
+			if entry == nil || !bytes.Equal(entry.key, key) {
+				continue
+			}
+			if latest == nil || (!inMemtable && entry.timeStamp > latest.timeStamp) {
+				latest, inMemtable = entry, i == 0
+			}
+			source.advance()
+		}
+		value, live, err := iterator.lsm.scanValue(latest, inMemtable)
+		if err != nil {
+			return nil, err
+		}
+		if live {
+			return &KeyValue{Key: key, Value: value}, nil
+		}
+	}
+}
+
+func (iterator *scanIterator) before(first []byte, second []byte) bool {
+	if iterator.reverse {
+		return bytes.Compare(first, second) > 0
+	}
+	return bytes.Compare(first, second) < 0
+}
+
+func (iterator *scanIterator) close() {
+	for _, table := range iterator.tables {
+		table.Close()
+	}
+}
+
+//Read the value of the latest version of the key,deleted keys aren't live
+func (lsm *LsmTree) scanValue(entry *sstableEntry, inMemtable bool) ([]byte, bool, error) {
+	if inMemtable && lsm.deleted[string(entry.key)] {
+		return nil, false, nil
+	}
+	if !inMemtable && lsm.isRangeDeleted(entry.key, entry.timeStamp) {
+		return nil, false, nil
+	}
+	searchEntry, err := lsm.log.fetch(entry)
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.Equal(searchEntry.value, []byte(tombstone)) {
+		return nil, false, nil
+	}
+	return searchEntry.value, true, nil
+}
+
+//Entries that are already in memory
+type sliceScan struct {
+	entries []*sstableEntry
+}
+
+func (scan *sliceScan) current() (*sstableEntry, error) {
+	if len(scan.entries) == 0 {
+		return nil, nil
+	}
+	return scan.entries[0], nil
+}
+
+func (scan *sliceScan) advance() {
+	scan.entries = scan.entries[1:]
+}
+
+//Copy entries of the memtable in the range,the memtable can change after the lock is released
+func (memtable *Memtable) scan(lower []byte, upper []byte, reverse bool) *sliceScan {
+	scan := &sliceScan{}
+	iterator := memtable.tree.Iterator()
+	for iterator.Next() {
+		key := []byte(iterator.Key().(string))
+		if bytes.Compare(key, lower) < 0 {
+			continue
+		}
+		if upper != nil && bytes.Compare(key, upper) >= 0 {
+			break
+		}
+		scan.entries = append(scan.entries, NewSStableEntry(key, iterator.Value().(*ValueMeta)))
+	}
+	if reverse {
+		reverseEntries(scan.entries)
+	}
+	return scan
+}
+
+func reverseEntries(entries []*sstableEntry) {
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+}
+
+//Entries of the sstable in the range,blocks are read one by one starting from the one with the first key of the scan
+type tableScan struct {
+	sliceScan
+	table   *SSTable
+	lower   []byte
+	upper   []byte
+	reverse bool
+	block   int //index of the next block,-1 or len(indexes) when all blocks were read
+}
+
+func newTableScan(table *SSTable, lower []byte, upper []byte, reverse bool) *tableScan {
+	scan := &tableScan{table: table, lower: lower, upper: upper, reverse: reverse}
+	//the first block whose last key is in the range
+	scan.block = sort.Search(len(table.indexes), func(i int) bool {
+		return bytes.Compare(table.indexes[i].LastKey, lower) >= 0
+	})
+	if reverse {
+		//the block with the upper bound,keys of the next blocks are out of the range
+		scan.block = len(table.indexes) - 1
+		if upper != nil {
+			if block := sort.Search(len(table.indexes), func(i int) bool {
+				return bytes.Compare(table.indexes[i].LastKey, upper) >= 0
+			}); block < len(table.indexes) {
+				scan.block = block
+			}
+		}
+	}
+	return scan
+}
+
+func (scan *tableScan) current() (*sstableEntry, error) {
+	for len(scan.entries) == 0 && scan.block >= 0 && scan.block < len(scan.table.indexes) {
+		index := scan.table.indexes[scan.block]
+		//blocks are read directly,scans don't evict hot blocks from the cache
+		block, err := scan.table.readBlock(index)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := newBlockReader(block)
+		if err != nil {
+			return nil, err
+		}
+		for reader.hasNext() {
+			entry, err := reader.next()
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Compare(entry.key, scan.lower) >= 0 && (scan.upper == nil || bytes.Compare(entry.key, scan.upper) < 0) {
+				scan.entries = append(scan.entries, entry)
+			}
+		}
+		if scan.reverse {
+			reverseEntries(scan.entries)
+			scan.block--
+			//keys of previous blocks are smaller than the lower bound
+			if scan.block >= 0 && bytes.Compare(scan.table.indexes[scan.block].LastKey, scan.lower) < 0 {
+				scan.block = -1
+			}
+		} else {
+			scan.block++
+			//keys of next blocks are bigger than the upper bound
+			if scan.upper != nil && bytes.Compare(index.LastKey, scan.upper) >= 0 {
+				scan.block = len(scan.table.indexes)
+			}
+		}
+	}
+	return scan.sliceScan.current()
+}
@@ -0,0 +1,180 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+//Small blocks,so a table has many of them,and some inline values
+func scanOptions(fs FS) Options {
+	return Options{Dir: "/data", FS: fs, MemtableSize: 200, BlockSize: 64, ValueThreshold: 6,
+		CompactionStrategy: NoCompaction, Logger: log.New(ioutil.Discard, "", 0)}
+}
+
+//Keys of the model in the range in the order of the scan
+func expectedScan(model map[string]string, options ScanOptions) []KeyValue {
+	lower, upper := options.bounds()
+	var keys []string
+	for key := range model {
+		if key >= string(lower) && (upper == nil || key < string(upper)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if options.Reverse {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	}
+	entries := []KeyValue{}
+	for _, key := range keys {
+		entries = append(entries, KeyValue{Key: []byte(key), Value: []byte(model[key])})
+	}
+	return entries
+}
+
+//Read all pages of the scan
+func scanAll(tree *LsmTree, options ScanOptions) ([]KeyValue, error) {
+	entries := []KeyValue{}
+	for next := &options; next != nil; {
+		result, err := tree.Scan(*next)
+		if err != nil {
+			return nil, err
+		}
+		if options.Limit != 0 && len(result.Entries) > options.Limit {
+			return nil, fmt.Errorf("page has %d entries,limit is %d", len(result.Entries), options.Limit)
+		}
+		entries = append(entries, result.Entries...)
+		next = result.Next
+	}
+	return entries, nil
+}
+
+func sameEntries(first []KeyValue, second []KeyValue) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for i := range first {
+		if !bytes.Equal(first[i].Key, second[i].Key) || !bytes.Equal(first[i].Value, second[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLsmTree_Scan(t *testing.T) {
+	db, err := Open(scanOptions(NewMemFS()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rnd := rand.New(rand.NewSource(1))
+	model := make(map[string]string)
+	scans := []ScanOptions{
+		{},
+		{Reverse: true},
+		{Start: []byte("k05"), End: []byte("k25")},
+		{Start: []byte("k05"), End: []byte("k25"), Reverse: true, Limit: 3},
+		{Prefix: []byte("k1"), Limit: 4},
+		{Prefix: []byte("k1"), Start: []byte("k15"), Reverse: true, Limit: 1},
+		{Start: []byte("k3"), End: []byte("k2")},
+		{Limit: 7},
+	}
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 30; i++ {
+			key := fmt.Sprintf("k%02d", rnd.Intn(40))
+			switch rnd.Intn(10) {
+			case 0, 1:
+				if err := db.Delete([]byte(key)); err != nil {
+					t.Fatal(err)
+				}
+				delete(model, key)
+			case 2:
+				end := fmt.Sprintf("k%02d", rnd.Intn(40))
+				if key >= end {
+					continue
+				}
+				if err := db.DeleteRange([]byte(key), []byte(end)); err != nil {
+					t.Fatal(err)
+				}
+				for modelKey := range model {
+					if modelKey >= key && modelKey < end {
+						delete(model, modelKey)
+					}
+				}
+			default:
+				value := fmt.Sprintf("v%d%s", round, strings.Repeat("x", rnd.Intn(10)))
+				if err := db.Put(&TableEntry{key: []byte(key), value: []byte(value)}); err != nil {
+					t.Fatal(err)
+				}
+				model[key] = value
+			}
+		}
+		//some keys are in memtable and the rest is in sstables
+		if round%3 == 0 {
+			if err := db.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if round%7 == 6 {
+			if err := db.Merge(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, options := range scans {
+			entries, err := scanAll(db.LsmTree, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := expectedScan(model, options); !sameEntries(entries, expected) {
+				t.Fatalf("round %d,scan %+v returned\n%q\nexpected\n%q", round, options, entries, expected)
+			}
+		}
+	}
+}
+
+func TestLsmTree_ScanPages(t *testing.T) {
+	db, err := Open(scanOptions(NewMemFS()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 0; i < 5; i++ {
+		if err := db.Put(&TableEntry{key: []byte{'a' + byte(i)}, value: []byte("value")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := db.Scan(ScanOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//all keys fit into the page,so there is no next page
+	if len(result.Entries) != 5 || result.Next != nil {
+		t.Fatalf("unexpected page: %+v", result)
+	}
+	result, err = db.Scan(ScanOptions{Limit: 2, Reverse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Entries[1].Key) != "d" || result.Next == nil || string(result.Next.End) != "d" {
+		t.Fatalf("the next page ends before the last key: %+v", result.Next)
+	}
+	db.Close()
+	if _, err := db.Scan(ScanOptions{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("closed tree has to return ErrClosed,got %v", err)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	cases := map[string]string{"ab": "ac", "a\xff": "b", "\xff\xff": "", "a\xffb": "a\xffc"}
+	for prefix, expected := range cases {
+		if end := prefixEnd([]byte(prefix)); string(end) != expected {
+			t.Fatalf("end of prefix %q is %q,expected %q", prefix, end, expected)
+		}
+	}
+}
@@ -2,171 +2,182 @@ package wiskey
 
 import (
 	"bytes"
-	"encoding/binary"
-	"os"
+	"fmt"
+	"io"
+	"sort"
 )
 
 type indexes []tableIndex
 
 const (
-	sstableExtension  = ".sstable$"
-	sstableFileLength = 10
+	sstableExtension = ".sstable$"
+	tempTableSuffix  = ".tmp" //table that is still being written
 )
 
 type SSTable struct {
-	footer  *Footer
-	indexes indexes
-	reader  *os.File
-	log     *vlog
+	footer     *Footer
+	indexes    indexes
+	properties *TableProperties
+	reader     File
+	log        *vlog
+	cache      *blockCache //can be nil,then blocks are read from the file every time
+	id         uint64      //id of the table in block cache
 }
 
 //Constructor
-func ReadTable(reader *os.File, log *vlog) *SSTable {
-	stats, _ := reader.Stat()
+func ReadTable(reader File, log *vlog) (*SSTable, error) {
+	stats, err := reader.Stat()
+	if err != nil {
+		return nil, err
+	}
 	//read footer
-	footer := readFooter(stats, reader)
-	indexes := readIndexes(stats, reader, *footer)
-	return &SSTable{footer: footer, indexes: indexes, reader: reader, log: log}
-}
-
-func OverrideVlogOffset(position int, meta *ValueMeta, file *os.File) error {
-	stats, _ := file.Stat()
-	footer := readFooter(stats, file)
-	indexes := readIndexes(stats, file, *footer)
-	index := indexes[position]
-	buffer := bytes.NewBuffer([]byte{})
-	//offset
-	if err := binary.Write(buffer, binary.BigEndian, meta.offset); err != nil {
-		return err
-	}
-	//length
-	if err := binary.Write(buffer, binary.BigEndian, meta.length); err != nil {
-		return err
-	}
-	_, err := file.WriteAt(buffer.Bytes(), int64(index.Offset))
+	footer, err := readFooter(stats, reader)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := readIndexes(reader, *footer)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	buffer, err := readChecksummedBlock(reader, footer.properties)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := readProperties(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return &SSTable{footer: footer, indexes: indexes, properties: properties, reader: reader, log: log}, nil
+}
+
+func (table *SSTable) Properties() TableProperties {
+	return *table.properties
 }
 
 func (table *SSTable) Close() {
 	table.reader.Close()
 }
 
-func (table *SSTable) Get(key []byte) (*SearchEntry, bool) {
-	//try smallest key
-	firstIndex := table.indexes[0]
-	compare, value := table.find(key, firstIndex)
-	if compare == 0 {
-		return value, true
-	}
-	//if smaller than firstIndex key in file than key is not in the file
-	if compare < 0 {
-		return nil, false
-	}
-	//try biggest key
-	//TODO: block can contain multiple keys, need to check them all
-	lastIndex := table.indexes[len(table.indexes)-1]
-	compare, value = table.find(key, lastIndex)
-	if compare == 0 {
-		return value, true
-	}
-	//if bigger than lastIndex key in file than key is not in the file
-	if compare > 0 {
-		return nil, false
-	}
-	search, found, _ := table.binarySearch(key)
-	return search, found
+func (table *SSTable) Get(key []byte) (*SearchEntry, bool, error) {
+	entry, found, err := table.lookup(key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	searchEntry, err := table.log.fetch(entry)
+	if err != nil {
+		return nil, false, err
+	}
+	return searchEntry, true, nil
 }
 
-func (table *SSTable) KeyAtIndex(key []byte) (bool, int) {
-	_, found, index := table.binarySearch(key)
-	return found, index
+//Find the entry in sstable without reading its value from the vlog
+//the index keeps the last key of every block,so only one block is read
+func (table *SSTable) lookup(key []byte) (*sstableEntry, bool, error) {
+	position := sort.Search(len(table.indexes), func(i int) bool {
+		return bytes.Compare(table.indexes[i].LastKey, key) >= 0
+	})
+	//bigger than the last key in the file
+	if position == len(table.indexes) {
+		return nil, false, nil
+	}
+	tableReader, err := table.blockReader(table.indexes[position])
+	if err != nil {
+		return nil, false, err
+	}
+	return tableReader.find(key)
 }
 
-//Tries to find given key in the sstable
-//Returns 1. value byte array or nil if not found
-//2. bool true if found,false otherwise
-//3. at which index this key was found
-func (table *SSTable) binarySearch(key []byte) (*SearchEntry, bool, int) {
-	left := 0
-	right := len(table.indexes) - 1
-	for left < right {
-		middle := (right-left)/2 + left
-		index := table.indexes[middle]
-		//read key length
-		tableReader := NewReader(table.reader, int64(index.Offset))
-		fileKeyLength := tableReader.readKeyLength()
-		//read actual key from the file
-		keyBuffer := tableReader.readKey(fileKeyLength)
-		compare := bytes.Compare(key, keyBuffer)
-		if compare == 0 {
-			return table.fetchFromVlog(tableReader), true, middle
-		} else if compare > 0 {
-			left = middle + 1
-		} else {
-			right = middle - 1
+//Read the whole block through the block cache
+func (table *SSTable) blockReader(index tableIndex) (*SSTableReader, error) {
+	if table.cache != nil {
+		if block, found := table.cache.get(table.id, index.Offset); found {
+			return newBlockReader(block)
 		}
 	}
-	index := table.indexes[left]
-	tableReader := NewReader(table.reader, int64(index.Offset))
-	for tableReader.offset != index.BlockLength {
-		keyLength := tableReader.readKeyLength()
-		keyFromFile := tableReader.readKey(keyLength)
-		if bytes.Compare(key, keyFromFile) == 0 {
-			return table.fetchFromVlog(tableReader), true, left
-		}
-		tableReader.readTimestamp()
-		tableReader.readValueOffset()
-		tableReader.readValueLength()
+	block, err := table.readBlock(index)
+	if err != nil {
+		return nil, err
+	}
+	if table.cache != nil {
+		table.cache.put(table.id, index.Offset, block)
 	}
-	return nil, false, -1
+	return newBlockReader(block)
 }
 
-func (table *SSTable) fetchFromVlog(tableReader *SSTableReader) *SearchEntry {
-	timestamp := tableReader.readTimestamp()
-	offset := tableReader.readValueOffset()
-	length := tableReader.readValueLength()
-	get, err := table.log.Get(ValueMeta{length: length, offset: offset})
+//Read the block,validate the checksum and decompress it
+func (table *SSTable) readBlock(index tableIndex) ([]byte, error) {
+	block, err := readChecksummedBlock(table.reader, blockHandle{offset: index.Offset, length: uint64(index.BlockLength)})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return &SearchEntry{key: get.key, value: get.value, timestamp: timestamp}
+	if len(block) == 0 {
+		return nil, errCorruptedBlock
+	}
+	last := len(block) - 1
+	return decompress(block[last], block[:last])
+}
+
+//Read the block and return it without the checksum
+func readChecksummedBlock(reader io.ReaderAt, handle blockHandle) ([]byte, error) {
+	block := make([]byte, handle.length)
+	_, err := reader.ReadAt(block, int64(handle.offset))
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: block is out of the file", ErrCorruptedTable)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return verifyChecksum(block)
+}
+
+//Iterator over all entries of the table in key order
+func (table *SSTable) iterator() *tableIterator {
+	return &tableIterator{table: table}
+}
+
+type tableIterator struct {
+	table  *SSTable
+	block  int            //index of the next block
+	reader *SSTableReader //reader of the current block
 }
 
-func (table *SSTable) find(key []byte, index tableIndex) (int, *SearchEntry) {
-	searchKeyLength := uint32(len(key))
-	tableReader := NewReader(table.reader, int64(index.Offset))
-	fileKeyLength := tableReader.readKeyLength()
-	//if keys length are not the same then don't make sense to compare an actual key
-	if searchKeyLength != fileKeyLength {
-		return len(key) - int(fileKeyLength), nil
-	}
-	//read actual key from the file
-	keyBuffer := tableReader.readKey(searchKeyLength)
-	compare := bytes.Compare(key, keyBuffer)
-	//they are equal
-	if compare == 0 {
-		return 0, table.fetchFromVlog(tableReader)
-	}
-	return compare, nil
+//Returns the next entry or nil when there are no entries anymore
+func (iterator *tableIterator) next() (*sstableEntry, error) {
+	for iterator.reader == nil || !iterator.reader.hasNext() {
+		if iterator.block == len(iterator.table.indexes) {
+			return nil, nil
+		}
+		//blocks are read directly,iteration doesn't evict hot blocks from the cache
+		block, err := iterator.table.readBlock(iterator.table.indexes[iterator.block])
+		if err != nil {
+			return nil, err
+		}
+		iterator.reader, err = newBlockReader(block)
+		if err != nil {
+			return nil, err
+		}
+		iterator.block++
+	}
+	return iterator.reader.next()
 }
 
 //Read the index from the file to in memory slice
-func readIndexes(stats os.FileInfo, reader *os.File, footer Footer) indexes {
-	buffer := make([]byte, stats.Size()-int64(footer.indexOffset)-footerSize)
-	reader.ReadAt(buffer, int64(footer.indexOffset))
-	start := 0
-	end := len(buffer)
-	indexes := indexes{}
-	for start != end {
-		blockLength := binary.BigEndian.Uint32(buffer[start : start+4])
-		blockOffset := binary.BigEndian.Uint32(buffer[start+4 : start+8])
-		indexes = append(indexes, tableIndex{Offset: blockOffset, BlockLength: blockLength})
-		start += 8
-	}
-	return indexes
+//data blocks are written before the index,so an index that points after it is corrupted
+func readIndexes(reader io.ReaderAt, footer Footer) (indexes, error) {
+	buffer, err := readChecksummedBlock(reader, footer.index)
+	if err != nil {
+		return nil, err
+	}
+	result, err := readIndexBlock(buffer)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range result {
+		if index.Offset > footer.index.offset || uint64(index.BlockLength) > footer.index.offset-index.Offset {
+			return nil, fmt.Errorf("%w: data block is out of the file", ErrCorruptedTable)
+		}
+	}
+	return result, nil
 }
 
 type SearchEntry struct {
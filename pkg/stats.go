@@ -0,0 +1,346 @@
+package wiskey
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	garbageSampleEntries = 256 //vlog entries that are read to estimate the garbage ratio
+	latencyBucketCount   = 25  //buckets with an upper bound,from 1µs to ~16s
+)
+
+//Operations whose count and latency are recorded
+const (
+	opGet = iota
+	opMultiGet
+	opPut
+	opDelete
+	opDeleteRange
+	opWrite
+	opScan
+	operationCount
+)
+
+var operationNames = [operationCount]string{"get", "multi_get", "put", "delete", "delete_range", "write", "scan"}
+
+//Upper bounds of latency histogram buckets,the last bucket counts slower operations
+var LatencyBounds = func() []time.Duration {
+	bounds := make([]time.Duration, latencyBucketCount)
+	for i := range bounds {
+		bounds[i] = time.Microsecond << uint(i)
+	}
+	return bounds
+}()
+
+//Snapshot of the engine state and counters since Open
+type Stats struct {
+	Memtable   MemtableStats             `json:"memtable"` //all column families together
+	Levels     []LevelStats              `json:"levels"`   //all column families together
+	Families   map[string]FamilyStats    `json:"families"`
+	Vlog       VlogStats                 `json:"vlog"`
+	Flush      WorkStats                 `json:"flush"`
+	Compaction WorkStats                 `json:"compaction"`
+	Gc         WorkStats                 `json:"gc"`
+	WriteStall WorkStats                 `json:"write_stall"` //writes that waited for the flush of a full memtable
+	BlockCache CacheUsage                `json:"block_cache"`
+	ValueCache CacheUsage                `json:"value_cache"`
+	Operations map[string]OperationStats `json:"operations"`
+}
+
+type MemtableStats struct {
+	Entries int `json:"entries"`
+	Bytes   int `json:"bytes"`
+}
+
+//sstables aren't leveled yet,so every table is in level 0
+type LevelStats struct {
+	Level  int   `json:"level"`
+	Tables int   `json:"tables"`
+	Bytes  int64 `json:"bytes"`
+}
+
+type FamilyStats struct {
+	Memtable MemtableStats `json:"memtable"`
+	Levels   []LevelStats  `json:"levels"`
+}
+
+//Offsets are logical,see vlog
+//the garbage ratio is estimated from the oldest flushed entries,the ones the next gc runs read
+//it's sampled by a background worker,so it can be older than other fields
+type VlogStats struct {
+	Size         int64   `json:"size"` //size of the file
+	Head         uint64  `json:"head"`
+	Tail         uint64  `json:"tail"`
+	SampledBytes int64   `json:"sampled_bytes"`
+	GarbageRatio float64 `json:"garbage_ratio"`
+}
+
+//Background or explicit work,bytes are read and written by it
+type WorkStats struct {
+	Runs         uint64        `json:"runs"`
+	ReadBytes    uint64        `json:"read_bytes"`
+	WrittenBytes uint64        `json:"written_bytes"`
+	Duration     time.Duration `json:"duration_ns"` //of all runs
+}
+
+type CacheUsage struct {
+	CacheStats
+	HitRate float64 `json:"hit_rate"`
+}
+
+type OperationStats struct {
+	Count     uint64          `json:"count"`
+	Duration  time.Duration   `json:"duration_ns"` //of all operations
+	P50       time.Duration   `json:"p50_ns"`      //upper bound of the bucket
+	P99       time.Duration   `json:"p99_ns"`
+	P999      time.Duration   `json:"p999_ns"`
+	Histogram []LatencyBucket `json:"histogram"` //only buckets with operations
+}
+
+//Operations that took less than UpperBound and more than the bound of the previous bucket
+//the last bucket has no upper bound
+type LatencyBucket struct {
+	UpperBound time.Duration `json:"le_ns"`
+	Count      uint64        `json:"count"`
+}
+
+//Counters of the root tree,they are updated with atomics so readers don't need the write lock
+type engineStats struct {
+	operations [operationCount]LatencyHistogram
+	flush      workCounter
+	compaction workCounter
+	gc         workCounter
+	writeStall workCounter
+	garbage    atomic.Value //garbageEstimate,it's sampled by a worker and not by Stats
+}
+
+//Bytes of live entries among the sampled oldest flushed vlog entries
+type garbageEstimate struct {
+	live    int64
+	sampled int64
+}
+
+//Latencies in LatencyBounds buckets,it's safe for concurrent use
+type LatencyHistogram struct {
+	count    uint64
+	duration uint64
+	buckets  [latencyBucketCount + 1]uint64
+}
+
+type workCounter struct {
+	runs     uint64
+	read     uint64
+	written  uint64
+	duration uint64
+}
+
+//Record the operation that started at start,used as defer stats.operation(opGet, time.Now())
+func (stats *engineStats) operation(op int, start time.Time) {
+	stats.operations[op].Record(time.Since(start))
+}
+
+func (histogram *LatencyHistogram) Record(duration time.Duration) {
+	bucket := sort.Search(len(LatencyBounds), func(i int) bool {
+		return duration <= LatencyBounds[i]
+	})
+	atomic.AddUint64(&histogram.count, 1)
+	atomic.AddUint64(&histogram.duration, uint64(duration))
+	atomic.AddUint64(&histogram.buckets[bucket], 1)
+}
+
+func (histogram *LatencyHistogram) Snapshot() OperationStats {
+	stats := OperationStats{
+		Count:     atomic.LoadUint64(&histogram.count),
+		Duration:  time.Duration(atomic.LoadUint64(&histogram.duration)),
+		Histogram: []LatencyBucket{},
+	}
+	counts := make([]uint64, len(histogram.buckets))
+	total := uint64(0)
+	for i := range counts {
+		counts[i] = atomic.LoadUint64(&histogram.buckets[i])
+		total += counts[i]
+		if counts[i] != 0 {
+			stats.Histogram = append(stats.Histogram, LatencyBucket{UpperBound: latencyBound(i), Count: counts[i]})
+		}
+	}
+	//the same rank as in benchmarks,but only the bucket of the operation is known
+	percentile := func(p float64) time.Duration {
+		if total == 0 {
+			return 0
+		}
+		rank, seen := uint64(float64(total-1)*p), uint64(0)
+		for i, count := range counts {
+			seen += count
+			if seen > rank {
+				return latencyBound(i)
+			}
+		}
+		return 0
+	}
+	stats.P50, stats.P99, stats.P999 = percentile(0.5), percentile(0.99), percentile(0.999)
+	return stats
+}
+
+//Upper bound of the bucket,0 for the last one
+func latencyBound(bucket int) time.Duration {
+	if bucket < len(LatencyBounds) {
+		return LatencyBounds[bucket]
+	}
+	return 0
+}
+
+func (counter *workCounter) record(start time.Time, read int64, written int64) {
+	atomic.AddUint64(&counter.runs, 1)
+	atomic.AddUint64(&counter.read, uint64(read))
+	atomic.AddUint64(&counter.written, uint64(written))
+	atomic.AddUint64(&counter.duration, uint64(time.Since(start)))
+}
+
+func (counter *workCounter) snapshot() WorkStats {
+	return WorkStats{
+		Runs:         atomic.LoadUint64(&counter.runs),
+		ReadBytes:    atomic.LoadUint64(&counter.read),
+		WrittenBytes: atomic.LoadUint64(&counter.written),
+		Duration:     time.Duration(atomic.LoadUint64(&counter.duration)),
+	}
+}
+
+func cacheUsage(stats CacheStats) CacheUsage {
+	return CacheUsage{CacheStats: stats, HitRate: stats.HitRate()}
+}
+
+//Statistics of all column families,the vlog and caches and counters since Open
+func (lsm *LsmTree) Stats() (Stats, error) {
+	root := lsm.root
+	root.rwm.RLock()
+	defer root.rwm.RUnlock()
+	if root.closed {
+		return Stats{}, ErrClosed
+	}
+	stats := Stats{
+		Levels:     []LevelStats{{Level: 0}},
+		Families:   make(map[string]FamilyStats),
+		Flush:      root.stats.flush.snapshot(),
+		Compaction: root.stats.compaction.snapshot(),
+		Gc:         root.stats.gc.snapshot(),
+		WriteStall: root.stats.writeStall.snapshot(),
+		BlockCache: cacheUsage(root.BlockCacheStats()),
+		ValueCache: cacheUsage(root.ValueCacheStats()),
+		Operations: make(map[string]OperationStats),
+	}
+	families := map[string]*LsmTree{defaultFamily: root}
+	for name, family := range root.families {
+		families[name] = family
+	}
+	for name, family := range families {
+		familyStats, err := family.familyStats()
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.Families[name] = familyStats
+		stats.Memtable.Entries += familyStats.Memtable.Entries
+		stats.Memtable.Bytes += familyStats.Memtable.Bytes
+		stats.Levels[0].Tables += familyStats.Levels[0].Tables
+		stats.Levels[0].Bytes += familyStats.Levels[0].Bytes
+	}
+	garbage, _ := root.stats.garbage.Load().(garbageEstimate)
+	stats.Vlog = VlogStats{Size: int64(root.log.size), Head: root.log.head, Tail: root.log.tail, SampledBytes: garbage.sampled}
+	if garbage.sampled != 0 {
+		stats.Vlog.GarbageRatio = 1 - float64(garbage.live)/float64(garbage.sampled)
+	}
+	for op, name := range operationNames {
+		stats.Operations[name] = root.stats.operations[op].Snapshot()
+	}
+	return stats, nil
+}
+
+func (lsm *LsmTree) familyStats() (FamilyStats, error) {
+	size, err := lsm.tablesSize(lsm.sstables)
+	if err != nil {
+		return FamilyStats{}, err
+	}
+	return FamilyStats{
+		Memtable: MemtableStats{Entries: lsm.memtable.Size(), Bytes: lsm.memtable.size},
+		Levels:   []LevelStats{{Level: 0, Tables: len(lsm.sstables), Bytes: size}},
+	}, nil
+}
+
+//Size of sstable files in bytes
+func (lsm *LsmTree) tablesSize(tables []string) (int64, error) {
+	size := int64(0)
+	for _, table := range tables {
+		info, err := lsm.fs().Stat(table)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+//Refresh the garbage estimate of Stats,the caller holds the lock
+//it reads up to garbageSampleEntries entries,so Stats only returns the last estimate
+func (lsm *LsmTree) sampleGarbageRatio() error {
+	live, sampled, err := lsm.log.sampleGarbage(garbageSampleEntries, lsm.root)
+	if err != nil {
+		return err
+	}
+	lsm.root.stats.garbage.Store(garbageEstimate{live: live, sampled: sampled})
+	return nil
+}
+
+//Background job that refreshes the garbage estimate of Stats
+func (lsm *LsmTree) estimateGarbage() error {
+	lsm.rwm.RLock()
+	defer lsm.rwm.RUnlock()
+	if lsm.root.closed {
+		return ErrClosed
+	}
+	return lsm.sampleGarbageRatio()
+}
+
+//Read up to given amount of flushed entries from the tail like gc does,but don't relocate them
+//Returns bytes of entries that are still live and bytes of all read entries
+func (log *vlog) sampleGarbage(entries int, lsm *LsmTree) (int64, int64, error) {
+	flushed := int64(log.head) - int64(log.tail)
+	if flushed <= 0 {
+		return 0, 0, nil
+	}
+	file, err := log.fs.OpenFile(log.file, os.O_RDONLY, 0666)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	readBytesSize, live := int64(0), int64(0)
+	for counter := 0; readBytesSize < flushed && counter < entries; counter++ {
+		entry, length, err := readEntry(reader)
+		if err != nil {
+			return 0, 0, err
+		}
+		meta := &ValueMeta{length: length, offset: log.tail + uint64(readBytesSize)}
+		batchEntries, batchMetas, err := unbatch(entry, meta)
+		if err != nil {
+			return 0, 0, err
+		}
+		for i, batchEntry := range batchEntries {
+			tree := lsm.familyById(batchEntry.family)
+			if tree == nil || batchEntry.isRangeDeletion() {
+				continue
+			}
+			pointed, err := tree.pointsTo(batchEntry.key, batchMetas[i].offset)
+			if err != nil {
+				return 0, 0, err
+			}
+			if pointed {
+				live += int64(batchMetas[i].length)
+			}
+		}
+		readBytesSize += int64(length)
+	}
+	return live, readBytesSize, nil
+}
@@ -0,0 +1,125 @@
+package wiskey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func statsOptions(fs FS) Options {
+	return Options{Dir: "/data", FS: fs, MemtableSize: 100000, GcEntries: 100, CompactionStrategy: NoCompaction,
+		Logger: log.New(ioutil.Discard, "", 0)}
+}
+
+func TestLsmTree_Stats(t *testing.T) {
+	db, err := Open(statsOptions(NewMemFS()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	users, err := db.CreateColumnFamily("users", 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := []byte(strings.Repeat("v", 100))
+	for table := 0; table < 2; table++ {
+		for i := 0; i < 10; i++ {
+			if err := db.Put(&TableEntry{key: []byte(fmt.Sprintf("key%d", i)), value: value}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := db.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := users.Put(&TableEntry{key: []byte("anita"), value: value}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get([]byte("key1")); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Memtable.Entries != 1 || stats.Families["users"].Memtable.Entries != 1 || stats.Memtable.Bytes == 0 {
+		t.Fatalf("only the family has an entry in memtable: %+v", stats)
+	}
+	if stats.Levels[0].Tables != 2 || stats.Families[defaultFamily].Levels[0].Tables != 2 || stats.Levels[0].Bytes == 0 {
+		t.Fatalf("default family has two sstables: %+v", stats.Levels)
+	}
+	if stats.Flush.Runs != 2 || stats.Flush.WrittenBytes != uint64(stats.Levels[0].Bytes) {
+		t.Fatalf("two flushes wrote all sstables: %+v", stats.Flush)
+	}
+	//Stats doesn't read the vlog,the worker didn't sample it yet
+	if stats.Vlog.SampledBytes != 0 {
+		t.Fatalf("garbage was sampled by Stats: %+v", stats.Vlog)
+	}
+	if err := db.estimateGarbage(); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = db.Stats(); err != nil {
+		t.Fatal(err)
+	}
+	//the first table is overwritten by the second one
+	if stats.Vlog.Head <= stats.Vlog.Tail || stats.Vlog.GarbageRatio < 0.45 || stats.Vlog.GarbageRatio > 0.55 {
+		t.Fatalf("half of the flushed vlog is garbage: %+v", stats.Vlog)
+	}
+	if stats.Operations["put"].Count != 21 || stats.Operations["get"].Count != 1 || stats.Operations["delete"].Count != 0 {
+		t.Fatalf("unexpected operation counters: %+v", stats.Operations)
+	}
+	if err := db.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompressVlog(); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = db.Stats(); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Compaction.Runs != 1 || stats.Compaction.WrittenBytes == 0 || stats.Compaction.ReadBytes <= stats.Compaction.WrittenBytes {
+		t.Fatalf("merge has to rewrite two tables into a smaller one: %+v", stats.Compaction)
+	}
+	if stats.Gc.Runs != 1 || stats.Gc.ReadBytes == 0 || stats.Gc.ReadBytes <= stats.Gc.WrittenBytes {
+		t.Fatalf("gc has to drop overwritten values: %+v", stats.Gc)
+	}
+	if stats.Levels[0].Tables != 1 {
+		t.Fatalf("tables have to be merged: %+v", stats.Levels)
+	}
+	encoded, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"garbage_ratio"`, `"hit_rate"`, `"p99_ns"`, `"multi_get"`} {
+		if !strings.Contains(string(encoded), field) {
+			t.Fatalf("%s is missing in %s", field, encoded)
+		}
+	}
+	db.Close()
+	if _, err := db.Stats(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("closed tree has to return ErrClosed,got %v", err)
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	var histogram LatencyHistogram
+	for i := 0; i < 98; i++ {
+		histogram.Record(3 * time.Microsecond)
+	}
+	histogram.Record(time.Millisecond)
+	histogram.Record(time.Hour)
+	stats := histogram.Snapshot()
+	if stats.Count != 100 || stats.P50 != 4*time.Microsecond || stats.P99 != 1024*time.Microsecond || stats.P999 != 1024*time.Microsecond {
+		t.Fatalf("unexpected percentiles: %+v", stats)
+	}
+	//the slowest operation is in the bucket without an upper bound
+	expected := []LatencyBucket{{4 * time.Microsecond, 98}, {1024 * time.Microsecond, 1}, {0, 1}}
+	if fmt.Sprint(stats.Histogram) != fmt.Sprint(expected) {
+		t.Fatalf("expected buckets %v,got %v", expected, stats.Histogram)
+	}
+}
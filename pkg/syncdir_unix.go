@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package wiskey
+
+import "os"
+
+//Entries of the directory are durable only after fsync of the directory itself
+func syncDir(dirname string) error {
+	dir, err := os.Open(dirname)
+	if err != nil {
+		return err
+	}
+	if err := dir.Sync(); err != nil {
+		dir.Close()
+		return err
+	}
+	return dir.Close()
+}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package wiskey
+
+//Directories can't be opened for fsync on windows,ntfs journals its metadata instead
+func syncDir(dirname string) error {
+	return nil
+}
@@ -2,6 +2,7 @@ package wiskey
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -10,7 +11,12 @@ const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 var seededRand = rand.New(
 	rand.NewSource(time.Now().UnixNano()))
 
+//rand.Rand isn't safe for concurrent use and sstable names are generated by flushes and background merges
+var randMutex sync.Mutex
+
 func RandStringBytes(n int) string {
+	randMutex.Lock()
+	defer randMutex.Unlock()
 	b := make([]byte, n)
 	for i := range b {
 		b[i] = letterBytes[seededRand.Intn(len(letterBytes))]
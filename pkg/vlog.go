@@ -1,46 +1,164 @@
 package wiskey
 
 import (
+	"bufio"
+	"bytes"
 	binary "encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+const truncationSuffix = ".gc." //suffix of the vlog copy without collected entries,it's followed by the new tail
+
+//the entry was read whole but its bytes don't match the checksum
+var errChecksumMismatch = fmt.Errorf("%w: vlog entry checksum mismatch", ErrCorruption)
+
+//offsets in the vlog are logical,the tail is the offset of the first byte in the file
+//gc cuts the beginning of the file and moves the tail, so offsets of other entries never change
 type vlog struct {
+	fs         FS
 	file       string
-	size       uint32 // current size of the file,it has to be updated every time you append a new value
-	checkpoint string //path to the file with checkpoint
+	size       uint64    // current size of the file,it has to be updated every time you append a new value
+	checkpoint string    //path to the file with checkpoint
+	head       uint64    //all entries before the head are flushed to sstables
+	tail       uint64    //offset of the first entry in the file,offsets only grow because gc moves it forward
+	cache      *lruCache //cache of entries by offset,can be nil
+	sync       bool      //fsync the file after every append
+	readOnly   bool      //the file is never changed,a torn tail is skipped instead of truncated
+}
+
+func NewVlog(file string, checkpoint string) (*vlog, error) {
+	return newVlog(NewOsFS(), file, checkpoint)
 }
 
-func NewVlog(file string, checkpoint string) *vlog {
-	vlogFile, err := os.OpenFile(file, os.O_CREATE, 0666)
+func newVlog(fs FS, file string, checkpoint string) (*vlog, error) {
+	vlogFile, err := fs.OpenFile(file, os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
 	vlogFile.Close()
+	if err := fs.SyncDir(filepath.Dir(file)); err != nil {
+		return nil, err
+	}
+	log := &vlog{
+		fs:         fs,
+		file:       file,
+		checkpoint: checkpoint,
+	}
+	err = log.readCheckpoint()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	stat, err := os.Stat(file)
+	err = log.finishTruncation()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return &vlog{
+	err = log.readSize()
+	if err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+//Open the existing vlog without creating,truncating or renaming files
+//if gc was interrupted after the checkpoint was moved,the copy without collected entries is read
+func openReadOnlyVlog(fs FS, file string, checkpoint string) (*vlog, error) {
+	log := &vlog{
+		fs:         fs,
 		file:       file,
 		checkpoint: checkpoint,
-		size:       uint32(stat.Size()),
+		readOnly:   true,
 	}
+	err := log.readCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	truncated := file + truncationSuffix + strconv.FormatUint(log.tail, 10)
+	if _, err := fs.Stat(truncated); err == nil {
+		log.file = truncated
+	}
+	err = log.readSize()
+	if err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+//Size of the file,the head from the checkpoint has to be inside of it
+func (log *vlog) readSize() error {
+	stat, err := log.fs.Stat(log.file)
+	if err != nil {
+		return err
+	}
+	log.size = uint64(stat.Size())
+	if log.head < log.tail || log.head > log.tail+log.size {
+		return fmt.Errorf("%w: vlog head %d is out of the file", ErrCorruption, log.head)
+	}
+	return nil
 }
 
 //Save the latest vlog head position in the checkpoint file
 func (log *vlog) FlushHead() error {
-	writer, err := os.OpenFile(log.checkpoint, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
+	log.head = log.tail + log.size
+	return log.writeCheckpoint()
+}
+
+//Checkpoint keeps the head and the tail as uint64
+//+------+------+
+//| Head | Tail |
+//+------+------+
+func (log *vlog) writeCheckpoint() error {
+	buffer := bytes.NewBuffer([]byte{})
+	if err := binary.Write(buffer, binary.BigEndian, log.head); err != nil {
 		return err
 	}
-	defer writer.Close()
-	err = writer.Truncate(0)
+	if err := binary.Write(buffer, binary.BigEndian, log.tail); err != nil {
+		return err
+	}
+	//write to temp file first so a crash doesn't leave an empty checkpoint
+	return writeFileAtomically(log.fs, log.checkpoint, buffer.Bytes())
+}
+
+//Read the head and the tail,if file doesn't exist then nothing was flushed yet
+//old checkpoints keep only the uint32 head,they are told apart by the size
+func (log *vlog) readCheckpoint() error {
+	buffer, err := readFile(log.fs, log.checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	return binary.Write(writer, binary.BigEndian, log.size)
+	switch len(buffer) {
+	case 0:
+	case uint32Size:
+		log.head = uint64(binary.BigEndian.Uint32(buffer))
+	case int64Size * 2:
+		log.head = binary.BigEndian.Uint64(buffer[:int64Size])
+		log.tail = binary.BigEndian.Uint64(buffer[int64Size:])
+	default:
+		return fmt.Errorf("%w: checkpoint has %d bytes", ErrCorruption, len(buffer))
+	}
+	return nil
+}
+
+//Position of the entry in the file
+//metas come from sstables,so a corrupted one is rejected before anything is read
+func (log *vlog) position(meta *ValueMeta) (int64, error) {
+	if meta.offset < log.tail {
+		return 0, fmt.Errorf("vlog entry at offset %d was garbage collected", meta.offset)
+	}
+	if meta.offset > log.tail+log.size || uint64(meta.length) > log.tail+log.size-meta.offset {
+		return 0, fmt.Errorf("%w: vlog entry at offset %d is out of the file", ErrCorruption, meta.offset)
+	}
+	return int64(meta.offset - log.tail), nil
 }
 
 // Example of vlog entry to read
@@ -48,99 +166,331 @@ func (log *vlog) FlushHead() error {
 //| Key Length | Value length | Key | Value |
 //+------------+--------------+-----+-------+
 func (log *vlog) Get(meta ValueMeta) (*TableEntry, error) {
-	reader, err := os.OpenFile(log.file, os.O_RDONLY|os.O_CREATE, 0666)
+	if log.cache != nil {
+		if entry, found := log.cache.get(meta.offset); found {
+			return entry.(*TableEntry), nil
+		}
+	}
+	position, err := log.position(&meta)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := log.fs.OpenFile(log.file, os.O_RDONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
-	reader.Seek(int64(meta.offset), 0)
 	buffer := make([]byte, meta.length)
-	reader.Read(buffer)
-	keyLength := binary.BigEndian.Uint32(buffer[0:4])
-	key := buffer[8 : 8+keyLength]
-	value := buffer[8+keyLength:]
-	return &TableEntry{key: key, value: value}, nil
+	_, err = reader.ReadAt(buffer, position)
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: vlog entry at offset %d is out of the file", ErrCorruption, meta.offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry, _, err := readEntry(bytes.NewReader(buffer))
+	if err != nil {
+		return nil, corrupted(err)
+	}
+	log.cacheEntry(meta.offset, entry)
+	return entry, nil
 }
 
-func (log *vlog) RunGc(entries int, lsm *LsmTree) error {
-	file, err := os.OpenFile(log.file, os.O_RDONLY, 0666)
+//Read the value of sstable entry,vlog is not touched if the value is inline
+func (log *vlog) fetch(entry *sstableEntry) (*SearchEntry, error) {
+	if entry.inline {
+		return &SearchEntry{key: entry.key, value: entry.value, timestamp: entry.timeStamp}, nil
+	}
+	get, err := log.Get(ValueMeta{length: entry.valueLength, offset: entry.valueOffset})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer file.Close()
-	stat, err := file.Stat()
+	return &SearchEntry{key: get.key, value: get.value, timestamp: entry.timeStamp}, nil
+}
+
+//Keep decoded entries in memory to skip reading the file for hot keys
+//capacity is in bytes
+func (log *vlog) SetCache(capacity int) {
+	log.cache = newLruCache(capacity)
+}
+
+func (log *vlog) cacheEntry(offset uint64, entry *TableEntry) {
+	if log.cache != nil {
+		log.cache.put(offset, entry, len(entry.key)+len(entry.value))
+	}
+}
+
+//The entry at given offset is not used anymore
+func (log *vlog) uncache(offset uint64) {
+	if log.cache != nil {
+		log.cache.remove(offset)
+	}
+}
+
+//Read entries for all given metas,nil metas are skipped
+//entries that are next to each other in the vlog are read with a single read
+func (log *vlog) GetMany(metas []*ValueMeta) ([]*TableEntry, error) {
+	entries := make([]*TableEntry, len(metas))
+	var order []int
+	for i, meta := range metas {
+		if meta == nil {
+			continue
+		}
+		if log.cache != nil {
+			if entry, found := log.cache.get(meta.offset); found {
+				entries[i] = entry.(*TableEntry)
+				continue
+			}
+		}
+		if _, err := log.position(meta); err != nil {
+			return nil, err
+		}
+		order = append(order, i)
+	}
+	if len(order) == 0 {
+		return entries, nil
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return metas[order[a]].offset < metas[order[b]].offset
+	})
+	reader, err := log.fs.OpenFile(log.file, os.O_RDONLY|os.O_CREATE, 0666)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer reader.Close()
+	start := 0
+	for start < len(order) {
+		//extend the run while the next entry starts where the current one ends
+		runOffset := metas[order[start]].offset
+		runEnd := runOffset + uint64(metas[order[start]].length)
+		end := start + 1
+		for end < len(order) && metas[order[end]].offset <= runEnd {
+			meta := metas[order[end]]
+			if meta.offset+uint64(meta.length) > runEnd {
+				runEnd = meta.offset + uint64(meta.length)
+			}
+			end++
+		}
+		buffer := make([]byte, runEnd-runOffset)
+		if _, err := reader.ReadAt(buffer, int64(runOffset-log.tail)); err != nil {
+			return nil, corrupted(err)
+		}
+		for _, i := range order[start:end] {
+			position := metas[i].offset - runOffset
+			entry, _, err := readEntry(bytes.NewReader(buffer[position : position+uint64(metas[i].length)]))
+			if err != nil {
+				return nil, corrupted(err)
+			}
+			entries[i] = entry
+			log.cacheEntry(metas[i].offset, entry)
+		}
+		start = end
+	}
+	return entries, nil
+}
+
+//Read a single entry from the vlog
+//Returns the entry and the amount of bytes it takes in the vlog
+func readEntry(reader io.Reader) (*TableEntry, uint32, error) {
+	header := make([]byte, uint32Size*2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, 0, err
+	}
+	keyHeader := binary.BigEndian.Uint32(header[0:uint32Size])
+	keyLength := keyHeader & keyLengthMask
+	valueLength := binary.BigEndian.Uint32(header[uint32Size:])
+	length := uint32Size*2 + keyLength + valueLength
+	entry := &TableEntry{flags: keyHeader &^ keyLengthMask &^ familyFlag &^ checksumFlag}
+	checksum := crc32.Checksum(header, crcTable)
+	if keyHeader&familyFlag != 0 {
+		family := make([]byte, uint32Size)
+		if _, err := io.ReadFull(reader, family); err != nil {
+			return nil, 0, unexpectedEOF(err)
+		}
+		entry.family = binary.BigEndian.Uint32(family)
+		checksum = crc32.Update(checksum, crcTable, family)
+		length += uint32Size
+	}
+	//lengths can be corrupted,so the body grows with the read data instead of being allocated upfront
+	body := bytes.NewBuffer([]byte{})
+	if _, err := io.CopyN(body, reader, int64(keyLength)+int64(valueLength)); err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	entry.key = body.Bytes()[:keyLength]
+	entry.value = body.Bytes()[keyLength:]
+	if keyHeader&checksumFlag != 0 {
+		stored := make([]byte, uint32Size)
+		if _, err := io.ReadFull(reader, stored); err != nil {
+			return nil, 0, unexpectedEOF(err)
+		}
+		length += uint32Size
+		if crc32.Update(checksum, crcTable, body.Bytes()) != binary.BigEndian.Uint32(stored) {
+			return nil, length, errChecksumMismatch
+		}
+	}
+	if entry.flags&compressedFlag != 0 {
+		if len(entry.value) == 0 {
+			return nil, 0, fmt.Errorf("%w: compressed vlog entry doesn't have compression type", ErrCorruption)
+		}
+		value, err := decompress(entry.value[0], entry.value[1:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: can't decompress vlog entry: %v", ErrCorruption, err)
+		}
+		entry.value = value
+		entry.flags &^= compressedFlag
+	}
+	return entry, length, nil
+}
+
+//the entry was partially read, it means it was torn by a crash
+//Entry that was referenced by memtable or sstable can't be read
+func corrupted(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: vlog entry is truncated", ErrCorruption)
+	}
+	return err
+}
+
+//Nothing is left in the reader
+func isEOF(reader *bufio.Reader) bool {
+	_, err := reader.Peek(1)
+	return err == io.EOF
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
 	}
-	logFileSize := stat.Size()
-	if logFileSize == 0 {
+	return err
+}
+
+//Split the vlog entry into the entries it keeps, a batch keeps all its entries in the value
+//Returns entries and their metas in the vlog
+func unbatch(entry *TableEntry, meta *ValueMeta) ([]*TableEntry, []*ValueMeta, error) {
+	if !entry.isBatch() {
+		return []*TableEntry{entry}, []*ValueMeta{meta}, nil
+	}
+	var entries []*TableEntry
+	var metas []*ValueMeta
+	reader := bytes.NewReader(entry.value)
+	//entries start right after key length and value length of the batch
+	offset := meta.offset + uint32Size*2
+	for reader.Len() != 0 {
+		batchEntry, length, err := readEntry(reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, batchEntry)
+		metas = append(metas, &ValueMeta{length: length, offset: offset})
+		offset += uint64(length)
+	}
+	return entries, metas, nil
+}
+
+func (log *vlog) RunGc(entries int, lsm *LsmTree) error {
+	//entries after the head are still in memtables,they are never collected
+	flushed := int64(log.head) - int64(log.tail)
+	if flushed <= 0 {
 		return nil
 	}
+	file, err := log.fs.OpenFile(log.file, os.O_RDONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
 	readBytesSize := int64(0) //how many bytes were read from a file
 	counter := 0
-	for readBytesSize < logFileSize && counter < entries {
-		keyLengthBuffer := make([]byte, uint32Size)
-		//read key length
-		_, _ = file.Read(keyLengthBuffer)
-		keyLength := binary.BigEndian.Uint32(keyLengthBuffer)
-		//read value length
-		valueLengthBuffer := make([]byte, uint32Size)
-		_, _ = file.Read(valueLengthBuffer)
-		valueLength := binary.BigEndian.Uint32(valueLengthBuffer)
-		keyBuffer := make([]byte, keyLength)
-		valueBuffer := make([]byte, valueLength)
-		_, _ = file.Read(keyBuffer)
-		_, _ = file.Read(valueBuffer)
-		tableWithIndexes := lsm.Exists(keyBuffer)
-		if len(tableWithIndexes) != 0 {
-			entry := &TableEntry{key: keyBuffer, value: valueBuffer}
-			valueMeta, err := log.Append(entry)
+	for readBytesSize < flushed && counter < entries {
+		entry, length, err := readEntry(reader)
+		if err != nil {
+			return err
+		}
+		meta := &ValueMeta{length: length, offset: log.tail + uint64(readBytesSize)}
+		//entries of a batch are relocated one by one
+		batchEntries, batchMetas, err := unbatch(entry, meta)
+		if err != nil {
+			return err
+		}
+		for i, batchEntry := range batchEntries {
+			err := log.relocate(batchEntry, batchMetas[i], lsm)
 			if err != nil {
 				return err
 			}
-			for i := range tableWithIndexes {
-				tableWithIndex := tableWithIndexes[i]
-				file, err := os.OpenFile(tableWithIndex.tablePath, os.O_RDWR, 0666)
-				if err != nil {
-					return err
-				}
-				err = OverrideVlogOffset(tableWithIndex.index, valueMeta, file)
-				if err != nil {
-					return err
-				}
-				err = file.Close()
-				if err != nil {
-					return err
-				}
-			}
 		}
-		readBytesSize += int64(uint32Size + keyLength + uint32Size + valueLength)
+		readBytesSize += int64(length)
 		counter++
 	}
-	//TODO: so we skipped deleted entries
+	//relocated entries have to be on the disk before their old copies are removed
+	err = log.syncFile()
+	if err != nil {
+		return err
+	}
 	//now we have to remove the beginning of the file
 	//starting from readBytesSize position
-	err = truncateVlog(readBytesSize, log.file)
+	err = log.truncate(readBytesSize)
 	if err != nil {
 		return err
 	}
-	info, err := os.Stat(log.file)
+	info, err := log.fs.Stat(log.file)
 	if err != nil {
 		return err
 	}
-	log.size = uint32(info.Size())
+	log.size = uint64(info.Size())
+	if log.cache != nil {
+		tail := log.tail
+		log.cache.removeIf(func(key interface{}) bool {
+			return key.(uint64) < tail
+		})
+	}
 	return nil
 }
 
-func truncateVlog(offset int64, file string) error {
-	fin, err := os.Open(file)
+//Append the entry to the head if it's still the latest version of the key,
+//the new offset is saved in the memtable and reaches sstables with the next flush
+func (log *vlog) relocate(entry *TableEntry, meta *ValueMeta, lsm *LsmTree) error {
+	tree := lsm.familyById(entry.family)
+	//range tombstones are kept by the lsm tree itself,nothing points to them
+	if tree == nil || entry.isRangeDeletion() {
+		return nil
+	}
+	log.uncache(meta.offset)
+	live, err := tree.pointsTo(entry.key, meta.offset)
+	if err != nil || !live {
+		return err
+	}
+	compressed, err := entry.compressed(tree.compressor)
+	if err != nil {
+		return err
+	}
+	valueMeta, err := log.Append(compressed)
+	if err != nil {
+		return err
+	}
+	err = tree.memtable.Put(entry.key, tree.inlined(entry, valueMeta))
+	if err != nil {
+		return err
+	}
+	if tree.memtable.isFull() {
+		return lsm.flush()
+	}
+	return nil
+}
+
+//Remove the beginning of the file till the offset and move the tail
+//the rest of the file is copied to vlog.gc.<new tail>,then the checkpoint gets the new tail
+//and the copy replaces the vlog,a crash between them is finished by finishTruncation
+func (log *vlog) truncate(offset int64) error {
+	fin, err := log.fs.OpenFile(log.file, os.O_RDONLY, 0)
 	if err != nil {
 		return err
 	}
 	defer fin.Close()
 
-	tempFileName := RandStringBytes(10)
-	fout, err := os.Create(tempFileName)
+	//temp file is next to the vlog so rename doesn't cross file systems
+	tail := log.tail + uint64(offset)
+	tempFileName := log.file + truncationSuffix + strconv.FormatUint(tail, 10)
+	fout, err := log.fs.OpenFile(tempFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
@@ -156,59 +506,124 @@ func truncateVlog(offset int64, file string) error {
 	if err != nil {
 		return err
 	}
-	if err := os.Remove(file); err != nil {
-		panic(err)
+	if err := fout.Sync(); err != nil {
+		return err
 	}
-
-	if err := os.Rename(tempFileName, file); err != nil {
+	//the copy has to survive a crash before the checkpoint points to it
+	if err := log.fs.SyncDir(filepath.Dir(log.file)); err != nil {
 		return err
 	}
-	return nil
+	log.tail = tail
+	if err := log.writeCheckpoint(); err != nil {
+		return err
+	}
+	if err := log.fs.Rename(tempFileName, log.file); err != nil {
+		return err
+	}
+	return log.fs.SyncDir(filepath.Dir(log.file))
 }
 
-//Restore vlog to given memtable
-func (log *vlog) RestoreTo(headOffset uint32, memtable *Memtable) error {
-	reader, err := os.OpenFile(log.file, os.O_RDONLY|os.O_CREATE, 0666)
+//Finish the truncation that was interrupted by a crash
+//the copy replaces the vlog only if the checkpoint already has its tail,otherwise it's removed
+func (log *vlog) finishTruncation() error {
+	infos, err := log.fs.ReadDir(filepath.Dir(log.file))
 	if err != nil {
 		return err
 	}
-	defer reader.Close()
-	_, err = reader.Seek(int64(headOffset), 0)
+	prefix := filepath.Base(log.file) + truncationSuffix
+	found := false
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), prefix) {
+			continue
+		}
+		found = true
+		path := filepath.Join(filepath.Dir(log.file), info.Name())
+		tail, err := strconv.ParseUint(strings.TrimPrefix(info.Name(), prefix), 10, 64)
+		if err == nil && tail == log.tail {
+			err = log.fs.Rename(path, log.file)
+		} else {
+			err = log.fs.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return nil
+	}
+	return log.fs.SyncDir(filepath.Dir(log.file))
+}
+
+//Flush appended entries to the disk
+func (log *vlog) syncFile() error {
+	file, err := log.fs.OpenFile(log.file, os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		return err
 	}
-	stat, err := reader.Stat()
+	defer file.Close()
+	return file.Sync()
+}
+
+//Restore vlog to the memtable of given lsm tree
+func (log *vlog) RestoreTo(headOffset uint64, lsm *LsmTree) error {
+	if headOffset < log.tail {
+		return fmt.Errorf("vlog head %d is before the tail %d", headOffset, log.tail)
+	}
+	reader, err := log.fs.OpenFile(log.file, os.O_RDONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return err
 	}
-	length := stat.Size() - int64(headOffset)
-	if length == 0 {
-		//head is the tail
-		return nil
-	}
-	buffer := make([]byte, length)
-	_, err = reader.Read(buffer)
+	defer reader.Close()
+	position := int64(headOffset - log.tail)
+	_, err = reader.Seek(position, 0)
 	if err != nil {
 		return err
 	}
-	lastPosition := 0
-	nextOffset := uint32(0)
-	for lastPosition != len(buffer) {
-		keyLength := binary.BigEndian.Uint32(buffer[lastPosition : lastPosition+4])
-		valueLength := binary.BigEndian.Uint32(buffer[lastPosition+4 : lastPosition+8])
-		key := buffer[lastPosition+8 : lastPosition+8+int(keyLength)]
-		metaLength := uint32Size + uint32Size + int(keyLength) + int(valueLength)
-		err := memtable.Put(key, &ValueMeta{length: uint32(metaLength), offset: nextOffset + headOffset})
+	//entries are streamed,the unflushed part of the vlog can be bigger than the memory
+	bufferReader := bufio.NewReader(reader)
+	nextOffset := uint64(0)
+	for {
+		entry, length, err := readEntry(bufferReader)
+		if err == io.EOF {
+			break
+		}
+		//the last entry was torn by a crash, it was never acknowledged so drop it
+		//it's either cut or has the full length with the bytes that never reached the disk
+		if errors.Is(err, io.ErrUnexpectedEOF) || err == errChecksumMismatch && isEOF(bufferReader) {
+			if !log.readOnly {
+				err := log.fs.Truncate(log.file, position+int64(nextOffset))
+				if err != nil {
+					return err
+				}
+			}
+			log.size = uint64(position) + nextOffset
+			return nil
+		}
 		if err != nil {
 			return err
 		}
-		nextOffset += uint32(metaLength)
-		lastPosition += uint32Size
-		lastPosition += uint32Size
-		lastPosition += int(keyLength)
-		lastPosition += int(valueLength)
+		entries, metas, err := unbatch(entry, &ValueMeta{length: length, offset: headOffset + nextOffset})
+		//the batch was written with a single append,a torn entry inside of it is a corruption
+		if err != nil {
+			return corrupted(err)
+		}
+		for i, entry := range entries {
+			tree := lsm.familyById(entry.family)
+			if tree == nil {
+				return fmt.Errorf("vlog entry at offset %d belongs to unknown column family %d", metas[i].offset, entry.family)
+			}
+			if entry.isRangeDeletion() {
+				err = tree.applyRangeTombstone(entry.key, entry.value)
+			} else {
+				err = tree.memtable.Put(entry.key, tree.inlined(entry, metas[i]))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		nextOffset += uint64(length)
 	}
-	log.size = uint32(stat.Size())
+	log.size = uint64(position) + nextOffset
 	return nil
 }
 
@@ -220,7 +635,7 @@ func (log *vlog) RestoreTo(headOffset uint32, memtable *Memtable) error {
 //| Key Length | Value length | Key | Value |
 //+------------+--------------+-----+-------+
 func (log *vlog) Append(entry *TableEntry) (*ValueMeta, error) {
-	writer, err := os.OpenFile(log.file, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	writer, err := log.fs.OpenFile(log.file, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return nil, err
 	}
@@ -229,13 +644,38 @@ func (log *vlog) Append(entry *TableEntry) (*ValueMeta, error) {
 	if err != nil {
 		return nil, err
 	}
-	meta := &ValueMeta{length: length, offset: log.size}
-	log.size += length
+	if log.sync {
+		if err := writer.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	meta := &ValueMeta{length: length, offset: log.tail + log.size}
+	log.size += uint64(length)
 	return meta, nil
 }
 
+//Append all entries as a single batch, either all of them are restored after a crash or none
+//Returns metas of every entry in the batch
+func (log *vlog) AppendBatch(entries []*TableEntry) ([]*ValueMeta, error) {
+	buffer := bytes.NewBuffer([]byte{})
+	for _, entry := range entries {
+		if _, err := entry.writeTo(buffer); err != nil {
+			return nil, err
+		}
+	}
+	batch := &TableEntry{value: buffer.Bytes(), flags: batchFlag}
+	meta, err := log.Append(batch)
+	if err != nil {
+		return nil, err
+	}
+	_, metas, err := unbatch(batch, meta)
+	return metas, err
+}
+
 //metadata of saved entry in vlog
 type ValueMeta struct {
 	length uint32 //value length in vlog file
-	offset uint32 //value offset in vlog file
+	offset uint64 //value offset in vlog file,it's logical and stays the same after gc
+	inline bool   //value is small,so it's kept in memtable and sstable
+	value  []byte //inlined value
 }
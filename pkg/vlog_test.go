@@ -1,6 +1,7 @@
 package wiskey
 
 import (
+	"encoding/binary"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -13,12 +14,15 @@ func TestVlog_Append(t *testing.T) {
 	defer checkpoint.Close()
 	defer os.Remove(file.Name())
 	defer os.Remove(checkpoint.Name())
-	vlog := NewVlog(file.Name(), checkpoint.Name())
+	vlog, err := NewVlog(file.Name(), checkpoint.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
 	//test entries
 	entries := FakeEntries()
 	//save entries
 	for _, entry := range entries {
-		length := uint32(uint32Size /*key length*/ + uint32Size /*value length*/ + len(entry.key) /*ANITA takes 5 bytes*/ + len(entry.value) /*DEVELOPER takes 8 bytes*/)
+		length := uint32(uint32Size /*key length*/ + uint32Size /*value length*/ + len(entry.key) /*ANITA takes 5 bytes*/ + len(entry.value) /*DEVELOPER takes 8 bytes*/ + uint32Size /*checksum*/)
 		meta, err := vlog.Append(&entry)
 		if err != nil {
 			t.Error(err)
@@ -27,15 +31,15 @@ func TestVlog_Append(t *testing.T) {
 			t.Error("The lengths don't match")
 		}
 	}
-	currentOffset := uint32(0)
+	currentOffset := uint64(0)
 	//search them
 	for _, entry := range entries {
-		length := uint32(uint32Size /*key length*/ + uint32Size /*value length*/ + len(entry.key) /*ANITA takes 5 bytes*/ + len(entry.value) /*DEVELOPER takes 8 bytes*/)
+		length := uint32(uint32Size /*key length*/ + uint32Size /*value length*/ + len(entry.key) /*ANITA takes 5 bytes*/ + len(entry.value) /*DEVELOPER takes 8 bytes*/ + uint32Size /*checksum*/)
 		val, err := vlog.Get(ValueMeta{length: length, offset: currentOffset})
 		if err != nil {
 			t.Error(err)
 		}
-		currentOffset += length
+		currentOffset += uint64(length)
 		if string(val.key) != string(entry.key) {
 			t.Error("Wrong keys")
 		}
@@ -44,3 +48,81 @@ func TestVlog_Append(t *testing.T) {
 		}
 	}
 }
+
+func TestVlog_GetMany(t *testing.T) {
+	file, _ := ioutil.TempFile("", "")
+	checkpoint, _ := ioutil.TempFile("", "")
+	defer file.Close()
+	defer checkpoint.Close()
+	defer os.Remove(file.Name())
+	defer os.Remove(checkpoint.Name())
+	vlog, err := NewVlog(file.Name(), checkpoint.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := FakeEntries()
+	var metas []*ValueMeta
+	for _, entry := range entries {
+		meta, err := vlog.Append(&entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		metas = append(metas, meta)
+	}
+	//skip one entry, so there are two runs of adjacent entries, and ask in reverse order
+	requested := []*ValueMeta{metas[5], metas[4], nil, metas[2], metas[1], metas[0]}
+	found, err := vlog.GetMany(requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found[2] != nil {
+		t.Error("Nil meta has to return nil entry")
+	}
+	for i, index := range []int{5, 4, -1, 2, 1, 0} {
+		if index == -1 {
+			continue
+		}
+		if string(found[i].key) != string(entries[index].key) || string(found[i].value) != string(entries[index].value) {
+			t.Errorf("Wrong entry at position %d", i)
+		}
+	}
+}
+
+//Checkpoint with the head and the tail as uint64
+func testCheckpoint(head uint64, tail uint64) []byte {
+	buffer := make([]byte, int64Size*2)
+	binary.BigEndian.PutUint64(buffer, head)
+	binary.BigEndian.PutUint64(buffer[int64Size:], tail)
+	return buffer
+}
+
+//Checkpoints written before offsets became uint64 keep only the uint32 head
+func TestVlog_ReadOldCheckpoint(t *testing.T) {
+	cases := []struct {
+		checkpoint []byte
+		head       uint64
+		tail       uint64
+	}{
+		{[]byte{0, 0, 0, 7}, 7, 0},
+		{testCheckpoint(1<<40+9, 1<<40), 1<<40 + 9, 1 << 40},
+	}
+	for _, c := range cases {
+		log := &vlog{fs: NewMemFS(), checkpoint: "/checkpoint"}
+		if err := writeFile(log.fs, log.checkpoint, c.checkpoint); err != nil {
+			t.Fatal(err)
+		}
+		if err := log.readCheckpoint(); err != nil {
+			t.Fatal(err)
+		}
+		if log.head != c.head || log.tail != c.tail {
+			t.Fatalf("Checkpoint %v was read as head %d and tail %d", c.checkpoint, log.head, log.tail)
+		}
+	}
+	log := &vlog{fs: NewMemFS(), checkpoint: "/checkpoint"}
+	if err := writeFile(log.fs, log.checkpoint, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.readCheckpoint(); err == nil {
+		t.Fatal("Checkpoint of unknown size has to be rejected")
+	}
+}
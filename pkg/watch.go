@@ -0,0 +1,120 @@
+package wiskey
+
+import (
+	"bytes"
+	"errors"
+)
+
+const watchBuffer = 1024 //events a watcher can fall behind by
+
+var ErrWatcherLagged = errors.New("watcher fell behind the writes")
+
+//Change of a key in the order of writes
+type WatchEvent struct {
+	Key     []byte
+	Value   []byte //nil for deletes
+	Deleted bool
+	End     []byte //set by range deletes,all keys from Key till End were deleted
+}
+
+//Receives changes of keys with the prefix in a single column family
+//writes never wait for watchers,a watcher that falls behind is stopped with ErrWatcherLagged
+type Watcher struct {
+	tree    *LsmTree
+	prefix  []byte
+	events  chan WatchEvent
+	err     error
+	stopped bool
+}
+
+//Watch changes of keys with the prefix,an empty prefix watches all keys of the family
+//only writes after Watch returns are sent
+func (lsm *LsmTree) Watch(prefix []byte) (*Watcher, error) {
+	lsm.rwm.Lock()
+	defer lsm.rwm.Unlock()
+	if lsm.root.closed {
+		return nil, ErrClosed
+	}
+	watcher := &Watcher{tree: lsm, prefix: append([]byte{}, prefix...), events: make(chan WatchEvent, watchBuffer)}
+	lsm.root.watchers[watcher] = struct{}{}
+	return watcher, nil
+}
+
+//Events are closed when the watcher stops,keys and values must not be modified
+func (watcher *Watcher) Events() <-chan WatchEvent {
+	return watcher.events
+}
+
+//Why the watcher stopped,ErrClosed if the tree was closed and nil if it's running or was stopped by Close
+func (watcher *Watcher) Err() error {
+	watcher.tree.rwm.RLock()
+	defer watcher.tree.rwm.RUnlock()
+	return watcher.err
+}
+
+//Stop the watcher,events that were already sent can still be read
+func (watcher *Watcher) Close() {
+	watcher.tree.rwm.Lock()
+	defer watcher.tree.rwm.Unlock()
+	watcher.stop(nil)
+}
+
+//It's called with the write lock held
+func (watcher *Watcher) stop(err error) {
+	if watcher.stopped {
+		return
+	}
+	watcher.stopped, watcher.err = true, err
+	close(watcher.events)
+	delete(watcher.tree.root.watchers, watcher)
+}
+
+//Check if the change touches keys with the prefix
+func (watcher *Watcher) matches(event WatchEvent) bool {
+	if event.End == nil {
+		return bytes.HasPrefix(event.Key, watcher.prefix)
+	}
+	//the range overlaps keys with the prefix
+	end := prefixEnd(watcher.prefix)
+	return bytes.Compare(event.End, watcher.prefix) > 0 && (end == nil || bytes.Compare(event.Key, end) < 0)
+}
+
+//Send the change of the tree to its watchers,it's called with the write lock held after the change was applied
+func (lsm *LsmTree) notify(event WatchEvent) {
+	if len(lsm.root.watchers) == 0 {
+		return
+	}
+	//callers can reuse their buffers
+	event.Key = append([]byte{}, event.Key...)
+	if event.Value != nil {
+		event.Value = append([]byte{}, event.Value...)
+	}
+	if event.End != nil {
+		event.End = append([]byte{}, event.End...)
+	}
+	for watcher := range lsm.root.watchers {
+		if watcher.tree != lsm || !watcher.matches(event) {
+			continue
+		}
+		select {
+		case watcher.events <- event:
+		default:
+			watcher.stop(ErrWatcherLagged)
+		}
+	}
+}
+
+//Change of the written entry,tombstones are deletes
+func writeEvent(entry *TableEntry) WatchEvent {
+	if string(entry.value) == tombstone {
+		return WatchEvent{Key: entry.key, Deleted: true}
+	}
+	return WatchEvent{Key: entry.key, Value: entry.value}
+}
+
+//Stop all watchers of the closed tree
+func (lsm *LsmTree) stopWatchers() {
+	for watcher := range lsm.root.watchers {
+		watcher.stop(ErrClosed)
+	}
+}
@@ -0,0 +1,99 @@
+package wiskey
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+//Read events that were already sent
+func receivedEvents(watcher *Watcher) []string {
+	var events []string
+	for {
+		select {
+		case event, ok := <-watcher.Events():
+			if !ok {
+				return events
+			}
+			switch {
+			case event.End != nil:
+				events = append(events, fmt.Sprintf("delete %s-%s", event.Key, event.End))
+			case event.Deleted:
+				events = append(events, "delete "+string(event.Key))
+			default:
+				events = append(events, fmt.Sprintf("put %s=%s", event.Key, event.Value))
+			}
+		default:
+			return events
+		}
+	}
+}
+
+func TestLsmTree_Watch(t *testing.T) {
+	db, err := Open(statsOptions(NewMemFS()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	users, err := db.CreateColumnFamily("users", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := db.Watch([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := users.Watch(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("a1")
+	if err := db.Put(&TableEntry{key: key, value: []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	//events don't share the buffer of the caller
+	key[1] = '9'
+	if err := db.Put(&TableEntry{key: []byte("b1"), value: []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete([]byte("a1")); err != nil {
+		t.Fatal(err)
+	}
+	batch := NewWriteBatch()
+	batch.Put("", []byte("a2"), []byte("v2"))
+	batch.Put("users", []byte("a3"), []byte("v3"))
+	batch.Delete("users", []byte("b3"))
+	if err := db.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteRange([]byte("0"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteRange([]byte("0"), []byte("a0")); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[put a1=v1 delete a1 put a2=v2 delete 0-a0]"
+	if events := fmt.Sprint(receivedEvents(watcher)); events != expected {
+		t.Fatalf("watcher of the prefix received %s,expected %s", events, expected)
+	}
+	expected = "[put a3=v3 delete b3]"
+	if events := fmt.Sprint(receivedEvents(all)); events != expected {
+		t.Fatalf("watcher of the family received %s,expected %s", events, expected)
+	}
+	//writes don't wait for a slow watcher
+	for i := 0; i <= watchBuffer; i++ {
+		if err := users.Put(&TableEntry{key: []byte(fmt.Sprintf("k%d", i)), value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events := receivedEvents(all); len(events) != watchBuffer || !errors.Is(all.Err(), ErrWatcherLagged) {
+		t.Fatalf("lagging watcher received %d events and stopped with %v", len(events), all.Err())
+	}
+	db.Close()
+	if _, ok := <-watcher.Events(); ok || !errors.Is(watcher.Err(), ErrClosed) {
+		t.Fatalf("watchers have to be stopped by Close,got %v", watcher.Err())
+	}
+	if _, err := db.Watch(nil); !errors.Is(err, ErrClosed) {
+		t.Fatalf("closed tree has to return ErrClosed,got %v", err)
+	}
+}
@@ -1,76 +1,122 @@
 package wiskey
 
-import "io"
+import (
+	"bytes"
+	"io"
+	"time"
+)
 
 //sstable writer
 type SSTableWriter struct {
-	maxBlockLength       uint32
-	currentBlockPosition uint32
-	size                 uint32 //how many bytes were written to file
-	writeCloser          io.WriteCloser
-	inMemoryIndex        []tableIndex
+	maxBlockLength uint32
+	compressor     Compressor    //can be nil,then blocks are not compressed
+	block          *blockBuilder //current block,it's written when full
+	size           uint64        //how many bytes were written to file,tables can be bigger than 4GiB
+	writeCloser    io.WriteCloser
+	inMemoryIndex  []tableIndex
+	properties     TableProperties
 }
 
-//create new writeCloser
-func NewWriter(w io.WriteCloser,blockLength uint32) *SSTableWriter {
+//create new writeCloser,blocks are written when they reach blockLength bytes
+func NewWriter(w io.WriteCloser, blockLength uint32, compressor Compressor) *SSTableWriter {
 	return &SSTableWriter{
-		maxBlockLength:       blockLength,
-		writeCloser:          w,
-		currentBlockPosition: uint32(0),
-		size:                 uint32(0),
-		inMemoryIndex:        indexes{},
+		maxBlockLength: blockLength,
+		compressor:     compressor,
+		writeCloser:    w,
+		block:          newBlockBuilder(),
+		inMemoryIndex:  indexes{},
+		properties:     TableProperties{Comparator: bytewiseComparator},
 	}
 }
 
 //close the writeCloser and returns the index Offset in the file
 func (w *SSTableWriter) Close() error {
-	//if there are still some remaining bytes then save them in the index
-	w.closeBlock()
-	err := w.writeIndex()
+	//if there are still some remaining entries then save them in the last block
+	err := w.closeBlock()
 	if err != nil {
 		return err
 	}
-	footer := Footer{indexOffset: w.size}
-	footer.writeTo(w.writeCloser)
+	footer := Footer{version: formatVersion}
+	footer.index, err = w.writeIndex()
+	if err != nil {
+		return err
+	}
+	w.properties.CreatedAt = time.Now()
+	footer.properties, err = w.writeBlock(withChecksum(w.properties.asByteArray()))
+	if err != nil {
+		return err
+	}
+	footer.filter = blockHandle{offset: w.size}
+	_, err = footer.writeTo(w.writeCloser)
+	if err != nil {
+		return err
+	}
+	//the table has to be on the disk before the vlog head is moved past its entries
+	if syncer, ok := w.writeCloser.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			w.writeCloser.Close()
+			return err
+		}
+	}
 	return w.writeCloser.Close()
 }
 
 //Write entry to the file, all entries have to be sorted in advance
-func (w *SSTableWriter) WriteEntry(e *sstableEntry) (uint32, error) {
-	length, err := e.writeTo(w.writeCloser)
-	w.size += length
-	if err != nil {
-		return length, err
-	}
-	//if block is full then create the index for this block
+func (w *SSTableWriter) WriteEntry(e *sstableEntry) error {
+	w.block.add(e)
+	w.properties.add(e)
+	//if block is full then write it and create the index for this block
 	if w.blockIsFull() {
-		w.closeBlock()
+		return w.closeBlock()
 	}
-	return length, nil
+	return nil
 }
 
 func (w *SSTableWriter) blockIsFull() bool {
-	return w.maxBlockLength <= w.blockCapacity()
+	return w.maxBlockLength <= uint32(w.block.size())
 }
 
-func (w *SSTableWriter) blockCapacity() uint32 {
-	return w.size - w.currentBlockPosition
-}
-
-func (w *SSTableWriter) closeBlock() {
-	//save the block in the index only when there are some bytes between the last saved position and current written length
-	if w.size > w.currentBlockPosition {
-		w.inMemoryIndex = append(w.inMemoryIndex, tableIndex{Offset: w.currentBlockPosition, BlockLength: w.size - w.currentBlockPosition})
-		w.currentBlockPosition = w.size
+func (w *SSTableWriter) closeBlock() error {
+	//save the block only when there are some entries in it
+	if w.block.empty() {
+		return nil
+	}
+	lastKey := w.block.lastKey
+	//the type of compression and checksum follow the block
+	//+-------+------------------+-----+
+	//| Block | Compression type | CRC |
+	//+-------+------------------+-----+
+	block, compression, err := compress(w.compressor, w.block.finish())
+	if err != nil {
+		return err
+	}
+	handle, err := w.writeBlock(withChecksum(append(block, compression)))
+	if err != nil {
+		return err
 	}
+	w.inMemoryIndex = append(w.inMemoryIndex, tableIndex{Offset: handle.offset, BlockLength: uint32(handle.length), LastKey: lastKey})
+	w.block = newBlockBuilder()
+	return nil
 }
 
-func (w *SSTableWriter) writeIndex() error {
+//Index block keeps all indexes and the checksum
+func (w *SSTableWriter) writeIndex() (blockHandle, error) {
+	buffer := bytes.NewBuffer([]byte{})
 	for _, index := range w.inMemoryIndex {
-		err := index.WriteTo(w.writeCloser)
+		_, err := index.writeTo(buffer)
 		if err != nil {
-			return err
+			return blockHandle{}, err
 		}
 	}
-	return nil
+	return w.writeBlock(withChecksum(buffer.Bytes()))
+}
+
+func (w *SSTableWriter) writeBlock(block []byte) (blockHandle, error) {
+	length, err := w.writeCloser.Write(block)
+	if err != nil {
+		return blockHandle{}, err
+	}
+	handle := blockHandle{offset: w.size, length: uint64(length)}
+	w.size += uint64(length)
+	return handle, nil
 }